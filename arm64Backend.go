@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+// arm64ArgumentRegisters carry the first eight arguments of a call, the rest go on the stack
+var arm64ArgumentRegisters = []string{"x0", "x1", "x2", "x3", "x4", "x5", "x6", "x7"}
+
+// arm64Registers are the registers values are kept in. Calls fill x0 to x7, x16 and x17 are where
+// operands are worked on, x18 belongs to the platform and x29 and x30 hold the frame and return
+// address, so none of those are handed out
+var arm64Registers = ir.Registers{
+	Preserved: []string{"x19", "x20", "x21", "x22", "x23", "x24", "x25", "x26", "x27", "x28"},
+	Scratch:   []string{"x9", "x10", "x11", "x12", "x13", "x14", "x15"},
+}
+
+var arm64Instructions = map[ir.Op]string{
+	ir.Add:      "add",
+	ir.Subtract: "sub",
+	ir.Multiply: "mul",
+	ir.Divide:   "sdiv",
+}
+
+var arm64Conditions = map[ir.Op]string{
+	ir.Equal:        "eq",
+	ir.NotEqual:     "ne",
+	ir.Less:         "lt",
+	ir.LessEqual:    "le",
+	ir.Greater:      "gt",
+	ir.GreaterEqual: "ge",
+}
+
+func init() {
+	RegisterProgramBackend("linux-arm64", arm64Backend{})
+}
+
+// arm64Backend writes the optimised IR of the program as GNU assembler AArch64 assembly following
+// AAPCS64, and links it against the C library with gcc, or aarch64-linux-gnu-gcc on other machines
+type arm64Backend struct{}
+
+func (arm64Backend) Build(program *ast.Program, lowered *ir.Program, sourcePath string, options BuildOptions) (string, error) {
+	if options.Assembler != "" || options.Linker == "builtin" {
+		return "", fmt.Errorf("linux-arm64 is only assembled and linked by gcc")
+	}
+	exePath := strings.TrimSuffix(sourcePath, ".gry")
+	asmPath := exePath + ".s"
+	if err := ioutil.WriteFile(asmPath, []byte(compileARM64(lowered)), 0644); err != nil {
+		return "", err
+	}
+	compiler := "aarch64-linux-gnu-gcc"
+	if runtime.GOARCH == "arm64" {
+		compiler = "gcc"
+	}
+	if _, err := exec.LookPath(compiler); err != nil {
+		return "", fmt.Errorf("linking for linux-arm64 needs %s, the assembly is in %s", compiler, asmPath)
+	}
+	out, err := exec.Command(compiler, asmPath, "-o", exePath).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v\n%s", compiler, err, out)
+	}
+	return exePath, nil
+}
+
+// arm64Compiler writes the procedures of the IR as AArch64 functions
+type arm64Compiler struct {
+	body       strings.Builder
+	procedure  *ir.Procedure
+	allocation *ir.Allocation
+	label      string
+	// outgoing is how many bytes at the bottom of the frame hold the arguments calls pass on the stack
+	outgoing int
+	// next is the block written after the current one, jumps to it are left out
+	next *ir.Block
+	// traps is set once the procedure has a division that can branch to its trap label
+	traps bool
+}
+
+// compileARM64 writes the constants of the program and then each procedure, thisisthepie being main
+func compileARM64(program *ir.Program) string {
+	c := &arm64Compiler{}
+	c.line("// compiled by garylang for linux-arm64, build it with gcc")
+	constants := getAssemblyConstantsFromIR(program)
+	names := []string{}
+	for name := range constants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		c.line("")
+		c.line("\t.section .rodata")
+		for _, name := range names {
+			c.line("%s:", name)
+			c.line("\t.ascii \"%s\"", arm64String(constants[name]))
+		}
+	}
+	c.line("")
+	c.line("\t.text")
+	for _, procedure := range program.Procedures {
+		c.compileProcedure(procedure)
+	}
+	c.line("")
+	c.line("\t.section .note.GNU-stack,\"\",%%progbits")
+	return c.body.String()
+}
+
+func (c *arm64Compiler) line(format string, args ...interface{}) {
+	fmt.Fprintf(&c.body, format+"\n", args...)
+}
+
+// compileProcedure gives procedure a frame below the saved x29 and x30, holding from sp upwards the
+// arguments of its calls past the eighth, its slots, the registers that were spilled and the
+// preserved registers it uses. sp stays on a 16 byte boundary throughout
+func (c *arm64Compiler) compileProcedure(procedure *ir.Procedure) {
+	c.procedure = procedure
+	c.allocation = ir.Allocate(procedure, arm64Registers, nil)
+	c.label = procedureLabel(procedure.Name)
+	c.traps = false
+	callArgs := 0
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			if instruction.Op == ir.Call && len(instruction.Args) > callArgs {
+				callArgs = len(instruction.Args)
+			}
+		}
+	}
+	c.outgoing = 0
+	if callArgs > len(arm64ArgumentRegisters) {
+		c.outgoing = (callArgs - len(arm64ArgumentRegisters)) * 8
+	}
+	saved := procedure.Slots + c.allocation.SpillSlots
+	frameSize := c.outgoing + (saved+len(c.allocation.Preserved))*8
+	if frameSize%16 != 0 {
+		frameSize += 16 - frameSize%16
+	}
+
+	c.line("")
+	if c.label == "main" {
+		c.line("\t.globl main")
+	}
+	c.line("\t.type %s, %%function", c.label)
+	c.line("%s:", c.label)
+	c.line("\tstp x29, x30, [sp, #-16]!")
+	c.line("\tmov x29, sp")
+	c.adjustStack("sub", frameSize)
+	for index, register := range c.allocation.Preserved {
+		c.line("\tstr %s, %s", register, c.frameSlot(saved+index))
+	}
+	for param := 0; param < procedure.Params; param++ {
+		if param < len(arm64ArgumentRegisters) {
+			c.line("\tstr %s, %s", arm64ArgumentRegisters[param], c.frameSlot(param))
+			continue
+		}
+		// above the saved x29 and x30 of the caller's call
+		c.line("\tldr x16, [x29, #%d]", 16+(param-len(arm64ArgumentRegisters))*8)
+		c.line("\tstr x16, %s", c.frameSlot(param))
+	}
+	for index, block := range procedure.Blocks {
+		c.next = nil
+		if index+1 < len(procedure.Blocks) {
+			c.next = procedure.Blocks[index+1]
+		}
+		if index > 0 {
+			c.line("%s:", c.blockLabel(block))
+		}
+		for _, instruction := range block.Instructions {
+			c.instruction(instruction)
+		}
+	}
+	c.line("%s:", c.returnLabel())
+	for index, register := range c.allocation.Preserved {
+		c.line("\tldr %s, %s", register, c.frameSlot(saved+index))
+	}
+	c.line("\tmov sp, x29")
+	c.line("\tldp x29, x30, [sp], #16")
+	c.line("\tret")
+	if c.traps {
+		c.line("%s:", c.trapLabel())
+		c.line("\tbl abort")
+	}
+	c.line("\t.size %s, .-%s", c.label, c.label)
+}
+
+// adjustStack moves sp by size with op, add or sub, which only take 12 bits at a time
+func (c *arm64Compiler) adjustStack(op string, size int) {
+	if high := size >> 12; high > 0 {
+		c.line("\t%s sp, sp, #%d, lsl #12", op, high)
+	}
+	if low := size & 0xFFF; low > 0 {
+		c.line("\t%s sp, sp, #%d", op, low)
+	}
+}
+
+// frameSlot addresses the 8 byte slot index of the frame, counted from the end of the arguments
+// passed on the stack
+func (c *arm64Compiler) frameSlot(index int) string {
+	return fmt.Sprintf("[sp, #%d]", c.outgoing+index*8)
+}
+
+func (c *arm64Compiler) blockLabel(block *ir.Block) string {
+	return ".L" + c.label + "." + labelIdentifier(block.Name)
+}
+
+func (c *arm64Compiler) returnLabel() string {
+	return ".L" + c.label + ".giveback"
+}
+
+// trapLabel is where the divisions of the procedure go when they cannot be made, to abort
+func (c *arm64Compiler) trapLabel() string {
+	return ".L" + c.label + ".trap"
+}
+
+func (c *arm64Compiler) instruction(instruction *ir.Instruction) {
+	switch instruction.Op {
+	case ir.Load:
+		dest := c.destination(instruction.Dest)
+		c.line("\tldr %s, %s", dest, c.frameSlot(instruction.Slot))
+		c.setDest(instruction.Dest, dest)
+	case ir.Store:
+		c.line("\tstr %s, %s", c.source(instruction.Args[0], "x16"), c.frameSlot(instruction.Slot))
+	case ir.Copy:
+		dest := c.destination(instruction.Dest)
+		c.load(dest, instruction.Args[0])
+		c.setDest(instruction.Dest, dest)
+	case ir.Negate:
+		dest := c.destination(instruction.Dest)
+		c.line("\tneg %s, %s", dest, c.source(instruction.Args[0], "x16"))
+		c.setDest(instruction.Dest, dest)
+	case ir.Call:
+		c.call(instruction)
+		c.setDest(instruction.Dest, "x0")
+	case ir.Jump:
+		c.jump(instruction.Targets[0])
+	case ir.Branch:
+		condition := c.source(instruction.Args[0], "x16")
+		if instruction.Targets[1] == c.next {
+			c.line("\tcbnz %s, %s", condition, c.blockLabel(instruction.Targets[0]))
+			return
+		}
+		c.line("\tcbz %s, %s", condition, c.blockLabel(instruction.Targets[1]))
+		c.jump(instruction.Targets[0])
+	case ir.Return:
+		c.load("x0", instruction.Args[0])
+		if c.next != nil {
+			c.line("\tb %s", c.returnLabel())
+		}
+	default:
+		left := c.source(instruction.Args[0], "x16")
+		right := c.source(instruction.Args[1], "x17")
+		dest := c.destination(instruction.Dest)
+		if instruction.Op == ir.Divide || instruction.Op == ir.Modulo {
+			c.checkDivision(instruction.Args[1], left, right)
+		}
+		if op, isArithmetic := arm64Instructions[instruction.Op]; isArithmetic {
+			c.line("\t%s %s, %s, %s", op, dest, left, right)
+		} else if instruction.Op == ir.Modulo {
+			// what is left over is left - (left / right) * right
+			c.line("\tsdiv x0, %s, %s", left, right)
+			c.line("\tmsub %s, x0, %s, %s", dest, right, left)
+		} else {
+			c.line("\tcmp %s, %s", left, right)
+			c.line("\tcset %s, %s", dest, arm64Conditions[instruction.Op])
+		}
+		c.setDest(instruction.Dest, dest)
+	}
+}
+
+// checkDivision goes to the trap label when left cannot be divided by right, where sdiv would give 0
+// for dividing by zero and the smallest number for dividing it by -1 rather than faulting as amd64
+// does. divisor is the operand right was loaded from, numbers other than 0 and -1 need no check
+func (c *arm64Compiler) checkDivision(divisor ir.Operand, left string, right string) {
+	if divisor.Kind == ir.ImmediateOperand && divisor.Value != 0 && divisor.Value != -1 {
+		return
+	}
+	c.line("\tcbz %s, %s", right, c.trapLabel())
+	// when right is -1, left - 1 only overflows for the smallest number
+	c.line("\tcmn %s, #1", right)
+	c.line("\tccmp %s, #1, #0, eq", left)
+	c.line("\tb.vs %s", c.trapLabel())
+	c.traps = true
+}
+
+// call passes the arguments in x0 to x7 and then on the stack, leaving the result in x0
+func (c *arm64Compiler) call(instruction *ir.Instruction) {
+	if instruction.Builtin {
+		GetStandardFunctionARM64(GetStandardFunction(instruction.Callee).AssembledBodyName)(c, instruction.Args, instruction.Types)
+		return
+	}
+	for index, arg := range instruction.Args {
+		if index < len(arm64ArgumentRegisters) {
+			c.load(arm64ArgumentRegisters[index], arg)
+			continue
+		}
+		c.line("\tstr %s, [sp, #%d]", c.source(arg, "x16"), (index-len(arm64ArgumentRegisters))*8)
+	}
+	c.line("\tbl %s", procedureLabel(instruction.Callee))
+}
+
+// callPrintf calls printf with what is already in x0 and x1, widening the int it gives back
+func (c *arm64Compiler) callPrintf() {
+	c.line("\tbl printf")
+	c.line("\tsxtw x0, w0")
+}
+
+func (c *arm64Compiler) jump(target *ir.Block) {
+	if target != c.next {
+		c.line("\tb %s", c.blockLabel(target))
+	}
+}
+
+// source is the register holding operand, which is loaded into temporary unless it is kept in a
+// register of its own
+func (c *arm64Compiler) source(operand ir.Operand, temporary string) string {
+	switch operand.Kind {
+	case ir.RegisterOperand:
+		if name, physical := c.allocation.Physical[operand.Register]; physical {
+			return name
+		}
+		c.line("\tldr %s, %s", temporary, c.frameSlot(c.procedure.Slots+c.allocation.Spilled[operand.Register]))
+	case ir.StringOperand:
+		c.address(temporary, stringConstantName(int(operand.Value)))
+	default:
+		c.immediate(temporary, operand.Value)
+	}
+	return temporary
+}
+
+// load puts operand in register
+func (c *arm64Compiler) load(register string, operand ir.Operand) {
+	if source := c.source(operand, register); source != register {
+		c.line("\tmov %s, %s", register, source)
+	}
+}
+
+// address puts the address of the constant called name in register, from the 4KB page it is in and
+// where it is in that page
+func (c *arm64Compiler) address(register string, name string) {
+	c.line("\tadrp %s, %s", register, name)
+	c.line("\tadd %s, %s, :lo12:%s", register, register, name)
+}
+
+// immediate puts value in register, 16 bits at a time when mov cannot do it in one
+func (c *arm64Compiler) immediate(register string, value int64) {
+	if value >= -65536 && value < 65536 {
+		c.line("\tmov %s, #%d", register, value)
+		return
+	}
+	bits := uint64(value)
+	c.line("\tmovz %s, #%d", register, bits&0xFFFF)
+	for shift := uint(16); shift < 64; shift += 16 {
+		if chunk := bits >> shift & 0xFFFF; chunk != 0 {
+			c.line("\tmovk %s, #%d, lsl #%d", register, chunk, shift)
+		}
+	}
+}
+
+// destination is the register an instruction giving dest a value should leave it in, x16 when
+// dest was spilled
+func (c *arm64Compiler) destination(dest ir.Register) string {
+	if name, physical := c.allocation.Physical[dest]; physical {
+		return name
+	}
+	return "x16"
+}
+
+// setDest moves the value in register to where dest is kept, if the instruction has a dest
+func (c *arm64Compiler) setDest(dest ir.Register, register string) {
+	if dest == ir.NoRegister {
+		return
+	}
+	if name, physical := c.allocation.Physical[dest]; physical {
+		if name != register {
+			c.line("\tmov %s, %s", name, register)
+		}
+		return
+	}
+	c.line("\tstr %s, %s", register, c.frameSlot(c.procedure.Slots+c.allocation.Spilled[dest]))
+}
+
+// arm64String is value as it is written between the quotes of .ascii, every byte that is not
+// printable ascii, and " and \, is written as \ and three octal digits
+func arm64String(value []byte) string {
+	literal := ""
+	for _, b := range value {
+		if b < ' ' || b > '~' || b == '"' || b == '\\' {
+			literal += fmt.Sprintf("\\%03o", b)
+		} else {
+			literal += string(b)
+		}
+	}
+	return literal
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+func Test_compileARM64(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	i = 0 #
+	whilst £ i < 2 $ /
+		printthevalue £ ¬"a"\\\n¬ $ #
+		i = i + 1 #
+	\
+	perchance £ i % 2 == 0 $ /
+		printthenumber £ 100000 $ #
+	\
+	giveback last £ 1 2 3 4 5 6 7 8 - i $ #
+\
+halfleft last £ a b c d e f g h n $ /
+	giveback n #
+\`
+	want := `// compiled by garylang for linux-arm64, build it with gcc
+
+	.section .rodata
+numberformat:
+	.ascii "%lld\000"
+p0:
+	.ascii "\042a\042\134\012\000"
+stringformat:
+	.ascii "%s\000"
+
+	.text
+
+	.globl main
+	.type main, %function
+main:
+	stp x29, x30, [sp, #-16]!
+	mov x29, sp
+	sub sp, sp, #16
+	mov x16, #0
+	str x16, [sp, #8]
+.Lmain.whilst1:
+	ldr x9, [sp, #8]
+	mov x17, #2
+	cmp x9, x17
+	cset x9, lt
+	cbz x9, .Lmain.endwhilst1
+.Lmain.body1:
+	adrp x0, stringformat
+	add x0, x0, :lo12:stringformat
+	adrp x1, p0
+	add x1, x1, :lo12:p0
+	bl printf
+	sxtw x0, w0
+	ldr x9, [sp, #8]
+	mov x17, #1
+	add x9, x9, x17
+	str x9, [sp, #8]
+	b .Lmain.whilst1
+.Lmain.endwhilst1:
+	ldr x9, [sp, #8]
+	mov x17, #2
+	sdiv x0, x9, x17
+	msub x9, x0, x17, x9
+	mov x17, #0
+	cmp x9, x17
+	cset x9, eq
+	cbz x9, .Lmain.endperchance2
+.Lmain.then2:
+	adrp x0, numberformat
+	add x0, x0, :lo12:numberformat
+	movz x1, #34464
+	movk x1, #1, lsl #16
+	bl printf
+	sxtw x0, w0
+.Lmain.endperchance2:
+	ldr x9, [sp, #8]
+	neg x9, x9
+	mov x0, #1
+	mov x1, #2
+	mov x2, #3
+	mov x3, #4
+	mov x4, #5
+	mov x5, #6
+	mov x6, #7
+	mov x7, #8
+	str x9, [sp, #0]
+	bl halfleft_last
+	mov x9, x0
+	mov x0, x9
+.Lmain.giveback:
+	mov sp, x29
+	ldp x29, x30, [sp], #16
+	ret
+	.size main, .-main
+
+	.type halfleft_last, %function
+halfleft_last:
+	stp x29, x30, [sp, #-16]!
+	mov x29, sp
+	sub sp, sp, #80
+	str x0, [sp, #0]
+	str x1, [sp, #8]
+	str x2, [sp, #16]
+	str x3, [sp, #24]
+	str x4, [sp, #32]
+	str x5, [sp, #40]
+	str x6, [sp, #48]
+	str x7, [sp, #56]
+	ldr x16, [x29, #16]
+	str x16, [sp, #64]
+	ldr x9, [sp, #64]
+	mov x0, x9
+.Lhalfleft_last.giveback:
+	mov sp, x29
+	ldp x29, x30, [sp], #16
+	ret
+	.size halfleft_last, .-halfleft_last
+
+	.section .note.GNU-stack,"",%progbits
+`
+	if got := compileARM64(lowerProgram(treeFromTokens(tokenize(source)))); got != want {
+		t.Errorf("compileARM64() = %v, want %v", got, want)
+	}
+}
+
+func Test_compileARM64_division(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	giveback half £ 7 2 $ #
+\
+halfleft half £ n d $ /
+	giveback n / d + n % 2 #
+\`
+	want := `// compiled by garylang for linux-arm64, build it with gcc
+
+	.text
+
+	.globl main
+	.type main, %function
+main:
+	stp x29, x30, [sp, #-16]!
+	mov x29, sp
+	mov x0, #7
+	mov x1, #2
+	bl halfleft_half
+	mov x9, x0
+	mov x0, x9
+.Lmain.giveback:
+	mov sp, x29
+	ldp x29, x30, [sp], #16
+	ret
+	.size main, .-main
+
+	.type halfleft_half, %function
+halfleft_half:
+	stp x29, x30, [sp, #-16]!
+	mov x29, sp
+	sub sp, sp, #16
+	str x0, [sp, #0]
+	str x1, [sp, #8]
+	ldr x9, [sp, #0]
+	ldr x10, [sp, #8]
+	cbz x10, .Lhalfleft_half.trap
+	cmn x10, #1
+	ccmp x9, #1, #0, eq
+	b.vs .Lhalfleft_half.trap
+	sdiv x9, x9, x10
+	ldr x10, [sp, #0]
+	mov x17, #2
+	sdiv x0, x10, x17
+	msub x10, x0, x17, x10
+	add x9, x9, x10
+	mov x0, x9
+.Lhalfleft_half.giveback:
+	mov sp, x29
+	ldp x29, x30, [sp], #16
+	ret
+.Lhalfleft_half.trap:
+	bl abort
+	.size halfleft_half, .-halfleft_half
+
+	.section .note.GNU-stack,"",%progbits
+`
+	if got := compileARM64(lowerProgram(treeFromTokens(tokenize(source)))); got != want {
+		t.Errorf("compileARM64() = %v, want %v", got, want)
+	}
+}
+
+func Test_arm64String(t *testing.T) {
+	type args struct {
+		value []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Printable", args{[]byte("Hello  world! 100%")}, "Hello  world! 100%"},
+		{"Quotes and backslashes", args{[]byte(`say "\"`)}, `say \042\134\042`},
+		{"Control characters", args{[]byte("a\n\tb\x00")}, `a\012\011b\000`},
+		{"Bytes outside ascii", args{[]byte("¬1")}, `\302\2541`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := arm64String(tt.args.value); got != tt.want {
+				t.Errorf("arm64String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_arm64Compiler_immediate(t *testing.T) {
+	type args struct {
+		value int64
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Small", args{65535}, "\tmov x9, #65535\n"},
+		{"Small and negative", args{-65536}, "\tmov x9, #-65536\n"},
+		{"Two halves", args{100000}, "\tmovz x9, #34464\n\tmovk x9, #1, lsl #16\n"},
+		{"Skipping zero chunks", args{1 << 48}, "\tmovz x9, #0\n\tmovk x9, #1, lsl #48\n"},
+		{"Large and negative", args{-100000}, "\tmovz x9, #31072\n\tmovk x9, #65534, lsl #16\n\tmovk x9, #65535, lsl #32\n\tmovk x9, #65535, lsl #48\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &arm64Compiler{}
+			c.immediate("x9", tt.args.value)
+			if got := c.body.String(); got != tt.want {
+				t.Errorf("immediate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_compileARM64_assembles checks llvm-mc takes the assembly of each program at every level, so it
+// can be checked without an AArch64 machine or gcc for one
+func Test_compileARM64_assembles(t *testing.T) {
+	if _, err := exec.LookPath("llvm-mc"); err != nil {
+		t.Skip("assembling for AArch64 needs llvm-mc")
+	}
+	for _, tt := range interpreterPrograms {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				lowered := lowerProgram(treeFromTokens(tokenize(tt.source)))
+				ir.Optimise(lowered, level)
+				directory := t.TempDir()
+				asmPath := filepath.Join(directory, "program.s")
+				if err := ioutil.WriteFile(asmPath, []byte(compileARM64(lowered)), 0644); err != nil {
+					t.Fatal(err)
+				}
+				out, err := exec.Command("llvm-mc", "-triple=aarch64-linux-gnu", "-filetype=obj", "-o", filepath.Join(directory, "program.o"), asmPath).CombinedOutput()
+				if err != nil {
+					t.Errorf("at level %d llvm-mc failed: %v\n%s", level, err, out)
+				}
+			})
+		}
+	}
+}
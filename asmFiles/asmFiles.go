@@ -5,10 +5,17 @@ Alignconstbytes = `align 16 ; align data constants to the 16 byte boundary
 `
 Allocatestack = `; -----------------------------------------------------------------------------
 ; Allocate stack memory
+; The frame below the saved rbp holds the variables first, then the saved
+; registers, then 8*4 bytes of shadow space and room for the parameters that
+; are passed on the stack.
+; !!! The frame size is always a multiple of 16, so together with the pushed
+; rbp the stack stays aligned on the 16 byte boundary.
 ; -----------------------------------------------------------------------------
-sub rsp,8*7
+push rbp
+mov rbp,rsp
+sub rsp,$frameSize
 `
-`C = `; ---------------------------------------------------------------------------
+C = `; ---------------------------------------------------------------------------
 ; C management
 ; ---------------------------------------------------------------------------
 global main
@@ -23,15 +30,15 @@ Datasection = `; ---------------------------------------------------------------
 ; ---------------------------------------------------------------------------
 section .data use64
 `
+End = `
+; ----
+; END ----
+; ----
+`
 Exit = `; -----------------------------------------------------------------------------
 ; Quit
 ; -----------------------------------------------------------------------------
-mov rax,qword 0
 ret
-
-; ----
-; END ----
-; ----
 `
 Getbytes = `lol plox make asm`
 Helloworld = `; ---------------------------------------------------------------------------
@@ -185,22 +192,22 @@ Invoke = `; --------------------------------------------------------------------
 %macro Invoke 1-*
         %if %0 > 1
                 %rotate 1
-                mov rcx,qword %1
+                mov rcx,%1
                 %rotate 1
                 %if %0 > 2
-                        mov rdx,qword %1
+                        mov rdx,%1
                         %rotate 1
                         %if  %0 > 3
-                                mov r8,qword %1
+                                mov r8,%1
                                 %rotate 1
                                 %if  %0 > 4
-                                        mov r9,qword %1
+                                        mov r9,%1
                                         %rotate 1
                                         %if  %0 > 5
                                                 %assign max %0-5
                                                 %assign i 32
                                                 %rep max
-                                                        mov rax,qword %1
+                                                        mov rax,%1
                                                         mov qword [rsp+i],rax
                                                         %assign i i+8
                                                         %rotate 1
@@ -220,33 +227,11 @@ Invoke = `; --------------------------------------------------------------------
         ; -----------------------------------------
 %endmacro
 `
-Printf = `; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; 4x of them are assigned to registers.
-; 3x of them are assigned to stack spaces.
-; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; -----------------------------------------------------------------------------
-Invoke printf,$printString
-`
 Releasestack = `; -----------------------------------------------------------------------------
 ; Release stack memory
 ; -----------------------------------------------------------------------------
-add rsp,8*7
-`
-Setbytes = `cmp [$varName], byte 0
-je allocate
-
-mov rcx, [$varName]
-call free
-
-allocate:
-
-mov rcx, $valLength
-call malloc
-mov [$varName], rax
-mov rcx, [$value]
-mov [rax], rcx
+mov rsp,rbp
+pop rbp
 `
 Start64bit = `; ---------------------------------------------------------------------------
 ; Tell compiler to generate 64 bit code
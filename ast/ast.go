@@ -0,0 +1,299 @@
+// Package ast holds the syntax tree of a GaryLang program.
+// Every node remembers where in the source it was written.
+package ast
+
+import (
+	"fmt"
+)
+
+// Position is where a node starts in the source, lines and columns count from 1
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Pos gives every node that embeds a Position its place in the source
+func (p Position) Pos() Position {
+	return p
+}
+
+type Node interface {
+	Pos() Position
+}
+
+// Statement is anything that can be written in a block
+type Statement interface {
+	Node
+	statementNode()
+}
+
+// Expression is anything that evaluates to a value
+type Expression interface {
+	Node
+	expressionNode()
+}
+
+// Program is every procedure of a source file, in the order they are written
+type Program struct {
+	Procedures []*ProcDecl
+}
+
+// Pos is the start of the first procedure, a program has no place of its own
+func (p *Program) Pos() Position {
+	if len(p.Procedures) == 0 {
+		return Position{}
+	}
+	return p.Procedures[0].Position
+}
+
+// Procedure finds the first procedure called name, or nil if there is none
+func (p *Program) Procedure(name string) *ProcDecl {
+	for _, procedure := range p.Procedures {
+		if procedure.Name == name {
+			return procedure
+		}
+	}
+	return nil
+}
+
+// ProcDecl is halfleft name £ params $ / body \, positioned at its name
+type ProcDecl struct {
+	Position
+	Name   string
+	Params []*Param
+	Body   *Block
+	// Symbol is what calls to the procedure are bound to, set by the resolver
+	Symbol *Symbol
+	// Locals are the parameters and then every variable of the procedure, set by the resolver
+	Locals []*Symbol
+}
+
+type Param struct {
+	Position
+	Name string
+}
+
+// Block is the statements between a / and its \
+type Block struct {
+	Position
+	Statements []Statement
+}
+
+// Assign is name = value #
+type Assign struct {
+	Position
+	Target *Ident
+	Value  Expression
+}
+
+// Return is giveback value #
+type Return struct {
+	Position
+	Value Expression
+}
+
+// If is perchance £ condition $ / then \ with an optional otherwise / else \,
+// otherwise perchance is an Else block holding just the next If
+type If struct {
+	Position
+	Condition Expression
+	Then      *Block
+	Else      *Block
+}
+
+// While is whilst £ condition $ / body \
+type While struct {
+	Position
+	Condition Expression
+	Body      *Block
+}
+
+// Break is scarper #
+type Break struct {
+	Position
+}
+
+// Continue is carryon #
+type Continue struct {
+	Position
+}
+
+// Call is name £ args $, used both as a statement and as a value
+type Call struct {
+	Position
+	Name   string
+	Args   []Expression
+	Symbol *Symbol
+}
+
+// Ident is a variable or parameter, either used as a value or assigned to
+type Ident struct {
+	Position
+	Name   string
+	Symbol *Symbol
+}
+
+type SymbolKind int
+
+const (
+	ProcedureSymbol SymbolKind = iota
+	BuiltinSymbol
+	ParamSymbol
+	LocalSymbol
+)
+
+// Symbol is what a name refers to, the resolver points every Ident and Call at one.
+// It is positioned where the name was defined, builtins have no position
+type Symbol struct {
+	Position
+	Name string
+	Kind SymbolKind
+	// Index is the slot of a parameter or local within the frame of its procedure
+	Index int
+	// Type is the value held by a parameter or local, or given back by a procedure or builtin.
+	// The type checker fills it in, except for builtins which know theirs from the start
+	Type Type
+}
+
+// Type is the kind of value an expression gives, every value is held in 64 bits:
+// ints as themselves, bools as 1 or 0 and strings as the address of their bytes
+type Type int
+
+const (
+	// Unknown is the type of a value that has not been inferred yet
+	Unknown Type = iota
+	Int
+	Bool
+	String
+)
+
+var typeNames = map[Type]string{
+	Unknown: "unknown",
+	Int:     "int",
+	Bool:    "bool",
+	String:  "string",
+}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+// TypeOf is the type of value, once the type checker has filled in the symbols it uses
+func TypeOf(value Expression) Type {
+	switch value := value.(type) {
+	case *IntLit:
+		return Int
+	case *BoolLit:
+		return Bool
+	case *StringLit:
+		return String
+	case *Ident:
+		if value.Symbol != nil {
+			return value.Symbol.Type
+		}
+	case *Call:
+		if value.Symbol != nil {
+			return value.Symbol.Type
+		}
+	case *Binary:
+		if value.Operator.IsComparison() {
+			return Bool
+		}
+		return Int
+	case *Unary:
+		return Int
+	}
+	return Unknown
+}
+
+type IntLit struct {
+	Position
+	Value int64
+}
+
+// BoolLit is aye or nay
+type BoolLit struct {
+	Position
+	Value bool
+}
+
+// StringLit holds the bytes of a string after its escapes have been decoded
+type StringLit struct {
+	Position
+	Value string
+}
+
+type Binary struct {
+	Position
+	Operator Operator
+	Left     Expression
+	Right    Expression
+}
+
+type Unary struct {
+	Position
+	Operator Operator
+	Operand  Expression
+}
+
+type Operator int
+
+const (
+	Add Operator = iota
+	Subtract
+	Multiply
+	Divide
+	Modulo
+	Equal
+	NotEqual
+	Less
+	LessEqual
+	Greater
+	GreaterEqual
+	Negate
+)
+
+var operatorSymbols = map[Operator]string{
+	Add:          "+",
+	Subtract:     "-",
+	Multiply:     "*",
+	Divide:       "/",
+	Modulo:       "%",
+	Equal:        "==",
+	NotEqual:     "!=",
+	Less:         "<",
+	LessEqual:    "<=",
+	Greater:      ">",
+	GreaterEqual: ">=",
+	Negate:       "-",
+}
+
+func (o Operator) String() string {
+	return operatorSymbols[o]
+}
+
+// IsComparison is true for the operators that give 1 or 0
+func (o Operator) IsComparison() bool {
+	return o >= Equal && o <= GreaterEqual
+}
+
+func (*Assign) statementNode()   {}
+func (*Return) statementNode()   {}
+func (*If) statementNode()       {}
+func (*While) statementNode()    {}
+func (*Break) statementNode()    {}
+func (*Continue) statementNode() {}
+func (*Call) statementNode()     {}
+
+func (*Call) expressionNode()      {}
+func (*Ident) expressionNode()     {}
+func (*IntLit) expressionNode()    {}
+func (*BoolLit) expressionNode()   {}
+func (*StringLit) expressionNode() {}
+func (*Binary) expressionNode()    {}
+func (*Unary) expressionNode()     {}
@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Inspect calls visit for node and then, if visit returned true, for each of its children in source order
+func Inspect(node Node, visit func(node Node) bool) {
+	if !visit(node) {
+		return
+	}
+	for _, child := range children(node) {
+		Inspect(child, visit)
+	}
+}
+
+func children(node Node) []Node {
+	nodes := []Node{}
+	switch node := node.(type) {
+	case *Program:
+		for _, procedure := range node.Procedures {
+			nodes = append(nodes, procedure)
+		}
+	case *ProcDecl:
+		for _, param := range node.Params {
+			nodes = append(nodes, param)
+		}
+		nodes = append(nodes, node.Body)
+	case *Block:
+		for _, statement := range node.Statements {
+			nodes = append(nodes, statement)
+		}
+	case *Assign:
+		nodes = append(nodes, node.Target, node.Value)
+	case *Return:
+		nodes = append(nodes, node.Value)
+	case *If:
+		nodes = append(nodes, node.Condition, node.Then)
+		if node.Else != nil {
+			nodes = append(nodes, node.Else)
+		}
+	case *While:
+		nodes = append(nodes, node.Condition, node.Body)
+	case *Call:
+		for _, arg := range node.Args {
+			nodes = append(nodes, arg)
+		}
+	case *Binary:
+		nodes = append(nodes, node.Left, node.Right)
+	case *Unary:
+		nodes = append(nodes, node.Operand)
+	}
+	return nodes
+}
+
+// Sprint writes node as a compact s-expression, handy for comparing trees in tests
+func Sprint(node Node) string {
+	switch node := node.(type) {
+	case *Program:
+		procedures := []string{}
+		for _, procedure := range node.Procedures {
+			procedures = append(procedures, Sprint(procedure))
+		}
+		return strings.Join(procedures, "\n")
+	case *ProcDecl:
+		params := []string{}
+		for _, param := range node.Params {
+			params = append(params, param.Name)
+		}
+		return "(halfleft " + node.Name + " (" + strings.Join(params, " ") + ") " + Sprint(node.Body) + ")"
+	case *Param:
+		return node.Name
+	case *Block:
+		statements := []string{}
+		for _, statement := range node.Statements {
+			statements = append(statements, Sprint(statement))
+		}
+		return "[" + strings.Join(statements, " ") + "]"
+	case *Assign:
+		return "(= " + node.Target.Name + " " + Sprint(node.Value) + ")"
+	case *Return:
+		return "(giveback " + Sprint(node.Value) + ")"
+	case *If:
+		content := "(perchance " + Sprint(node.Condition) + " " + Sprint(node.Then)
+		if node.Else != nil {
+			content += " " + Sprint(node.Else)
+		}
+		return content + ")"
+	case *While:
+		return "(whilst " + Sprint(node.Condition) + " " + Sprint(node.Body) + ")"
+	case *Break:
+		return "scarper"
+	case *Continue:
+		return "carryon"
+	case *Call:
+		content := "(call " + node.Name
+		for _, arg := range node.Args {
+			content += " " + Sprint(arg)
+		}
+		return content + ")"
+	case *Ident:
+		return node.Name
+	case *IntLit:
+		return strconv.FormatInt(node.Value, 10)
+	case *BoolLit:
+		if node.Value {
+			return "aye"
+		}
+		return "nay"
+	case *StringLit:
+		return strconv.Quote(node.Value)
+	case *Binary:
+		return "(" + node.Operator.String() + " " + Sprint(node.Left) + " " + Sprint(node.Right) + ")"
+	case *Unary:
+		return "(" + node.Operator.String() + " " + Sprint(node.Operand) + ")"
+	}
+	return "?"
+}
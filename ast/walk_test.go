@@ -0,0 +1,74 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Inspect(t *testing.T) {
+	program := &Program{Procedures: []*ProcDecl{
+		&ProcDecl{
+			Name:   "thisisthepie",
+			Params: []*Param{&Param{Name: "n"}},
+			Body: &Block{Statements: []Statement{
+				&If{
+					Condition: &Binary{Operator: Less, Left: &Ident{Name: "n"}, Right: &IntLit{Value: 2}},
+					Then:      &Block{Statements: []Statement{&Return{Value: &Ident{Name: "n"}}}},
+				},
+				&Call{Name: "printthething", Args: []Expression{&StringLit{Value: "hi"}}},
+			}},
+		},
+	}}
+	type args struct {
+		skipIf bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			"Every node in source order",
+			args{false},
+			[]string{
+				"(halfleft thisisthepie (n) [(perchance (< n 2) [(giveback n)]) (call printthething \"hi\")])",
+				"n",
+				"[(perchance (< n 2) [(giveback n)]) (call printthething \"hi\")]",
+				"(perchance (< n 2) [(giveback n)])",
+				"(< n 2)",
+				"n",
+				"2",
+				"[(giveback n)]",
+				"(giveback n)",
+				"n",
+				"(call printthething \"hi\")",
+				"\"hi\"",
+			},
+		},
+		{
+			"Children of a node are skipped when visit returns false",
+			args{true},
+			[]string{
+				"(halfleft thisisthepie (n) [(perchance (< n 2) [(giveback n)]) (call printthething \"hi\")])",
+				"n",
+				"[(perchance (< n 2) [(giveback n)]) (call printthething \"hi\")]",
+				"(perchance (< n 2) [(giveback n)])",
+				"(call printthething \"hi\")",
+				"\"hi\"",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			Inspect(program.Procedures[0], func(node Node) bool {
+				got = append(got, Sprint(node))
+				_, isIf := node.(*If)
+				return !(isIf && tt.args.skipIf)
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inspect() visited %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+const defaultBackend = "windows-amd64"
+
+// Backend turns the pieces of a compiled program into something runnable for one platform.
+// Implementations register themselves with RegisterBackend from an init function.
+type Backend interface {
+	// Prologue is emitted before anything else in the output
+	Prologue() string
+	// DataSection lays out every constant the program uses
+	DataSection(consts map[string][]byte) string
+	// Constant lays out a single named constant
+	Constant(name string, value []byte) string
+	// CodeSection opens the code and declares the externs
+	CodeSection(externs []string) string
+	// Registers are the machine registers values may be kept in, none of them are used by the
+	// snippets for anything else
+	Registers() ir.Registers
+	// ProcedureStart labels a procedure, gives it frame and saves the registers frame keeps for the caller
+	ProcedureStart(name string, frame Frame) string
+	// Local is the operand addressing the variable in slot index of the current frame
+	Local(index int) string
+	// Parameter is the operand holding argument index on entry to a procedure
+	Parameter(index int) string
+	// Call invokes function with the given operands as its arguments
+	Call(function string, args []string) string
+	// Return leaves the current procedure with value as its result
+	Return(value string) string
+	// Label marks the current position as a jump target local to the procedure
+	Label(name string) string
+	// Jump continues at label
+	Jump(label string) string
+	// JumpIfZero continues at label when rax is zero
+	JumpIfZero(label string) string
+	// ProcedureEnd restores the registers frame saved, releases it and returns the result Return left
+	ProcedureEnd(frame Frame) string
+	// Epilogue is emitted after everything else in the output
+	Epilogue() string
+	// Build assembles and links the output at sourcePath, returning the path of the executable
+	Build(sourcePath string, options BuildOptions) (string, error)
+}
+
+// ProgramBackend is a target that writes the whole program out itself, rather than having the code
+// generator put it together from the pieces a Backend gives. Implementations register themselves
+// with RegisterProgramBackend from an init function.
+type ProgramBackend interface {
+	// Build compiles the checked program, or lowered if it works from the optimised IR, into a file
+	// next to the source at sourcePath and builds whatever it needs to from that, returning the path
+	// of the result
+	Build(program *ast.Program, lowered *ir.Program, sourcePath string, options BuildOptions) (string, error)
+}
+
+// BuildOptions pick the tools Build uses, leaving a field empty picks the default for the target
+type BuildOptions struct {
+	// Assembler is builtin or nasm, the builtin one is the default where the target has it
+	Assembler string
+	// Linker is gcc, linking against the C library, or builtin, writing a static executable that
+	// carries a runtime of its own and needs nothing installed. gcc is the default
+	Linker string
+}
+
+// Check rejects the tools no backend knows, before anything is compiled
+func (o BuildOptions) Check() error {
+	if o.Assembler != "" && o.Assembler != "builtin" && o.Assembler != "nasm" {
+		return fmt.Errorf("unknown assembler %q, expected builtin or nasm", o.Assembler)
+	}
+	if o.Linker != "" && o.Linker != "builtin" && o.Linker != "gcc" {
+		return fmt.Errorf("unknown linker %q, expected builtin or gcc", o.Linker)
+	}
+	if o.Linker == "builtin" && o.Assembler == "nasm" {
+		return fmt.Errorf("the builtin linker only links what the builtin assembler makes")
+	}
+	return nil
+}
+
+// Frame is what a procedure needs room for on the stack
+type Frame struct {
+	// Locals is how many 8 byte variables the procedure keeps in memory
+	Locals int
+	// CallArgs is the most arguments passed by any call the procedure makes
+	CallArgs int
+	// Saved are the registers the procedure uses that it must give back to its caller unchanged
+	Saved []string
+}
+
+var backends = map[string]Backend{}
+var programBackends = map[string]ProgramBackend{}
+
+func RegisterBackend(name string, backend Backend) {
+	backends[name] = backend
+}
+
+func RegisterProgramBackend(name string, backend ProgramBackend) {
+	programBackends[name] = backend
+}
+
+// GetProgramBackend is the program backend called name, or nil if it is not one
+func GetProgramBackend(name string) ProgramBackend {
+	return programBackends[name]
+}
+
+func GetBackend(name string) (Backend, error) {
+	backend := backends[name]
+	if backend == nil {
+		return nil, fmt.Errorf("unknown target %q, expected one of %v", name, backendNames())
+	}
+	return backend, nil
+}
+
+func backendNames() []string {
+	names := []string{}
+	for name := range backends {
+		names = append(names, name)
+	}
+	for name := range programBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+func windowsBackend() Backend {
+	backend, _ := GetBackend("windows-amd64")
+	return backend
+}
+
+func Test_GetBackend(t *testing.T) {
+	type args struct {
+		name string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantErr      bool
+		wantContains []string
+	}{
+		{
+			"Windows",
+			args{"windows-amd64"},
+			false,
+			[]string{
+				"section .text use64",
+				"mov rcx,%1",
+				"Invoke printf,stringformat,p0",
+			},
+		},
+		{
+			"Linux",
+			args{"linux-amd64"},
+			false,
+			[]string{
+				"section .text\n",
+				"lea rdi,[rel stringformat]\nlea rsi,[rel p0]\nxor eax,eax\ncall printf\n",
+				"section .note.GNU-stack",
+			},
+		},
+		{
+			"Unknown",
+			args{"commodore-64"},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := GetBackend(tt.args.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetBackend() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if backend == nil {
+				return
+			}
+			body := GetStandardFunctionBody("printf")(backend, []string{"p0"}, []ast.Type{ast.String})
+			assembly := getAssembly(backend, body, []string{"printf"}, map[string][]byte{"p0": append([]byte("hi"), 0)})
+			for _, want := range tt.wantContains {
+				if !strings.Contains(assembly, want) {
+					t.Errorf("getAssembly() is missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func Test_BuildOptions_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		options BuildOptions
+		want    string
+	}{
+		{"Defaults", BuildOptions{}, ""},
+		{"Everything builtin", BuildOptions{Assembler: "builtin", Linker: "builtin"}, ""},
+		{"nasm and gcc", BuildOptions{Assembler: "nasm", Linker: "gcc"}, ""},
+		{"Unknown assembler", BuildOptions{Assembler: "yasm"}, `unknown assembler "yasm", expected builtin or nasm`},
+		{"Unknown linker", BuildOptions{Linker: "lld"}, `unknown linker "lld", expected builtin or gcc`},
+		{"Builtin linker after nasm", BuildOptions{Assembler: "nasm", Linker: "builtin"}, "the builtin linker only links what the builtin assembler makes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if err := tt.options.Check(); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_ProgramBackend_Build_options checks the backends turn down the tools they cannot use, rather
+// than building without them
+func Test_ProgramBackend_Build_options(t *testing.T) {
+	type args struct {
+		target  string
+		options BuildOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"C with nasm", args{"c", BuildOptions{Assembler: "nasm"}}, "c is only compiled and linked by cc"},
+		{"C with the builtin linker", args{"c", BuildOptions{Linker: "builtin"}}, "c is only compiled and linked by cc"},
+		{"LLVM with nasm", args{"llvm", BuildOptions{Assembler: "nasm"}}, "llvm only writes a .ll file, which is not assembled or linked"},
+		{"wasm with gcc", args{"wasm", BuildOptions{Linker: "gcc"}}, "wasm only writes a .wasm file, which is not assembled or linked"},
+		{"Bytecode with the builtin linker", args{"bytecode", BuildOptions{Linker: "builtin"}}, "bytecode only writes a .gryc file, which is not assembled or linked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourcePath := filepath.Join(t.TempDir(), "program.gry")
+			_, err := GetProgramBackend(tt.args.target).Build(nil, nil, sourcePath, tt.args.options)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Build() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
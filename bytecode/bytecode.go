@@ -0,0 +1,151 @@
+// Package bytecode holds the compact stack based form a GaryLang program can be compiled into, a
+// virtual machine running it and the .gryc files it is kept in. Running it needs neither an
+// assembler nor a C compiler, and the same file runs anywhere Go does.
+package bytecode
+
+import (
+	"encoding/binary"
+)
+
+// Op is the first byte of every instruction, its operands follow it
+type Op byte
+
+const (
+	// Number pushes the 8 byte number after it
+	Number Op = iota
+	// Address pushes the address of the constant numbered by its operand
+	Address
+	// Load pushes the local in the slot numbered by its operand
+	Load
+	// Store pops into the local in the slot numbered by its operand
+	Store
+	// Add to GreaterEqual pop the right and then the left value and push what they combine into,
+	// comparisons give 1 or 0
+	Add
+	Subtract
+	Multiply
+	Divide
+	Modulo
+	Equal
+	NotEqual
+	Less
+	LessEqual
+	Greater
+	GreaterEqual
+	// Negate replaces the top of the stack with minus it
+	Negate
+	// Jump continues at the offset in the chunk given by its operand
+	Jump
+	// JumpIfZero pops a value and continues at the offset given by its operand if it is zero
+	JumpIfZero
+	// Call pops the arguments of the chunk numbered by its operand, runs it and pushes what it gives back
+	Call
+	// CallExtern pops as many arguments as its second operand says, passes them to the extern
+	// numbered by its first operand and pushes what it gives back
+	CallExtern
+	// Pop drops the top of the stack
+	Pop
+	// Return leaves the chunk giving back the value it pops
+	Return
+)
+
+// operandSizes are how many bytes each operand of an instruction takes, numbers take 8 and
+// everything else 4. Operands are little endian
+var operandSizes = map[Op][]int{
+	Number:     {8},
+	Address:    {4},
+	Load:       {4},
+	Store:      {4},
+	Jump:       {4},
+	JumpIfZero: {4},
+	Call:       {4},
+	CallExtern: {4, 4},
+}
+
+// Constant is an entry of the constant pool, the bytes of a string with its terminating 0
+type Constant struct {
+	Name  string
+	Bytes []byte
+}
+
+// Position is where in the source the instruction at Offset of a chunk was written
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Chunk is the code of one halfleft. Its parameters are the first of its locals and hold the
+// arguments when it starts
+type Chunk struct {
+	Name   string
+	Params int
+	Locals int
+	Code   []byte
+	// Positions are kept for the instructions that can fail while running, the calls and divisions,
+	// in the order of their offsets
+	Positions []Position
+}
+
+// Program is every chunk that can run, the one it starts at first, the constants they use and the
+// functions they call that the virtual machine provides
+type Program struct {
+	// File is the source the program was compiled from
+	File      string
+	Constants []Constant
+	Externs   []string
+	Chunks    []*Chunk
+}
+
+// Emit adds an instruction to the end of the chunk and gives the offset it starts at
+func (c *Chunk) Emit(op Op, operands ...int64) int {
+	offset := len(c.Code)
+	c.Code = append(c.Code, byte(op))
+	for index, size := range operandSizes[op] {
+		if size == 8 {
+			c.Code = binary.LittleEndian.AppendUint64(c.Code, uint64(operands[index]))
+		} else {
+			c.Code = binary.LittleEndian.AppendUint32(c.Code, uint32(operands[index]))
+		}
+	}
+	return offset
+}
+
+// SetJump points the jump at offset at target
+func (c *Chunk) SetJump(offset int, target int) {
+	binary.LittleEndian.PutUint32(c.Code[offset+1:], uint32(target))
+}
+
+// Mark records that the instruction at offset was written at line and column of the source
+func (c *Chunk) Mark(offset int, line int, column int) {
+	c.Positions = append(c.Positions, Position{offset, line, column})
+}
+
+// position is where the instruction at offset was written, if it was kept
+func (c *Chunk) position(offset int) (Position, bool) {
+	for _, position := range c.Positions {
+		if position.Offset == offset {
+			return position, true
+		}
+	}
+	return Position{}, false
+}
+
+// instruction reads the instruction at offset, giving its operands and the offset of the next one.
+// ok is false when the chunk ends before its operands do
+func (c *Chunk) instruction(offset int) (op Op, operands []int64, next int, ok bool) {
+	op = Op(c.Code[offset])
+	next = offset + 1
+	for _, size := range operandSizes[op] {
+		if next+size > len(c.Code) {
+			return op, nil, next, false
+		}
+		if size == 8 {
+			operands = append(operands, int64(binary.LittleEndian.Uint64(c.Code[next:])))
+		} else {
+			operands = append(operands, int64(binary.LittleEndian.Uint32(c.Code[next:])))
+		}
+		next += size
+	}
+	return op, operands, next, true
+}
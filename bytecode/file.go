@@ -0,0 +1,133 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// magic starts every .gryc file, the version of the layout follows it
+const (
+	magic   = "GRYC"
+	version = 1
+)
+
+// Encode lays the program out as a .gryc file. After the magic and version come the source file,
+// the constants, the externs and the chunks. Counts and numbers are unsigned varints, strings and
+// byte runs are their length followed by their bytes
+func (p *Program) Encode() []byte {
+	e := &encoder{bytes: []byte(magic)}
+	e.number(version)
+	e.text(p.File)
+	e.number(len(p.Constants))
+	for _, constant := range p.Constants {
+		e.text(constant.Name)
+		e.text(string(constant.Bytes))
+	}
+	e.number(len(p.Externs))
+	for _, extern := range p.Externs {
+		e.text(extern)
+	}
+	e.number(len(p.Chunks))
+	for _, chunk := range p.Chunks {
+		e.text(chunk.Name)
+		e.number(chunk.Params)
+		e.number(chunk.Locals)
+		e.text(string(chunk.Code))
+		e.number(len(chunk.Positions))
+		for _, position := range chunk.Positions {
+			e.number(position.Offset)
+			e.number(position.Line)
+			e.number(position.Column)
+		}
+	}
+	return e.bytes
+}
+
+// Decode reads a program Encode laid out and verifies it can be run
+func Decode(data []byte) (*Program, error) {
+	if len(data) < len(magic) || string(data[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not a gryc file")
+	}
+	d := &decoder{bytes: data[len(magic):]}
+	if fileVersion := d.number(); d.err == nil && fileVersion != version {
+		return nil, fmt.Errorf("gryc version %d cannot be read, only version %d", fileVersion, version)
+	}
+	p := &Program{File: d.text()}
+	for count := d.count(); count > 0; count-- {
+		p.Constants = append(p.Constants, Constant{Name: d.text(), Bytes: []byte(d.text())})
+	}
+	for count := d.count(); count > 0; count-- {
+		p.Externs = append(p.Externs, d.text())
+	}
+	for count := d.count(); count > 0; count-- {
+		chunk := &Chunk{Name: d.text(), Params: d.number(), Locals: d.number(), Code: []byte(d.text())}
+		for positions := d.count(); positions > 0; positions-- {
+			chunk.Positions = append(chunk.Positions, Position{d.number(), d.number(), d.number()})
+		}
+		p.Chunks = append(p.Chunks, chunk)
+	}
+	if d.err == nil && len(d.bytes) > 0 {
+		d.err = fmt.Errorf("there are %d bytes after the last chunk", len(d.bytes))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := p.Verify(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type encoder struct {
+	bytes []byte
+}
+
+func (e *encoder) number(value int) {
+	e.bytes = binary.AppendUvarint(e.bytes, uint64(value))
+}
+
+func (e *encoder) text(value string) {
+	e.number(len(value))
+	e.bytes = append(e.bytes, value...)
+}
+
+// decoder reads what encoder wrote, after the first thing it cannot read it gives back zeros and
+// keeps the error
+type decoder struct {
+	bytes []byte
+	err   error
+}
+
+func (d *decoder) number() int {
+	if d.err != nil {
+		return 0
+	}
+	value, size := binary.Uvarint(d.bytes)
+	if size <= 0 || value > 1<<31 {
+		d.err = fmt.Errorf("the file is cut short or has a number too big to be one")
+		return 0
+	}
+	d.bytes = d.bytes[size:]
+	return int(value)
+}
+
+// count is how many of something follow, each one takes at least a byte so a count bigger than
+// what is left is cut short rather than a reason to make room for it
+func (d *decoder) count() int {
+	count := d.number()
+	if count > len(d.bytes) {
+		d.err = fmt.Errorf("the file is cut short")
+		return 0
+	}
+	return count
+}
+
+func (d *decoder) text() string {
+	length := d.count()
+	if d.err != nil {
+		return ""
+	}
+	value := string(d.bytes[:length])
+	d.bytes = d.bytes[length:]
+	return value
+}
@@ -0,0 +1,118 @@
+package bytecode
+
+import (
+	"reflect"
+	"testing"
+)
+
+// double is a program giving back twice 21 from a chunk of its own
+func double() *Program {
+	start := &Chunk{Name: "start"}
+	start.Emit(Number, 21)
+	start.Mark(start.Emit(Call, 1), 2, 5)
+	start.Emit(Return)
+	twice := &Chunk{Name: "twice", Params: 1, Locals: 2}
+	twice.Emit(Load, 0)
+	twice.Emit(Number, 2)
+	twice.Emit(Multiply)
+	twice.Emit(Store, 1)
+	twice.Emit(Load, 1)
+	twice.Emit(Return)
+	return &Program{
+		File:      "double.gry",
+		Constants: []Constant{{"p0", []byte("unused\x00")}},
+		Externs:   []string{"printf"},
+		Chunks:    []*Chunk{start, twice},
+	}
+}
+
+func Test_Decode(t *testing.T) {
+	program := double()
+	got, err := Decode(program.Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, program) {
+		t.Errorf("Decode() = %v, want %v", got, program)
+	}
+}
+
+func Test_Decode_errors(t *testing.T) {
+	encoded := double().Encode()
+	growing := double()
+	growing.Chunks[1] = &Chunk{Name: "twice", Params: 1, Locals: 2}
+	growing.Chunks[1].Emit(Load, 0)
+	growing.Chunks[1].Emit(Jump, 0)
+	type args struct {
+		data []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Not bytecode", args{[]byte("\x7fELF")}, "not a gryc file"},
+		{"Later version", args{[]byte("GRYC\x02")}, "gryc version 2 cannot be read, only version 1"},
+		{"Cut short", args{encoded[:len(encoded)-3]}, "the file is cut short"},
+		{"Bytes after the end", args{append(encoded, 0)}, "there are 1 bytes after the last chunk"},
+		{"Code that does not verify", args{growing.Encode()}, "twice: at 0: reached with 0 values and with 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.args.data)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Program_Verify(t *testing.T) {
+	chunk := func(params int, locals int, emit func(c *Chunk)) *Chunk {
+		c := &Chunk{Name: "c", Params: params, Locals: locals}
+		emit(c)
+		return c
+	}
+	type args struct {
+		chunks []*Chunk
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"No chunks", args{nil}, "there is no chunk to start at"},
+		{"Starting with params", args{[]*Chunk{chunk(1, 1, func(c *Chunk) {})}}, "c is started with no arguments but takes 1"},
+		{"Params outside the locals", args{[]*Chunk{chunk(0, 0, func(c *Chunk) {
+			c.Emit(Number, 0)
+			c.Emit(Return)
+		}), chunk(2, 1, func(c *Chunk) {})}}, "c: 2 params do not fit in 1 locals"},
+		{"Unknown instruction", args{[]*Chunk{{Name: "s", Code: []byte{200}}}}, "s: at 0: there is no instruction 200"},
+		{"Cut short", args{[]*Chunk{{Name: "s", Code: []byte{byte(Number), 1, 2}}}}, "s: at 0: number is cut short"},
+		{"Unknown local", args{[]*Chunk{chunk(0, 1, func(c *Chunk) { c.Emit(Load, 1) })}}, "c: at 0: there is no local 1"},
+		{"Unknown constant", args{[]*Chunk{chunk(0, 0, func(c *Chunk) { c.Emit(Address, 0) })}}, "c: at 0: there is no constant 0"},
+		{"Unknown extern", args{[]*Chunk{chunk(0, 0, func(c *Chunk) { c.Emit(CallExtern, 0, 0) })}}, "c: at 0: there is no extern 0"},
+		{"Unknown chunk", args{[]*Chunk{chunk(0, 0, func(c *Chunk) { c.Emit(Call, 1) })}}, "c: at 0: there is no chunk 1"},
+		{"Jump into an instruction", args{[]*Chunk{chunk(0, 0, func(c *Chunk) {
+			c.Emit(Number, 0)
+			c.Emit(Jump, 1)
+		})}}, "c: at 1: there is no instruction starting there to jump to"},
+		{"Running off the end", args{[]*Chunk{chunk(0, 0, func(c *Chunk) { c.Emit(Number, 0) })}}, "c: the code runs off the end"},
+		{"Too few values", args{[]*Chunk{chunk(0, 0, func(c *Chunk) {
+			c.Emit(Number, 0)
+			c.Emit(Add)
+		})}}, "c: at 9: add needs 2 values but there are 1"},
+		{"Loop growing the stack", args{[]*Chunk{chunk(0, 0, func(c *Chunk) {
+			c.Emit(Number, 0)
+			c.Emit(Jump, 0)
+		})}}, "c: at 0: reached with 0 values and with 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Program{Chunks: tt.args.chunks}).Verify()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package bytecode
+
+import (
+	"strconv"
+	"strings"
+)
+
+var opNames = map[Op]string{
+	Number:       "number",
+	Address:      "address",
+	Load:         "load",
+	Store:        "store",
+	Add:          "add",
+	Subtract:     "sub",
+	Multiply:     "mul",
+	Divide:       "div",
+	Modulo:       "mod",
+	Equal:        "eq",
+	NotEqual:     "ne",
+	Less:         "lt",
+	LessEqual:    "le",
+	Greater:      "gt",
+	GreaterEqual: "ge",
+	Negate:       "neg",
+	Jump:         "jump",
+	JumpIfZero:   "jumpifzero",
+	Call:         "call",
+	CallExtern:   "callextern",
+	Pop:          "pop",
+	Return:       "return",
+}
+
+func (o Op) String() string {
+	if name, known := opNames[o]; known {
+		return name
+	}
+	return "op" + strconv.Itoa(int(o))
+}
+
+// String lists the constants and externs of the program and then the code of each chunk, an
+// instruction to a line after its offset
+func (p *Program) String() string {
+	content := ""
+	for index, constant := range p.Constants {
+		content += "const" + strconv.Itoa(index) + " " + constant.Name + " = " + strconv.Quote(string(constant.Bytes)) + "\n"
+	}
+	for index, extern := range p.Externs {
+		content += "extern" + strconv.Itoa(index) + " " + extern + "\n"
+	}
+	for _, chunk := range p.Chunks {
+		content += "\nchunk " + chunk.Name + " params " + strconv.Itoa(chunk.Params) + " locals " + strconv.Itoa(chunk.Locals) + "\n"
+		for offset := 0; offset < len(chunk.Code); {
+			op, operands, next, ok := chunk.instruction(offset)
+			content += "\t" + strconv.Itoa(offset) + ": " + p.instructionString(op, operands, ok) + "\n"
+			offset = next
+		}
+	}
+	return content
+}
+
+// instructionString writes the operands naming constants, chunks and externs with their names
+func (p *Program) instructionString(op Op, operands []int64, ok bool) string {
+	if !ok {
+		return op.String() + " cut short"
+	}
+	args := []string{}
+	for index, operand := range operands {
+		arg := strconv.FormatInt(operand, 10)
+		switch {
+		case op == Address && operand < int64(len(p.Constants)):
+			arg = p.Constants[operand].Name
+		case op == Call && operand < int64(len(p.Chunks)):
+			arg = p.Chunks[operand].Name
+		case op == CallExtern && index == 0 && operand < int64(len(p.Externs)):
+			arg = p.Externs[operand]
+		case op == Load || op == Store:
+			arg = "s" + arg
+		}
+		args = append(args, arg)
+	}
+	return strings.TrimSpace(op.String() + " " + strings.Join(args, ", "))
+}
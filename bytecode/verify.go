@@ -0,0 +1,143 @@
+package bytecode
+
+import (
+	"fmt"
+)
+
+// stackEffects are how many values an instruction needs on the stack and how many it leaves in
+// their place, the calls work theirs out from their operands
+var stackEffects = map[Op][2]int{
+	Number:       {0, 1},
+	Address:      {0, 1},
+	Load:         {0, 1},
+	Store:        {1, 0},
+	Add:          {2, 1},
+	Subtract:     {2, 1},
+	Multiply:     {2, 1},
+	Divide:       {2, 1},
+	Modulo:       {2, 1},
+	Equal:        {2, 1},
+	NotEqual:     {2, 1},
+	Less:         {2, 1},
+	LessEqual:    {2, 1},
+	Greater:      {2, 1},
+	GreaterEqual: {2, 1},
+	Negate:       {1, 1},
+	Jump:         {0, 0},
+	JumpIfZero:   {1, 0},
+	Pop:          {1, 0},
+	Return:       {1, 0},
+}
+
+// Verify checks the program can be run without looking at anything that is not there: every
+// instruction is known and whole, every operand names something that exists, jumps land on
+// instructions, no chunk runs off its end and every instruction finds the values it needs on the
+// stack, however it is reached
+func (p *Program) Verify() error {
+	if len(p.Chunks) == 0 {
+		return fmt.Errorf("there is no chunk to start at")
+	}
+	if p.Chunks[0].Params != 0 {
+		return fmt.Errorf("%s is started with no arguments but takes %d", p.Chunks[0].Name, p.Chunks[0].Params)
+	}
+	for _, chunk := range p.Chunks {
+		if err := p.verifyChunk(chunk); err != nil {
+			return fmt.Errorf("%s: %v", chunk.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *Program) verifyChunk(chunk *Chunk) error {
+	if chunk.Params < 0 || chunk.Params > chunk.Locals {
+		return fmt.Errorf("%d params do not fit in %d locals", chunk.Params, chunk.Locals)
+	}
+	starts := map[int]bool{}
+	for offset := 0; offset < len(chunk.Code); {
+		op, operands, next, ok := chunk.instruction(offset)
+		if op > Return {
+			return fmt.Errorf("at %d: there is no instruction %d", offset, op)
+		}
+		if !ok {
+			return fmt.Errorf("at %d: %s is cut short", offset, op)
+		}
+		if err := p.verifyOperands(chunk, op, operands); err != nil {
+			return fmt.Errorf("at %d: %v", offset, err)
+		}
+		starts[offset] = true
+		offset = next
+	}
+
+	// depths are how many values are on the stack above the locals when each instruction starts
+	depths := map[int]int{}
+	pending := []int{0}
+	depths[0] = 0
+	for len(pending) > 0 {
+		offset := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if offset >= len(chunk.Code) {
+			return fmt.Errorf("the code runs off the end")
+		}
+		if !starts[offset] {
+			return fmt.Errorf("at %d: there is no instruction starting there to jump to", offset)
+		}
+		op, operands, next, _ := chunk.instruction(offset)
+		effect := stackEffects[op]
+		switch op {
+		case Call:
+			effect = [2]int{p.Chunks[operands[0]].Params, 1}
+		case CallExtern:
+			effect = [2]int{int(operands[1]), 1}
+		}
+		if depths[offset] < effect[0] {
+			return fmt.Errorf("at %d: %s needs %d values but there are %d", offset, op, effect[0], depths[offset])
+		}
+		depth := depths[offset] - effect[0] + effect[1]
+		successors := []int{next}
+		switch op {
+		case Jump:
+			successors = []int{int(operands[0])}
+		case JumpIfZero:
+			successors = append(successors, int(operands[0]))
+		case Return:
+			successors = nil
+		}
+		for _, successor := range successors {
+			if known, seen := depths[successor]; seen {
+				if known != depth {
+					return fmt.Errorf("at %d: reached with %d values and with %d", successor, known, depth)
+				}
+				continue
+			}
+			depths[successor] = depth
+			pending = append(pending, successor)
+		}
+	}
+	return nil
+}
+
+func (p *Program) verifyOperands(chunk *Chunk, op Op, operands []int64) error {
+	switch op {
+	case Address:
+		if operands[0] >= int64(len(p.Constants)) {
+			return fmt.Errorf("there is no constant %d", operands[0])
+		}
+	case Load, Store:
+		if operands[0] >= int64(chunk.Locals) {
+			return fmt.Errorf("there is no local %d", operands[0])
+		}
+	case Jump, JumpIfZero:
+		if operands[0] >= int64(len(chunk.Code)) {
+			return fmt.Errorf("jump to %d is past the end", operands[0])
+		}
+	case Call:
+		if operands[0] >= int64(len(p.Chunks)) {
+			return fmt.Errorf("there is no chunk %d", operands[0])
+		}
+	case CallExtern:
+		if operands[0] >= int64(len(p.Externs)) {
+			return fmt.Errorf("there is no extern %d", operands[0])
+		}
+	}
+	return nil
+}
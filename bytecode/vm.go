@@ -0,0 +1,262 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/Jordank321/GaryLang/format"
+)
+
+// maxFrames is how deep calls may go before the program is stopped, where a compiled program would
+// run out of stack
+const maxFrames = 1 << 20
+
+// RuntimeError is something the program did that stopped it, at the instruction that did it
+type RuntimeError struct {
+	File     string
+	Position Position
+	Message  string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Position.Line, e.Position.Column, e.Message)
+}
+
+// extern is a function the virtual machine provides for programs to call
+type extern func(m *machine, args []int64) int64
+
+// externs are what the C library gives the compiled programs, only the parts they use
+var externs = map[string]extern{
+	"printf": printf,
+}
+
+// frame is a call being run, its locals are on the stack from base
+type frame struct {
+	chunk *Chunk
+	ip    int
+	base  int
+}
+
+// machine runs one program. Every value is 64 bits, strings being the address of their first byte in
+// memory, which holds the constants one after the other
+type machine struct {
+	program   *Program
+	out       io.Writer
+	memory    []byte
+	addresses []int64
+	externs   []extern
+	stack     []int64
+	frames    []frame
+	// current is the frame being run, it goes on frames while it calls another
+	current frame
+	// at is where the instruction being run starts
+	at int
+}
+
+// Run verifies the program and runs it from its first chunk, writing what it prints to out, and gives
+// back what that chunk gives back
+func Run(program *Program, out io.Writer) (result int64, err error) {
+	if err := program.Verify(); err != nil {
+		return 0, err
+	}
+	m := &machine{program: program, out: out}
+	for _, constant := range program.Constants {
+		m.addresses = append(m.addresses, int64(len(m.memory)))
+		m.memory = append(m.memory, constant.Bytes...)
+	}
+	for _, name := range program.Externs {
+		function, found := externs[name]
+		if !found {
+			return 0, fmt.Errorf("%s is called but the virtual machine does not have it", name)
+		}
+		m.externs = append(m.externs, function)
+	}
+	defer func() {
+		recovered := recover()
+		if runtimeError, isRuntimeError := recovered.(*RuntimeError); isRuntimeError {
+			err = runtimeError
+		} else if recovered != nil {
+			panic(recovered)
+		}
+	}()
+	return m.run(), nil
+}
+
+// fail stops the program with message, at the instruction being run
+func (m *machine) fail(message string) {
+	position, _ := m.current.chunk.position(m.at)
+	panic(&RuntimeError{m.program.File, position, message})
+}
+
+func (m *machine) push(value int64) {
+	m.stack = append(m.stack, value)
+}
+
+func (m *machine) pop() int64 {
+	value := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return value
+}
+
+// enter starts chunk with its arguments already on the stack, making room for the rest of its locals
+func (m *machine) enter(chunk *Chunk) {
+	if len(m.frames) == maxFrames {
+		m.fail("calls go too deep")
+	}
+	base := len(m.stack) - chunk.Params
+	for local := chunk.Params; local < chunk.Locals; local++ {
+		m.push(0)
+	}
+	m.current = frame{chunk: chunk, base: base}
+}
+
+func (m *machine) run() int64 {
+	m.enter(m.program.Chunks[0])
+	for {
+		code := m.current.chunk.Code
+		ip := m.current.ip
+		m.at = ip
+		op := Op(code[ip])
+		ip++
+		operand := int64(0)
+		if sizes := operandSizes[op]; len(sizes) > 0 {
+			if sizes[0] == 8 {
+				operand = int64(binary.LittleEndian.Uint64(code[ip:]))
+			} else {
+				operand = int64(binary.LittleEndian.Uint32(code[ip:]))
+			}
+			ip += sizes[0]
+		}
+		m.current.ip = ip
+
+		switch op {
+		case Number:
+			m.push(operand)
+		case Address:
+			m.push(m.addresses[operand])
+		case Load:
+			m.push(m.stack[m.current.base+int(operand)])
+		case Store:
+			m.stack[m.current.base+int(operand)] = m.pop()
+		case Negate:
+			m.stack[len(m.stack)-1] = -m.stack[len(m.stack)-1]
+		case Jump:
+			m.current.ip = int(operand)
+		case JumpIfZero:
+			if m.pop() == 0 {
+				m.current.ip = int(operand)
+			}
+		case Call:
+			m.frames = append(m.frames, m.current)
+			m.enter(m.program.Chunks[operand])
+		case CallExtern:
+			count := int(binary.LittleEndian.Uint32(code[ip:]))
+			m.current.ip += 4
+			args := m.stack[len(m.stack)-count:]
+			result := m.externs[operand](m, args)
+			m.stack = m.stack[:len(m.stack)-count]
+			m.push(result)
+		case Pop:
+			m.pop()
+		case Return:
+			result := m.pop()
+			m.stack = m.stack[:m.current.base]
+			if len(m.frames) == 0 {
+				return result
+			}
+			m.current = m.frames[len(m.frames)-1]
+			m.frames = m.frames[:len(m.frames)-1]
+			m.push(result)
+		default:
+			right := m.pop()
+			left := m.pop()
+			m.push(m.binary(op, left, right))
+		}
+	}
+}
+
+// binary works out op the way the machine instructions do, wrapping around on overflow. Dividing by
+// zero, or the smallest number by -1, faults a compiled program and stops this one
+func (m *machine) binary(op Op, left int64, right int64) int64 {
+	switch op {
+	case Add:
+		return left + right
+	case Subtract:
+		return left - right
+	case Multiply:
+		return left * right
+	case Divide, Modulo:
+		if right == 0 {
+			m.fail("division by zero")
+		}
+		if left == math.MinInt64 && right == -1 {
+			m.fail("division overflows")
+		}
+		if op == Divide {
+			return left / right
+		}
+		return left % right
+	}
+	result := false
+	switch op {
+	case Equal:
+		result = left == right
+	case NotEqual:
+		result = left != right
+	case Less:
+		result = left < right
+	case LessEqual:
+		result = left <= right
+	case Greater:
+		result = left > right
+	case GreaterEqual:
+		result = left >= right
+	}
+	if result {
+		return 1
+	}
+	return 0
+}
+
+// text is the bytes at address up to the 0 ending them
+func (m *machine) text(address int64) []byte {
+	if address < 0 || address > int64(len(m.memory)) {
+		m.fail("there is no string at " + strconv.FormatInt(address, 10))
+	}
+	end := address
+	for end < int64(len(m.memory)) && m.memory[end] != 0 {
+		end++
+	}
+	return m.memory[address:end]
+}
+
+// printf writes the string at args[0] formatted with the rest of args to out like the C function
+// does, see format.Printf, and gives back the number of bytes written
+func printf(m *machine, args []int64) int64 {
+	if len(args) == 0 {
+		m.fail("printf is called without a format")
+	}
+	formatArgs := make([]format.Arg, len(args)-1)
+	for index, arg := range args[1:] {
+		formatArgs[index] = printfArg{m, arg}
+	}
+	count, _ := m.out.Write(format.Printf(m.text(args[0]), formatArgs))
+	return int64(count)
+}
+
+// printfArg is a value passed to printf, which %s reads as the address of a string
+type printfArg struct {
+	m     *machine
+	value int64
+}
+
+func (a printfArg) Number() int64 {
+	return a.value
+}
+
+func (a printfArg) Text() []byte {
+	return a.m.text(a.value)
+}
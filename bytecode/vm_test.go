@@ -0,0 +1,87 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+)
+
+// printing is a program passing the format and then args to printf, giving back what printf does.
+// The constant hi is first so its address is 0
+func printing(format string, args ...int64) *Program {
+	start := &Chunk{Name: "start"}
+	start.Emit(Address, 1)
+	for _, arg := range args {
+		start.Emit(Number, arg)
+	}
+	start.Emit(CallExtern, 0, int64(1+len(args)))
+	start.Emit(Return)
+	return &Program{
+		Constants: []Constant{{"hi", []byte("hi\x00")}, {"format", []byte(format + "\x00")}},
+		Externs:   []string{"printf"},
+		Chunks:    []*Chunk{start},
+	}
+}
+
+func Test_Run(t *testing.T) {
+	type args struct {
+		program *Program
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantResult int64
+	}{
+		{"Calls and locals", args{double()}, "", 42},
+		{"Numbers", args{printing("%lld %ld %d %i", -1, 2, 3, 4)}, "-1 2 3 4", 8},
+		{"Strings and characters", args{printing("%s%c", 0, '!')}, "hi!", 3},
+		{"Percent", args{printing("100%% 100%")}, "100% 100%", 9},
+		{"Unknown conversion", args{printing("%q %l%")}, "%q %l%", 6},
+		{"Missing argument", args{printing("%d")}, "%d", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			result, err := Run(tt.args.program, out)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if out.String() != tt.want || result != tt.wantResult {
+				t.Errorf("Run() printed %q and gave back %d, want %q and %d", out.String(), result, tt.want, tt.wantResult)
+			}
+		})
+	}
+}
+
+func Test_Run_errors(t *testing.T) {
+	dividing := double()
+	dividing.Chunks[1] = &Chunk{Name: "twice", Params: 1, Locals: 1}
+	dividing.Chunks[1].Emit(Number, 1)
+	dividing.Chunks[1].Emit(Load, 0)
+	dividing.Chunks[1].Emit(Number, 21)
+	dividing.Chunks[1].Emit(Subtract)
+	dividing.Chunks[1].Mark(dividing.Chunks[1].Emit(Divide), 3, 7)
+	dividing.Chunks[1].Emit(Return)
+	unknownExtern := printing("")
+	unknownExtern.Externs[0] = "puts"
+	type args struct {
+		program *Program
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Dividing by zero", args{dividing}, "double.gry:3:7: division by zero"},
+		{"Printing a number as a string", args{printing("%s", 1000)}, ":0:0: there is no string at 1000"},
+		{"An extern the machine does not have", args{unknownExtern}, "puts is called but the virtual machine does not have it"},
+		{"Code that does not verify", args{&Program{}}, "there is no chunk to start at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Run(tt.args.program, &strings.Builder{}); err == nil || err.Error() != tt.want {
+				t.Errorf("Run() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/bytecode"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+var bytecodeOps = map[ir.Op]bytecode.Op{
+	ir.Add:          bytecode.Add,
+	ir.Subtract:     bytecode.Subtract,
+	ir.Multiply:     bytecode.Multiply,
+	ir.Divide:       bytecode.Divide,
+	ir.Modulo:       bytecode.Modulo,
+	ir.Equal:        bytecode.Equal,
+	ir.NotEqual:     bytecode.NotEqual,
+	ir.Less:         bytecode.Less,
+	ir.LessEqual:    bytecode.LessEqual,
+	ir.Greater:      bytecode.Greater,
+	ir.GreaterEqual: bytecode.GreaterEqual,
+}
+
+func init() {
+	RegisterProgramBackend("bytecode", bytecodeBackend{})
+}
+
+// bytecodeBackend writes the optimised IR of the program as a .gryc file for the virtual machine to run
+type bytecodeBackend struct{}
+
+func (bytecodeBackend) Build(program *ast.Program, lowered *ir.Program, sourcePath string, options BuildOptions) (string, error) {
+	if options.Assembler != "" || options.Linker != "" {
+		return "", fmt.Errorf("bytecode only writes a .gryc file, which is not assembled or linked")
+	}
+	outputPath := strings.TrimSuffix(sourcePath, ".gry") + ".gryc"
+	return outputPath, ioutil.WriteFile(outputPath, compileBytecode(program.Pos().File, lowered).Encode(), 0644)
+}
+
+// runBytecodeFile runs the .gryc file at filePath in the virtual machine
+func runBytecodeFile(filePath string, out io.Writer) (int64, error) {
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	program, err := bytecode.Decode(fileBytes)
+	if err != nil {
+		return 0, err
+	}
+	return bytecode.Run(program, out)
+}
+
+// bytecodeCompiler turns the procedures of the IR into a chunk each
+type bytecodeCompiler struct {
+	program *bytecode.Program
+	ir      *ir.Program
+	chunks  map[string]int
+	chunk   *bytecode.Chunk
+	stackEmitter
+	// current is the instruction being compiled, the bytecode for it is marked with its position
+	current *ir.Instruction
+}
+
+// blockJump is a jump waiting for the offset of the block it goes to
+type blockJump struct {
+	offset int
+	target *ir.Block
+}
+
+// compileBytecode compiles every procedure of program, thisisthepie first so it is the chunk the
+// program starts at. file is the source the program was compiled from
+func compileBytecode(file string, program *ir.Program) *bytecode.Program {
+	c := &bytecodeCompiler{
+		program: &bytecode.Program{File: file},
+		ir:      program,
+		chunks:  map[string]int{},
+	}
+	for index, procedure := range program.Procedures {
+		c.chunks[procedure.Name] = index
+	}
+	for _, procedure := range program.Procedures {
+		c.procedure(procedure)
+	}
+	return c.program
+}
+
+// procedure writes the blocks of procedure one after another, leaving out jumps to the block that
+// follows the one they end
+func (c *bytecodeCompiler) procedure(procedure *ir.Procedure) {
+	c.chunk = &bytecode.Chunk{Name: procedure.Name, Params: procedure.Params}
+	c.start(procedure, func(local int64) { c.chunk.Emit(bytecode.Store, local) })
+	offsets := map[*ir.Block]int{}
+	jumps := []blockJump{}
+	for index, block := range procedure.Blocks {
+		offsets[block] = len(c.chunk.Code)
+		var next *ir.Block
+		if index+1 < len(procedure.Blocks) {
+			next = procedure.Blocks[index+1]
+		}
+		for _, instruction := range block.Instructions {
+			c.current = instruction
+			switch instruction.Op {
+			case ir.Jump:
+				if instruction.Targets[0] != next {
+					jumps = append(jumps, blockJump{c.emit(bytecode.Jump, 0), instruction.Targets[0]})
+				}
+			case ir.Branch:
+				c.operand(instruction.Args[0])
+				jumps = append(jumps, blockJump{c.emit(bytecode.JumpIfZero, 0), instruction.Targets[1]})
+				if instruction.Targets[0] != next {
+					jumps = append(jumps, blockJump{c.emit(bytecode.Jump, 0), instruction.Targets[0]})
+				}
+			default:
+				c.instruction(instruction)
+			}
+		}
+		// the next block can be jumped to from elsewhere, so nothing is left on the stack for it
+		c.flush()
+	}
+	for _, jump := range jumps {
+		c.chunk.SetJump(jump.offset, offsets[jump.target])
+	}
+	c.chunk.Locals = c.localCount()
+	c.program.Chunks = append(c.program.Chunks, c.chunk)
+}
+
+func (c *bytecodeCompiler) instruction(instruction *ir.Instruction) {
+	switch instruction.Op {
+	case ir.Load:
+		c.emit(bytecode.Load, int64(instruction.Slot))
+	case ir.Store:
+		c.operand(instruction.Args[0])
+		c.emit(bytecode.Store, int64(instruction.Slot))
+		return
+	case ir.Copy:
+		c.operand(instruction.Args[0])
+	case ir.Negate:
+		c.operand(instruction.Args[0])
+		c.emit(bytecode.Negate)
+	case ir.Call:
+		if instruction.Builtin {
+			c.builtin(instruction)
+		} else {
+			for _, arg := range instruction.Args {
+				c.operand(arg)
+			}
+			c.mark(c.emit(bytecode.Call, int64(c.chunks[instruction.Callee])))
+		}
+	case ir.Return:
+		c.operand(instruction.Args[0])
+		c.emit(bytecode.Return)
+		return
+	default:
+		c.operand(instruction.Args[0])
+		c.operand(instruction.Args[1])
+		offset := c.emit(bytecodeOps[instruction.Op])
+		if instruction.Op == ir.Divide || instruction.Op == ir.Modulo {
+			c.mark(offset)
+		}
+	}
+	if !c.keep(instruction.Dest) {
+		c.emit(bytecode.Pop)
+	}
+}
+
+// emit flushes and adds an instruction to the chunk, giving its offset
+func (c *bytecodeCompiler) emit(op bytecode.Op, operands ...int64) int {
+	c.flush()
+	return c.chunk.Emit(op, operands...)
+}
+
+// operand pushes the value of operand
+func (c *bytecodeCompiler) operand(operand ir.Operand) {
+	switch operand.Kind {
+	case ir.RegisterOperand:
+		if !c.take(operand.Register) {
+			c.emit(bytecode.Load, c.local(operand.Register))
+		}
+	case ir.StringOperand:
+		c.emit(bytecode.Address, c.constant(stringConstantName(int(operand.Value)), append([]byte(c.ir.Strings[operand.Value]), 0)))
+	default:
+		c.emit(bytecode.Number, operand.Value)
+	}
+}
+
+// constant is the index of the constant called name in the pool, adding it with value if it is not there yet
+func (c *bytecodeCompiler) constant(name string, value []byte) int64 {
+	for index, constant := range c.program.Constants {
+		if constant.Name == name {
+			return int64(index)
+		}
+	}
+	c.program.Constants = append(c.program.Constants, bytecode.Constant{Name: name, Bytes: value})
+	return int64(len(c.program.Constants) - 1)
+}
+
+// extern is the index of the extern called name, adding it if it is not there yet
+func (c *bytecodeCompiler) extern(name string) int64 {
+	c.program.Externs = appendIfMissing(c.program.Externs, name)
+	for index, extern := range c.program.Externs {
+		if extern == name {
+			return int64(index)
+		}
+	}
+	return -1
+}
+
+// mark records where the instruction being compiled was written for the bytecode at offset, so it can
+// say where it failed
+func (c *bytecodeCompiler) mark(offset int) {
+	c.chunk.Mark(offset, c.current.Position.Line, c.current.Position.Column)
+}
+
+// builtin adds the constants the builtin uses to the pool and compiles its body
+func (c *bytecodeCompiler) builtin(call *ir.Instruction) {
+	name := GetStandardFunction(call.Callee).AssembledBodyName
+	constants := GetStandardFunctionConstants(name)
+	names := []string{}
+	for constName := range constants {
+		names = append(names, constName)
+	}
+	sort.Strings(names)
+	for _, constName := range names {
+		c.constant(constName, constants[constName])
+	}
+	GetStandardFunctionBytecode(name)(c, call.Args, call.Types)
+}
+
+// callExtern pushes the constants called constants and then args, and passes them all to the extern function
+func (c *bytecodeCompiler) callExtern(function string, constants []string, args []ir.Operand) {
+	for _, name := range constants {
+		c.emit(bytecode.Address, c.constant(name, nil))
+	}
+	for _, arg := range args {
+		c.operand(arg)
+	}
+	c.mark(c.emit(bytecode.CallExtern, c.extern(function), int64(len(constants)+len(args))))
+}
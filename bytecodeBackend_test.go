@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/bytecode"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+func Test_compileBytecode(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	i = 0 #
+	whilst £ i < 3 $ /
+		perchance £ i == 1 $ /
+			printthevalue £ ¬one¬ $ #
+		\ otherwise /
+			printthenumber £ half £ i $ $ #
+		\
+		i = i + 1 #
+	\
+\
+halfleft half £ n $ /
+	giveback n / 2 #
+\`
+	want := `const0 numberformat = "%lld\x00"
+const1 stringformat = "%s\x00"
+const2 p0 = "one\x00"
+extern0 printf
+
+chunk thisisthepie params 0 locals 2
+	0: number 0
+	9: store s0
+	14: load s0
+	19: number 3
+	28: lt
+	29: jumpifzero 139
+	34: load s0
+	39: number 1
+	48: eq
+	49: jumpifzero 79
+	54: address stringformat
+	59: address p0
+	64: callextern printf, 2
+	73: pop
+	74: jump 114
+	79: load s0
+	84: call half
+	89: store s1
+	94: address numberformat
+	99: load s1
+	104: callextern printf, 2
+	113: pop
+	114: load s0
+	119: number 1
+	128: add
+	129: store s0
+	134: jump 14
+	139: number 0
+	148: return
+
+chunk half params 1 locals 1
+	0: load s0
+	5: number 2
+	14: div
+	15: return
+`
+	program := compileBytecode("", lowerProgram(treeFromTokens(tokenize(source))))
+	if got := program.String(); got != want {
+		t.Errorf("compileBytecode() = %v, want %v", got, want)
+	}
+	if err := program.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+// Test_compileBytecode_run checks the virtual machine does what the interpreter does at every level,
+// running the programs from a .gryc file
+func Test_compileBytecode_run(t *testing.T) {
+	for _, tt := range interpreterPrograms {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				lowered := lowerProgram(treeFromTokens(tokenize(tt.source)))
+				ir.Optimise(lowered, level)
+				program, err := bytecode.Decode(compileBytecode("", lowered).Encode())
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				out := &strings.Builder{}
+				result, err := bytecode.Run(program, out)
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+				if out.String() != tt.want || result != tt.wantResult {
+					t.Errorf("at level %d Run() printed %q and gave back %d, want %q and %d", level, out.String(), result, tt.want, tt.wantResult)
+				}
+			})
+		}
+	}
+}
+
+func Test_compileBytecode_errors(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      string
+		wantError string
+	}{
+		{
+			"Division by zero",
+			args{`halfleft thisisthepie £ $ /
+	printthething £ ¬before¬ $ #
+	zero = 0 #
+	printthenumber £ ( 1 / zero ) $ #
+\`},
+			"before",
+			":4:23: division by zero",
+		},
+		{
+			"Recursion that never stops",
+			args{`halfleft thisisthepie £ $ /
+	forever £ 1 $ #
+\
+halfleft forever £ n $ /
+	giveback 1 + forever £ ( n + 1 ) $ #
+\`},
+			"",
+			":5:15: calls go too deep",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			lowered := lowerProgram(treeFromTokens(tokenize(tt.args.source)))
+			ir.Optimise(lowered, 0)
+			_, err := bytecode.Run(compileBytecode("", lowered), out)
+			if err == nil || err.Error() != tt.wantError || out.String() != tt.want {
+				t.Errorf("Run() printed %q with error %v, want %q and %v", out.String(), err, tt.want, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+var cOps = map[ir.Op]string{
+	ir.Add:          "+",
+	ir.Subtract:     "-",
+	ir.Multiply:     "*",
+	ir.Equal:        "==",
+	ir.NotEqual:     "!=",
+	ir.Less:         "<",
+	ir.LessEqual:    "<=",
+	ir.Greater:      ">",
+	ir.GreaterEqual: ">=",
+}
+
+// cHelpers are the C runtime the builtins map onto, each is written out once if the program uses it
+var cHelpers = map[string]string{
+	"gary_printnumber": `static long long gary_printnumber(long long number) {
+	return printf("%lld", number);
+}
+`,
+	"gary_printstring": `static long long gary_printstring(long long string) {
+	return printf("%s", (const char *)string);
+}
+`,
+	// dividing by zero or the smallest number by -1 is undefined in C, -fwrapv or not, so the program
+	// stops there as the native targets do
+	"gary_divide": `static long long gary_divide(long long left, long long right) {
+	if (right == 0 || (left == -9223372036854775807 - 1 && right == -1)) {
+		abort();
+	}
+	return left / right;
+}
+`,
+	"gary_modulo": `static long long gary_modulo(long long left, long long right) {
+	if (right == 0 || (left == -9223372036854775807 - 1 && right == -1)) {
+		abort();
+	}
+	return left % right;
+}
+`,
+}
+
+func init() {
+	RegisterProgramBackend("c", cBackend{})
+}
+
+// cBackend writes the optimised IR of the program as C and builds it with cc. Every value is a long
+// long, strings being the address of their bytes. Adding, subtracting and multiplying wrap around as
+// they do on every other target, which C only promises with -fwrapv, and dividing goes through a
+// helper that stops the program where C would leave it undefined
+type cBackend struct{}
+
+func (cBackend) Build(program *ast.Program, lowered *ir.Program, sourcePath string, options BuildOptions) (string, error) {
+	if options.Assembler != "" || options.Linker == "builtin" {
+		return "", fmt.Errorf("c is only compiled and linked by cc")
+	}
+	basePath := strings.TrimSuffix(sourcePath, ".gry")
+	cPath := basePath + ".c"
+	if err := ioutil.WriteFile(cPath, []byte(compileC(lowered)), 0644); err != nil {
+		return "", err
+	}
+	exePath := basePath
+	if runtime.GOOS == "windows" {
+		exePath += ".exe"
+	}
+	out, err := exec.Command("cc", "-std=c99", "-fwrapv", cPath, "-o", exePath).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("cc failed: %v\n%s", err, out)
+	}
+	return exePath, nil
+}
+
+// cCompiler writes the procedures of the IR as C functions
+type cCompiler struct {
+	program *ir.Program
+	// helpers are the names of the helpers the program uses, in the order they were first used
+	helpers []string
+	body    strings.Builder
+}
+
+// compileC writes every procedure of program as a C function, after the helpers they use and a
+// prototype of each so they can call each other in any order. main runs thisisthepie, exiting with
+// what it gives back
+func compileC(program *ir.Program) string {
+	c := &cCompiler{program: program}
+	prototypes := ""
+	for _, procedure := range program.Procedures {
+		prototypes += cPrototype(procedure) + ";\n"
+		c.procedure(procedure)
+	}
+
+	content := "/* compiled by garylang, build it with -fwrapv so arithmetic wraps around */\n"
+	content += "int printf(const char *format, ...);\n"
+	content += "void abort(void);\n\n"
+	for _, helper := range c.helpers {
+		content += cHelpers[helper] + "\n"
+	}
+	content += prototypes + "\n"
+	content += c.body.String()
+	content += "int main(void) {\n\treturn (int)" + cProcedureName("thisisthepie") + "();\n}\n"
+	return content
+}
+
+// helper is the name of a helper, which is written out with the program
+func (c *cCompiler) helper(name string) string {
+	c.helpers = appendIfMissing(c.helpers, name)
+	return name
+}
+
+func (c *cCompiler) line(format string, args ...interface{}) {
+	fmt.Fprintf(&c.body, format+"\n", args...)
+}
+
+// procedure writes procedure as a function whose parameters are its first slots, the rest of them
+// starting at 0. Each block is a label, and jumps to the block after the one they end are left out
+func (c *cCompiler) procedure(procedure *ir.Procedure) {
+	c.line("%s {", cPrototype(procedure))
+	if procedure.Slots > procedure.Params {
+		slots := []string{}
+		for slot := procedure.Params; slot < procedure.Slots; slot++ {
+			slots = append(slots, "s"+strconv.Itoa(slot)+" = 0")
+		}
+		c.line("\tlong long %s;", strings.Join(slots, ", "))
+	}
+	if procedure.Registers > 0 {
+		registers := []string{}
+		for register := 0; register < procedure.Registers; register++ {
+			registers = append(registers, "r"+strconv.Itoa(register))
+		}
+		c.line("\tlong long %s;", strings.Join(registers, ", "))
+	}
+	for index, block := range procedure.Blocks {
+		var next *ir.Block
+		if index+1 < len(procedure.Blocks) {
+			next = procedure.Blocks[index+1]
+		}
+		c.line("%s:", labelIdentifier(block.Name))
+		for _, instruction := range block.Instructions {
+			c.instruction(instruction, next)
+		}
+	}
+	c.line("}")
+	c.line("")
+}
+
+// instruction writes instruction as a C statement, next is the block after the one it is in
+func (c *cCompiler) instruction(instruction *ir.Instruction, next *ir.Block) {
+	args := []string{}
+	for _, arg := range instruction.Args {
+		args = append(args, c.operand(arg))
+	}
+	dest := ""
+	if instruction.Dest != ir.NoRegister {
+		dest = "r" + strconv.Itoa(int(instruction.Dest)) + " = "
+	}
+	switch instruction.Op {
+	case ir.Load:
+		c.line("\t%ss%d;", dest, instruction.Slot)
+	case ir.Store:
+		c.line("\ts%d = %s;", instruction.Slot, args[0])
+	case ir.Copy:
+		c.line("\t%s%s;", dest, args[0])
+	case ir.Negate:
+		// minus a negative number is not written as --, which C reads as a decrement
+		if strings.HasPrefix(args[0], "-") {
+			args[0] = "(" + args[0] + ")"
+		}
+		c.line("\t%s-%s;", dest, args[0])
+	case ir.Call:
+		if instruction.Builtin {
+			c.line("\t%s%s;", dest, GetStandardFunctionC(GetStandardFunction(instruction.Callee).AssembledBodyName)(c, args, instruction.Types))
+			return
+		}
+		c.line("\t%s%s(%s);", dest, cProcedureName(instruction.Callee), strings.Join(args, ", "))
+	case ir.Jump:
+		if instruction.Targets[0] != next {
+			c.line("\tgoto %s;", labelIdentifier(instruction.Targets[0].Name))
+		}
+	case ir.Branch:
+		switch {
+		case instruction.Targets[1] == next:
+			c.line("\tif (%s) goto %s;", args[0], labelIdentifier(instruction.Targets[0].Name))
+		case instruction.Targets[0] == next:
+			c.line("\tif (!%s) goto %s;", args[0], labelIdentifier(instruction.Targets[1].Name))
+		default:
+			c.line("\tif (%s) goto %s;", args[0], labelIdentifier(instruction.Targets[0].Name))
+			c.line("\tgoto %s;", labelIdentifier(instruction.Targets[1].Name))
+		}
+	case ir.Return:
+		c.line("\treturn %s;", args[0])
+	case ir.Divide:
+		c.line("\t%s%s(%s, %s);", dest, c.helper("gary_divide"), args[0], args[1])
+	case ir.Modulo:
+		c.line("\t%s%s(%s, %s);", dest, c.helper("gary_modulo"), args[0], args[1])
+	default:
+		// numbers on their own are ints in C, which are too small to work out what they make
+		if instruction.Args[0].Kind == ir.ImmediateOperand && instruction.Args[1].Kind == ir.ImmediateOperand {
+			args[0] = "(long long)" + args[0]
+		}
+		c.line("\t%s%s %s %s;", dest, args[0], cOps[instruction.Op], args[1])
+	}
+}
+
+// operand is the long long value of operand, a string being the address of its bytes
+func (c *cCompiler) operand(operand ir.Operand) string {
+	switch operand.Kind {
+	case ir.RegisterOperand:
+		return "r" + strconv.Itoa(int(operand.Register))
+	case ir.StringOperand:
+		return "(long long)" + cString(c.program.Strings[operand.Value])
+	}
+	// the smallest number has no literal, the number after its - is too big for a long long
+	if operand.Value == math.MinInt64 {
+		return "(-9223372036854775807 - 1)"
+	}
+	return strconv.FormatInt(operand.Value, 10)
+}
+
+// cPrototype is the start of the definition of procedure, its parameters are its first slots
+func cPrototype(procedure *ir.Procedure) string {
+	params := []string{}
+	for param := 0; param < procedure.Params; param++ {
+		params = append(params, "long long s"+strconv.Itoa(param))
+	}
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	return "static long long " + cProcedureName(procedure.Name) + "(" + strings.Join(params, ", ") + ")"
+}
+
+// cProcedureName is the name of the C function a procedure becomes, named like its assembly label
+// so none of them can be mistaken for a variable or something from the C library
+func cProcedureName(name string) string {
+	return "halfleft_" + labelIdentifier(name)
+}
+
+// cString is a C string literal of value. Bytes that cannot be written as they are become octal
+// escapes, which unlike hex ones end after three digits whatever follows them, and a ? after another
+// is escaped so the two are never read as the start of a trigraph
+func cString(value string) string {
+	literal := "\""
+	for index, b := range []byte(value) {
+		switch {
+		case b == '"' || b == '\\':
+			literal += "\\" + string(b)
+		case b == '\n':
+			literal += "\\n"
+		case b == '\t':
+			literal += "\\t"
+		case b == '?' && index > 0 && value[index-1] == '?':
+			literal += "\\?"
+		case b < ' ' || b > '~':
+			literal += fmt.Sprintf("\\%03o", b)
+		default:
+			literal += string(b)
+		}
+	}
+	return literal + "\""
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+func Test_compileC(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	i = 0 #
+	whilst £ i < 3 $ /
+		perchance £ i == 1 $ /
+			printthevalue £ ¬one¬ $ #
+		\ otherwise perchance £ i == 2 $ /
+			int = half £ ( 0 - i ) $ #
+			printthenumber £ int $ #
+		\ otherwise /
+			printthething £ ¬"100%"\n¬ $ #
+		\
+		i = i + 1 #
+	\
+	giveback 100000 * 100000 #
+\
+halfleft half £ n $ /
+	giveback n / 2 #
+\`
+	want := `/* compiled by garylang, build it with -fwrapv so arithmetic wraps around */
+int printf(const char *format, ...);
+void abort(void);
+
+static long long gary_printstring(long long string) {
+	return printf("%s", (const char *)string);
+}
+
+static long long gary_printnumber(long long number) {
+	return printf("%lld", number);
+}
+
+static long long gary_divide(long long left, long long right) {
+	if (right == 0 || (left == -9223372036854775807 - 1 && right == -1)) {
+		abort();
+	}
+	return left / right;
+}
+
+static long long halfleft_thisisthepie(void);
+static long long halfleft_half(long long s0);
+
+static long long halfleft_thisisthepie(void) {
+	long long s0 = 0, s1 = 0;
+	long long r0, r1, r2, r3, r4, r5, r6, r7, r8, r9, r10, r11, r12;
+entry:
+	s0 = 0;
+whilst1:
+	r0 = s0;
+	r1 = r0 < 3;
+	if (!r1) goto endwhilst1;
+body1:
+	r2 = s0;
+	r3 = r2 == 1;
+	if (!r3) goto otherwise2;
+then2:
+	gary_printstring((long long)"one");
+	goto endperchance2;
+otherwise2:
+	r4 = s0;
+	r5 = r4 == 2;
+	if (!r5) goto otherwise3;
+then3:
+	r7 = s0;
+	r8 = 0 - r7;
+	r6 = halfleft_half(r8);
+	s1 = r6;
+	r9 = s1;
+	gary_printnumber(r9);
+	goto endperchance3;
+otherwise3:
+	gary_printstring((long long)"\"100%\"\n");
+endperchance3:
+endperchance2:
+	r10 = s0;
+	r11 = r10 + 1;
+	s0 = r11;
+	goto whilst1;
+endwhilst1:
+	r12 = (long long)100000 * 100000;
+	return r12;
+}
+
+static long long halfleft_half(long long s0) {
+	long long r0, r1;
+entry:
+	r0 = s0;
+	r1 = gary_divide(r0, 2);
+	return r1;
+}
+
+int main(void) {
+	return (int)halfleft_thisisthepie();
+}
+`
+	program := lowerProgram(treeFromTokens(tokenize(source)))
+	ir.Optimise(program, 0)
+	if got := compileC(program); got != want {
+		t.Errorf("compileC() = %v, want %v", got, want)
+	}
+}
+
+func Test_cString(t *testing.T) {
+	type args struct {
+		value string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Printable", args{"Hello  world!"}, `"Hello  world!"`},
+		{"Quotes and backslashes", args{`say "\"`}, `"say \"\\\""`},
+		{"Control characters", args{"a\n\tb\x01"}, `"a\n\tb\001"`},
+		{"Bytes outside ascii are not run into the digits after them", args{"¬1"}, `"\302\2541"`},
+		{"Trigraphs", args{"??=?"}, `"?\?=?"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cString(tt.args.value); got != tt.want {
+				t.Errorf("cString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_cBackend_Build checks the programs cc builds do what the interpreter does at every level
+func Test_cBackend_Build(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("building C needs cc")
+	}
+	backend := GetProgramBackend("c")
+	for _, tt := range interpreterPrograms {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				program := treeFromTokens(tokenize(tt.source))
+				lowered := lowerProgram(program)
+				ir.Optimise(lowered, level)
+				exePath, err := backend.Build(program, lowered, filepath.Join(t.TempDir(), "program.gry"), BuildOptions{})
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				got, err := exec.Command(exePath).Output()
+				result := 0
+				if exitError, exited := err.(*exec.ExitError); exited {
+					result = exitError.ExitCode()
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want || int64(result) != tt.wantResult&0xff {
+					t.Errorf("at level %d the program printed %q and exited with %d, want %q and %d", level, got, result, tt.want, tt.wantResult&0xff)
+				}
+			})
+		}
+	}
+}
+
+// Test_cBackend_Build_errors checks the divisions a compiled program faults on stop the program cc
+// builds rather than giving something undefined
+func Test_cBackend_Build_errors(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("building C needs cc")
+	}
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"Division by zero", args{`halfleft thisisthepie £ $ /
+	zero = 0 #
+	giveback 1 / zero #
+\`}},
+		{"Smallest int divided by -1", args{`halfleft thisisthepie £ $ /
+	giveback ( 0 - 9223372036854775807 - 1 ) / ( 0 - 1 ) #
+\`}},
+	}
+	backend := GetProgramBackend("c")
+	for _, tt := range tests {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				program := treeFromTokens(tokenize(tt.args.source))
+				lowered := lowerProgram(program)
+				ir.Optimise(lowered, level)
+				exePath, err := backend.Build(program, lowered, filepath.Join(t.TempDir(), "program.gry"), BuildOptions{})
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				// a program stopped by a signal has no exit code
+				err = exec.Command(exePath).Run()
+				if exitError, exited := err.(*exec.ExitError); !exited || exitError.ExitCode() != -1 {
+					t.Errorf("at level %d the program gave %v, want it stopped by abort", level, err)
+				}
+			})
+		}
+	}
+}
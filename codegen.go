@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+// stringConstantName is the label of the string at index in the strings of the program
+func stringConstantName(index int) string {
+	return "p" + strconv.Itoa(index)
+}
+
+func getAssemblyConstantsFromIR(program *ir.Program) map[string][]byte {
+	currentConstants := map[string][]byte{}
+	for index, value := range program.Strings {
+		currentConstants[stringConstantName(index)] = append([]byte(value), 0)
+	}
+	for _, builtin := range *usedBuiltinFunctions(program, &[]string{}) {
+		for constName, value := range GetStandardFunctionConstants(builtin) {
+			currentConstants[constName] = value
+		}
+	}
+	return currentConstants
+}
+
+func usedBuiltinFunctions(program *ir.Program, used *[]string) *[]string {
+	for _, procedure := range program.Procedures {
+		for _, block := range procedure.Blocks {
+			for _, instruction := range block.Instructions {
+				if instruction.Op == ir.Call && instruction.Builtin {
+					*used = appendIfMissing(*used, GetStandardFunction(instruction.Callee).AssembledBodyName)
+				}
+			}
+		}
+	}
+	return used
+}
+
+func getAssemblyBodyFromIR(backend Backend, program *ir.Program) string {
+	currentBody := ""
+	for _, procedure := range program.Procedures {
+		currentBody += getProcedureAssembly(backend, procedure)
+	}
+	return currentBody
+}
+
+// getProcedureAssembly writes a procedure block by block. Registers live in the machine registers the
+// allocator gave them, the frame holds the slots of the procedure and then the registers that were spilled
+func getProcedureAssembly(backend Backend, procedure *ir.Procedure) string {
+	calls := &callCounter{Backend: backend}
+	onlyInRax := registersOnlyInRax(procedure)
+	emitter := procedureEmitter{
+		backend:    calls,
+		procedure:  procedure,
+		allocation: ir.Allocate(procedure, backend.Registers(), onlyInRax),
+		onlyInRax:  onlyInRax,
+	}
+	currentBody := ""
+	for index := 0; index < procedure.Params; index++ {
+		currentBody += "mov rax," + backend.Parameter(index) + "\n"
+		currentBody += "mov qword " + backend.Local(index) + ",rax\n"
+	}
+	for index, block := range procedure.Blocks {
+		emitter.next = nil
+		emitter.inRax = ir.NoRegister
+		if index+1 < len(procedure.Blocks) {
+			emitter.next = procedure.Blocks[index+1]
+		}
+		if index > 0 {
+			currentBody += backend.Label(blockLabel(block))
+		}
+		for _, instruction := range block.Instructions {
+			currentBody += emitter.instruction(instruction)
+		}
+	}
+	frame := Frame{
+		Locals:   procedure.Slots + emitter.allocation.SpillSlots,
+		CallArgs: calls.maxArgs,
+		Saved:    emitter.allocation.Preserved,
+	}
+	start := backend.ProcedureStart(procedureLabel(procedure.Name), frame)
+	return start + currentBody + backend.ProcedureEnd(frame)
+}
+
+// registersOnlyInRax finds the registers whose one use is as the first operand of the instruction
+// right after the one giving them their value, which finds them still in rax so they need no place of their own
+func registersOnlyInRax(procedure *ir.Procedure) map[ir.Register]bool {
+	uses := map[ir.Register]int{}
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			for _, arg := range instruction.Args {
+				if arg.Kind == ir.RegisterOperand {
+					uses[arg.Register]++
+				}
+			}
+		}
+	}
+	onlyInRax := map[ir.Register]bool{}
+	for index, block := range procedure.Blocks {
+		for at, instruction := range block.Instructions[:len(block.Instructions)-1] {
+			following := block.Instructions[at+1]
+			register := instruction.Dest
+			if register == ir.NoRegister || uses[register] != 1 || len(following.Args) == 0 || following.Args[0] != ir.Reg(register) {
+				continue
+			}
+			switch following.Op {
+			case ir.Call:
+				continue
+			case ir.Return:
+				// only the last block loads what it gives back into rax itself
+				if index+1 < len(procedure.Blocks) {
+					continue
+				}
+			}
+			onlyInRax[register] = true
+		}
+	}
+	return onlyInRax
+}
+
+// procedureLabel is the assembly label of a procedure, thisisthepie is the C entry point. Names can
+// hold any rune the lexer does not treat specially, so the others go through labelIdentifier
+func procedureLabel(name string) string {
+	if name == "thisisthepie" {
+		return "main"
+	}
+	return "halfleft_" + labelIdentifier(name)
+}
+
+// labelIdentifier makes name into something every assembler accepts in a label, and C as a name:
+// letters and digits are kept, _ is doubled and any other byte is written as _ and two hex digits.
+// Names made this way only differ if the names did, and never end with a single _
+func labelIdentifier(name string) string {
+	identifier := ""
+	for _, b := range []byte(name) {
+		switch {
+		case b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' && identifier != "":
+			identifier += string(b)
+		case b == '_':
+			identifier += "__"
+		default:
+			identifier += fmt.Sprintf("_%02X", b)
+		}
+	}
+	return identifier
+}
+
+// blockLabel is local to the label of the procedure, so every procedure can use the same block names
+func blockLabel(block *ir.Block) string {
+	return "." + block.Name
+}
+
+// callCounter remembers the most arguments passed by any call written through it
+type callCounter struct {
+	Backend
+	maxArgs int
+}
+
+func (c *callCounter) Call(function string, args []string) string {
+	if len(args) > c.maxArgs {
+		c.maxArgs = len(args)
+	}
+	return c.Backend.Call(function, args)
+}
+
+// procedureEmitter writes the instructions of one procedure, every value passes through rax on its way
+type procedureEmitter struct {
+	backend    Backend
+	procedure  *ir.Procedure
+	allocation *ir.Allocation
+	// next is the block written after the current one, jumps to it are left out
+	next *ir.Block
+	// inRax is the register whose value rax still holds, loads of it are left out
+	inRax ir.Register
+	// onlyInRax are the registers never written anywhere but rax
+	onlyInRax map[ir.Register]bool
+}
+
+func (e *procedureEmitter) instruction(instruction *ir.Instruction) string {
+	code := e.emit(instruction)
+	e.inRax = instruction.Dest
+	return code
+}
+
+func (e *procedureEmitter) emit(instruction *ir.Instruction) string {
+	args := []string{}
+	for _, arg := range instruction.Args {
+		args = append(args, e.operand(arg))
+	}
+	switch instruction.Op {
+	case ir.Load:
+		return "mov rax,qword " + e.backend.Local(instruction.Slot) + "\n" + e.setDest(instruction)
+	case ir.Store:
+		if e.isPhysical(instruction.Args[0]) && instruction.Args[0].Register != e.inRax {
+			return "mov qword " + e.backend.Local(instruction.Slot) + "," + args[0] + "\n"
+		}
+		return e.load("rax", instruction.Args[0]) + "mov qword " + e.backend.Local(instruction.Slot) + ",rax\n"
+	case ir.Copy:
+		return e.load("rax", instruction.Args[0]) + e.setDest(instruction)
+	case ir.Negate:
+		return e.load("rax", instruction.Args[0]) + "neg rax\n" + e.setDest(instruction)
+	case ir.Call:
+		return e.call(instruction, args) + e.setDest(instruction)
+	case ir.Jump:
+		return e.jump(instruction.Targets[0])
+	case ir.Branch:
+		code := e.load("rax", instruction.Args[0])
+		code += e.backend.JumpIfZero(blockLabel(instruction.Targets[1]))
+		return code + e.jump(instruction.Targets[0])
+	case ir.Return:
+		if e.next == nil {
+			// the last block runs straight into the end of the procedure
+			return e.load("rax", instruction.Args[0])
+		}
+		return e.backend.Return(args[0])
+	}
+
+	code := e.load("rax", instruction.Args[0])
+	code += e.load("rcx", instruction.Args[1])
+	switch instruction.Op {
+	case ir.Add:
+		code += "add rax,rcx\n"
+	case ir.Subtract:
+		code += "sub rax,rcx\n"
+	case ir.Multiply:
+		code += "imul rax,rcx\n"
+	case ir.Divide:
+		code += "cqo\nidiv rcx\n"
+	case ir.Modulo:
+		code += "cqo\nidiv rcx\nmov rax,rdx\n"
+	default:
+		code += "cmp rax,rcx\n"
+		code += comparisonSetInstructions[instruction.Op] + " al\n"
+		code += "movzx rax,al\n"
+	}
+	return code + e.setDest(instruction)
+}
+
+var comparisonSetInstructions = map[ir.Op]string{
+	ir.Equal:        "sete",
+	ir.NotEqual:     "setne",
+	ir.Less:         "setl",
+	ir.LessEqual:    "setle",
+	ir.Greater:      "setg",
+	ir.GreaterEqual: "setge",
+}
+
+// call writes a call to a builtin or procedure, leaving its result in rax
+func (e *procedureEmitter) call(instruction *ir.Instruction, args []string) string {
+	if instruction.Builtin {
+		body := GetStandardFunctionBody(GetStandardFunction(instruction.Callee).AssembledBodyName)
+		return body(e.backend, args, instruction.Types)
+	}
+	return e.backend.Call(procedureLabel(instruction.Callee), args)
+}
+
+func (e *procedureEmitter) jump(target *ir.Block) string {
+	if target == e.next {
+		return ""
+	}
+	return e.backend.Jump(blockLabel(target))
+}
+
+// setDest moves the value in rax to the register the instruction gives its value in, if it has one
+func (e *procedureEmitter) setDest(instruction *ir.Instruction) string {
+	if instruction.Dest == ir.NoRegister || e.onlyInRax[instruction.Dest] {
+		return ""
+	}
+	dest := ir.Reg(instruction.Dest)
+	if e.isPhysical(dest) {
+		return "mov " + e.operand(dest) + ",rax\n"
+	}
+	return "mov qword " + e.operand(dest) + ",rax\n"
+}
+
+// load moves operand into the machine register named register
+func (e *procedureEmitter) load(register string, operand ir.Operand) string {
+	if register == "rax" && operand.Kind == ir.RegisterOperand && operand.Register == e.inRax {
+		return ""
+	}
+	if e.isPhysical(operand) {
+		return "mov " + register + "," + e.operand(operand) + "\n"
+	}
+	return "mov " + register + ",qword " + e.operand(operand) + "\n"
+}
+
+// isPhysical is true for the registers kept in a machine register rather than spilled to the frame
+func (e *procedureEmitter) isPhysical(operand ir.Operand) bool {
+	_, physical := e.allocation.Physical[operand.Register]
+	return operand.Kind == ir.RegisterOperand && physical
+}
+
+func (e *procedureEmitter) operand(operand ir.Operand) string {
+	switch operand.Kind {
+	case ir.RegisterOperand:
+		if name, physical := e.allocation.Physical[operand.Register]; physical {
+			return name
+		}
+		return e.backend.Local(e.procedure.Slots + e.allocation.Spilled[operand.Register])
+	case ir.StringOperand:
+		return stringConstantName(int(operand.Value))
+	}
+	return strconv.FormatInt(operand.Value, 10)
+}
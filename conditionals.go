@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// parseConditional reads perchance £ condition $ / ... \ with an optional
+// otherwise / ... \ or otherwise perchance ... after it
+func (p *parser) parseConditional() *ast.If {
+	start := *p.peek()
+	p.expect(If, "perchance")
+	conditional := &ast.If{Position: start.Position, Condition: p.parseCondition()}
+	p.expect(BodyStart, "/ to open the perchance block")
+	conditional.Then = p.parseBlock()
+
+	next := p.peek()
+	if next != nil && next.Type == Else {
+		p.position++
+		if following := p.peek(); following != nil && following.Type == If {
+			conditional.Else = &ast.Block{Position: next.Position, Statements: []ast.Statement{p.parseConditional()}}
+		} else {
+			p.expect(BodyStart, "/ to open the otherwise block")
+			conditional.Else = p.parseBlock()
+		}
+	}
+	return conditional
+}
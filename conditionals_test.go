@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+func Test_parseConditional(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Perchance alone",
+			args{"perchance £ a < 2 $ / printthenumber £ 1 $ # \\"},
+			"(perchance (< a 2) [(call printthenumber 1)])",
+		},
+		{
+			"Perchance otherwise",
+			args{"perchance £ a $ / printthenumber £ 1 $ # \\ otherwise / printthenumber £ 2 $ # printthenumber £ 3 $ # \\"},
+			"(perchance a [(call printthenumber 1)] [(call printthenumber 2) (call printthenumber 3)])",
+		},
+		{
+			"Otherwise perchance",
+			args{"perchance £ a == 1 $ / \\ otherwise perchance £ a == 2 $ / printthenumber £ 2 $ # \\"},
+			"(perchance (== a 1) [] [(perchance (== a 2) [(call printthenumber 2)])])",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ast.Sprint(parserFor(tt.args.source).parseStatement()); got != tt.want {
+				t.Errorf("parseStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getAssemblyBodyFromIR_conditionals(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	a = 3 #
+	perchance £ a > 2 $ /
+		perchance £ a == 3 $ /
+			printthenumber £ 3 $ #
+		\
+	\ otherwise /
+		b = 1 #
+	\
+
+	perchance £ a < 0 $ /
+		giveback 1 #
+	\
+	printthenumber £ a $ #
+\`))
+	got := getAssemblyBodyFromIR(windowsBackend(), lowerProgram(tree))
+	want := `setg al
+movzx rax,al
+test rax,rax
+jz .otherwise1
+.then1:
+mov rax,qword [rbp-8]
+mov rcx,qword 3
+cmp rax,rcx
+sete al
+movzx rax,al
+test rax,rax
+jz .endperchance2
+.then2:
+Invoke printf,numberformat,3
+.endperchance2:
+jmp .endperchance1
+.otherwise1:
+mov rax,qword 1
+mov qword [rbp-16],rax
+.endperchance1:
+`
+	if !strings.Contains(got, want) {
+		t.Errorf("getAssemblyBodyFromIR() = %v, missing %v", got, want)
+	}
+	want = `setl al
+movzx rax,al
+test rax,rax
+jz .endperchance3
+.then3:
+mov rax,1
+jmp .giveback
+.endperchance3:
+mov rax,qword [rbp-8]
+mov r10,rax
+Invoke printf,numberformat,r10
+`
+	if !strings.Contains(got, want) {
+		t.Errorf("getAssemblyBodyFromIR() = %v, missing %v", got, want)
+	}
+}
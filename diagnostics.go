@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+var severityNames = map[Severity]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+}
+
+// Diagnostic is a single problem found in the source
+type Diagnostic struct {
+	Severity Severity
+	Position Position
+	Message  string
+}
+
+// Diagnostics collects the problems found in one source file, so they can all be reported together
+type Diagnostics struct {
+	file  string
+	lines []string
+	List  []Diagnostic
+}
+
+func NewDiagnostics(file string, source string) *Diagnostics {
+	return &Diagnostics{
+		file:  file,
+		lines: strings.Split(strings.Replace(source, "\r\n", "\n", -1), "\n"),
+	}
+}
+
+func (d *Diagnostics) Add(diagnostic Diagnostic) {
+	if diagnostic.Position.File == "" {
+		diagnostic.Position.File = d.file
+	}
+	d.List = append(d.List, diagnostic)
+}
+
+func (d *Diagnostics) Error(position Position, format string, args ...interface{}) {
+	d.Add(Diagnostic{Severity: SeverityError, Position: position, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *Diagnostics) Warning(position Position, format string, args ...interface{}) {
+	d.Add(Diagnostic{Severity: SeverityWarning, Position: position, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *Diagnostics) HasErrors() bool {
+	for _, diagnostic := range d.List {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// String lists every diagnostic in source order with the line it was found on and a caret under the column
+func (d *Diagnostics) String() string {
+	sorted := append([]Diagnostic{}, d.List...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Position.Line != sorted[j].Position.Line {
+			return sorted[i].Position.Line < sorted[j].Position.Line
+		}
+		return sorted[i].Position.Column < sorted[j].Position.Column
+	})
+	content := ""
+	for _, diagnostic := range sorted {
+		position := diagnostic.Position
+		if position.Line == 0 {
+			content += position.File + ": " + severityNames[diagnostic.Severity] + ": " + diagnostic.Message + "\n"
+			continue
+		}
+		content += position.String() + ": " + severityNames[diagnostic.Severity] + ": " + diagnostic.Message + "\n"
+		if position.Line > len(d.lines) {
+			continue
+		}
+		line := []rune(d.lines[position.Line-1])
+		caret := ""
+		for index := 0; index < position.Column-1 && index < len(line); index++ {
+			// tabs are kept so the caret lines up however wide the terminal draws them
+			if line[index] == '\t' {
+				caret += "\t"
+			} else {
+				caret += " "
+			}
+		}
+		content += "    " + string(line) + "\n"
+		content += "    " + caret + "^\n"
+	}
+	return content
+}
+
+// failAt abandons the statement being parsed, parser.tryStatement records the diagnostic and carries on
+func failAt(position Position, format string, args ...interface{}) {
+	panic(Diagnostic{Severity: SeverityError, Position: position, Message: fmt.Sprintf(format, args...)})
+}
+
+// describeToken names a token the way it is written in the source
+func describeToken(tok Token) string {
+	if tok.Value != nil {
+		if tok.Type == StringConst {
+			return "string ¬" + *tok.Value + "¬"
+		}
+		return *tok.Value
+	}
+	return tokenText[tok.Type]
+}
+
+var tokenText = map[TokeType]string{
+	ModuleImport:    "alien",
+	ProcedureDefine: "halfleft",
+	ParamOpen:       "£",
+	ParamClose:      "$",
+	EndLine:         "#",
+	BodyStart:       "/",
+	BodyEnd:         "\\",
+	Assign:          "=",
+	Plus:            "+",
+	Minus:           "-",
+	Multiply:        "*",
+	Modulo:          "%",
+	IsEqual:         "==",
+	NotEqual:        "!=",
+	LessThan:        "<",
+	LessEqual:       "<=",
+	GreaterThan:     ">",
+	GreaterEqual:    ">=",
+	GroupOpen:       "(",
+	GroupClose:      ")",
+	Return:          "giveback",
+	If:              "perchance",
+	Else:            "otherwise",
+	While:           "whilst",
+	Break:           "scarper",
+	Continue:        "carryon",
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_resolveProgram_diagnostics(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Unknown procedure",
+			args{"halfleft thisisthepie £ $ /\n\tshoot £ 1 $ #\n\\"},
+			"test.gry:2:2: error: call to unknown procedure shoot\n" +
+				"    \tshoot £ 1 $ #\n" +
+				"    \t^\n",
+		},
+		{
+			"Too many and too few arguments",
+			args{"halfleft thisisthepie £ $ /\n\tprintthenumber £ 1 2 $ #\n\tpair £ 1 $ #\n\\\nhalfleft pair £ a b $ /\n\\"},
+			"test.gry:2:21: error: too many arguments to printthenumber, it takes 1 argument\n" +
+				"    \tprintthenumber £ 1 2 $ #\n" +
+				"    \t                   ^\n" +
+				"test.gry:3:2: error: pair takes 2 arguments but is given 1\n" +
+				"    \tpair £ 1 $ #\n" +
+				"    \t^\n",
+		},
+		{
+			"Missing thisisthepie",
+			args{"halfleft other £ $ /\n\\"},
+			"test.gry: error: there is no halfleft thisisthepie for the program to start from\n",
+		},
+		{
+			"Unterminated body",
+			args{"halfleft thisisthepie £ $ /\n\tperchance £ 1 $ /\n\t\tprintthenumber £ 1 $ #\n\\"},
+			"test.gry:1:27: error: missing \\ to close the block opened here\n" +
+				"    halfleft thisisthepie £ $ /\n" +
+				"                              ^\n",
+		},
+		{
+			"Stray tokens outside procedures",
+			args{"oops\nhalfleft thisisthepie £ $ /\n\\ #"},
+			"test.gry:1:1: error: unexpected oops outside of a procedure, procedures start with halfleft\n" +
+				"    oops\n" +
+				"    ^\n" +
+				"test.gry:3:3: error: unexpected # after the end of procedure thisisthepie\n" +
+				"    \\ #\n" +
+				"      ^\n",
+		},
+		{
+			"Stray tokens in a declaration",
+			args{"halfleft thisisthepie £ x £ $ /\n\\"},
+			"test.gry:1:27: error: expected $ in the declaration of thisisthepie but found £\n" +
+				"    halfleft thisisthepie £ x £ $ /\n" +
+				"                              ^\n",
+		},
+		{
+			"Stray tokens in statements",
+			args{"halfleft thisisthepie £ $ /\n\tx = 1 $ #\n\tscarper #\n\tprintthenumber £ x $\n\\"},
+			"test.gry:2:8: error: unexpected $ in expression\n" +
+				"    \tx = 1 $ #\n" +
+				"    \t      ^\n" +
+				"test.gry:3:2: error: scarper can only be used inside a whilst loop\n" +
+				"    \tscarper #\n" +
+				"    \t^\n" +
+				"test.gry:4:2: error: missing # at the end of this statement\n" +
+				"    \tprintthenumber £ x $\n" +
+				"    \t^\n",
+		},
+		{
+			"Number too big for an int",
+			args{"halfleft thisisthepie £ $ /\n\tx = 99999999999999999999 #\n\\"},
+			"test.gry:2:6: error: number 99999999999999999999 is too big\n" +
+				"    \tx = 99999999999999999999 #\n" +
+				"    \t    ^\n",
+		},
+		{
+			"Unused procedure",
+			args{"halfleft thisisthepie £ $ /\n\\\nhalfleft spare £ $ /\n\\"},
+			"test.gry:3:10: warning: procedure spare is never called\n" +
+				"    halfleft spare £ $ /\n" +
+				"             ^\n",
+		},
+		{
+			"Duplicate procedure",
+			args{"halfleft thisisthepie £ $ /\n\\\nhalfleft thisisthepie £ $ /\n\\"},
+			"test.gry:3:10: error: procedure thisisthepie is already defined\n" +
+				"    halfleft thisisthepie £ $ /\n" +
+				"             ^\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := NewDiagnostics("test.gry", tt.args.source)
+			resolveProgram(parseProgram(tokenize(tt.args.source), diagnostics), diagnostics)
+			if got := diagnostics.String(); got != tt.want {
+				t.Errorf("parseProgram() reported\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
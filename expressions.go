@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// Operators from loosest to tightest binding, "/" arrives as a BodyStart token
+var binaryPrecedence = [][]TokeType{
+	{IsEqual, NotEqual, LessThan, LessEqual, GreaterThan, GreaterEqual},
+	{Plus, Minus},
+	{Multiply, BodyStart, Modulo},
+}
+
+var binaryOperators = map[TokeType]ast.Operator{
+	IsEqual:      ast.Equal,
+	NotEqual:     ast.NotEqual,
+	LessThan:     ast.Less,
+	LessEqual:    ast.LessEqual,
+	GreaterThan:  ast.Greater,
+	GreaterEqual: ast.GreaterEqual,
+	Plus:         ast.Add,
+	Minus:        ast.Subtract,
+	Multiply:     ast.Multiply,
+	BodyStart:    ast.Divide,
+	Modulo:       ast.Modulo,
+}
+
+// parseExpression reads an expression for as long as the tokens continue it
+func (p *parser) parseExpression() ast.Expression {
+	return p.parseBinary(0)
+}
+
+// parseBinary reads operands joined by the operators of level or tighter, a Binary is positioned at its operator
+func (p *parser) parseBinary(level int) ast.Expression {
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+	left := p.parseBinary(level + 1)
+	for {
+		next := p.peek()
+		if next == nil || !isOperatorOf(next.Type, binaryPrecedence[level]) {
+			return left
+		}
+		p.position++
+		right := p.parseBinary(level + 1)
+		left = &ast.Binary{
+			Position: next.Position,
+			Operator: binaryOperators[next.Type],
+			Left:     left,
+			Right:    right,
+		}
+	}
+}
+
+func (p *parser) parseUnary() ast.Expression {
+	next := p.peek()
+	if next != nil && next.Type == Minus {
+		p.position++
+		return &ast.Unary{Position: next.Position, Operator: ast.Negate, Operand: p.parseUnary()}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() ast.Expression {
+	next := p.peek()
+	if endsBlock(next) || next.Type == EndLine {
+		failAt(p.previousPosition(), "expected a value")
+	}
+	p.position++
+	switch next.Type {
+	case Name:
+		following := p.peek()
+		if following != nil && following.Type == ParamOpen {
+			return p.parseCall(*next)
+		}
+		return &ast.Ident{Position: next.Position, Name: *next.Value}
+	case Number:
+		value, err := strconv.ParseInt(*next.Value, 10, 64)
+		if err != nil {
+			failAt(next.Position, "number %s is too big", *next.Value)
+		}
+		return &ast.IntLit{Position: next.Position, Value: value}
+	case BoolConst:
+		return &ast.BoolLit{Position: next.Position, Value: *next.Value == "aye"}
+	case StringConst:
+		return &ast.StringLit{Position: next.Position, Value: *next.Value}
+	case GroupOpen:
+		value := p.parseExpression()
+		closing := p.peek()
+		if closing == nil || closing.Type != GroupClose {
+			failAt(next.Position, "missing ) to close this (")
+		}
+		p.position++
+		return value
+	}
+	failAt(next.Position, "unexpected %s where a value was expected", describeToken(*next))
+	return nil
+}
+
+// parseCall reads the arguments of a call from its £ up to the matching $,
+// each argument is a single value so anything more involved goes in parentheses
+func (p *parser) parseCall(name Token) *ast.Call {
+	call := &ast.Call{Position: name.Position, Name: *name.Value}
+	p.position++
+	for {
+		next := p.peek()
+		if endsBlock(next) || next.Type == EndLine {
+			failAt(name.Position, "missing $ after the arguments to %s", call.Name)
+		}
+		if next.Type == ParamClose {
+			p.position++
+			return call
+		}
+		call.Args = append(call.Args, p.parseUnary())
+	}
+}
+
+func argumentCount(count int) string {
+	if count == 1 {
+		return "1 argument"
+	}
+	return strconv.Itoa(count) + " arguments"
+}
+
+func isOperatorOf(tokenType TokeType, operators []TokeType) bool {
+	for _, operator := range operators {
+		if operator == tokenType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// parserFor starts a parser at the beginning of source
+func parserFor(source string) *parser {
+	return &parser{tokens: *tokenize(source), diagnostics: NewDiagnostics("", source)}
+}
+
+func Test_parseExpression(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Single value",
+			args{"42"},
+			"42",
+		},
+		{
+			"Multiplication binds tighter than addition",
+			args{"a * 2 + b"},
+			"(+ (* a 2) b)",
+		},
+		{
+			"Parentheses and division",
+			args{"( a - 1 ) / 2"},
+			"(/ (- a 1) 2)",
+		},
+		{
+			"Comparison binds loosest and is left associative",
+			args{"a < b + 1 == 0"},
+			"(== (< a (+ b 1)) 0)",
+		},
+		{
+			"Bools",
+			args{"aye != nay"},
+			"(!= aye nay)",
+		},
+		{
+			"Unary minus",
+			args{"- a % 3"},
+			"(% (- a) 3)",
+		},
+		{
+			"Calls as values",
+			args{"double £ double £ x $ $ + ¬s¬"},
+			"(+ (call double (call double x)) \"s\")",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ast.Sprint(parserFor(tt.args.source).parseExpression()); got != tt.want {
+				t.Errorf("parseExpression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseExpression_positions(t *testing.T) {
+	got := parserFor("a +\n\t( 2 * b )").parseExpression().(*ast.Binary)
+	product := got.Right.(*ast.Binary)
+	positions := map[string][2]ast.Position{
+		"sum":      {got.Pos(), {Line: 1, Column: 3}},
+		"left":     {got.Left.Pos(), {Line: 1, Column: 1}},
+		"product":  {product.Pos(), {Line: 2, Column: 6}},
+		"constant": {product.Left.Pos(), {Line: 2, Column: 4}},
+	}
+	for name, position := range positions {
+		if position[0] != position[1] {
+			t.Errorf("parseExpression() put %v at %v, want %v", name, position[0], position[1])
+		}
+	}
+}
+
+func Test_lowerProgram_expressions(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Operands are evaluated left to right",
+			args{"a * 2 + b"},
+			`	r0 = load s0
+	r1 = mul r0, 2
+	r2 = load s1
+	r3 = add r1, r2
+	return r3
+`,
+		},
+		{
+			"Negation",
+			args{"- ( a - 1 )"},
+			`	r0 = load s0
+	r1 = sub r0, 1
+	r2 = neg r1
+	return r2
+`,
+		},
+		{
+			"Comparison",
+			args{"a >= b"},
+			`	r0 = load s0
+	r1 = load s1
+	r2 = ge r0, r1
+	return r2
+`,
+		},
+		{
+			"Remainder",
+			args{"a % 2"},
+			`	r0 = load s0
+	r1 = mod r0, 2
+	return r1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a and b are parameters so they are the first two slots
+			tree := treeFromTokens(tokenize("halfleft thisisthepie £ a b $ / giveback " + tt.args.source + " # \\"))
+			got := ""
+			for _, instruction := range lowerProgram(tree).Procedures[0].Blocks[0].Instructions {
+				got += "\t" + instruction.String() + "\n"
+			}
+			if got != tt.want {
+				t.Errorf("lowerProgram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getAssemblyBodyFromIR_expressions(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	a = 6 #
+	b = 4 #
+	x = a * 2 + b #
+	printthenumber £ ( x / 2 ) $ #
+\`))
+	got := getAssemblyBodyFromIR(windowsBackend(), lowerProgram(tree))
+	for _, want := range []string{
+		"imul rax,rcx\n",
+		"mov rax,r10\nmov rcx,r11\nadd rax,rcx\nmov qword [rbp-24],rax\n",
+		"cqo\nidiv rcx\nmov r10,rax\nInvoke printf,numberformat,r10\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("getAssemblyBodyFromIR() = %v, missing %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package format writes strings the way printf from the C library does, for the runtimes that run
+// GaryLang programs without it: the interpreter, the virtual machine and the wasm host. Sharing it
+// keeps what they print the same as what the compiled programs print.
+package format
+
+import (
+	"strconv"
+)
+
+// Arg is something passed to Printf, read as a number or as a string by the conversion it is for
+type Arg interface {
+	Number() int64
+	Text() []byte
+}
+
+// Printf gives what printf writes for format and args, for the conversions the runtimes know: %%, %s
+// and %c, and %d and %i with any number of l in front. Anything else is written as it is, as is a
+// conversion with no argument left for it
+func Printf(format []byte, args []Arg) []byte {
+	written := []byte{}
+	for index := 0; index < len(format); index++ {
+		if format[index] != '%' {
+			written = append(written, format[index])
+			continue
+		}
+		start := index
+		index++
+		for index < len(format) && format[index] == 'l' {
+			index++
+		}
+		conversion := byte(0)
+		if index < len(format) {
+			conversion = format[index]
+		}
+		lengthGiven := index > start+1
+		switch {
+		case conversion == '%' && !lengthGiven:
+			written = append(written, '%')
+		case (conversion == 's' || conversion == 'c') && !lengthGiven || conversion == 'd' || conversion == 'i':
+			if len(args) == 0 {
+				written = append(written, format[start:index+1]...)
+				continue
+			}
+			arg := args[0]
+			args = args[1:]
+			switch conversion {
+			case 's':
+				written = append(written, arg.Text()...)
+			case 'c':
+				written = append(written, byte(arg.Number()))
+			default:
+				written = strconv.AppendInt(written, arg.Number(), 10)
+			}
+		default:
+			// not a conversion after all, what stopped it is written as it is from the next turn
+			written = append(written, format[start:index]...)
+			index--
+		}
+	}
+	return written
+}
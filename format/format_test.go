@@ -0,0 +1,46 @@
+package format
+
+import (
+	"testing"
+)
+
+// arg is a number that prints as text with %s
+type arg struct {
+	number int64
+	text   string
+}
+
+func (a arg) Number() int64 {
+	return a.number
+}
+
+func (a arg) Text() []byte {
+	return []byte(a.text)
+}
+
+func Test_Printf(t *testing.T) {
+	type args struct {
+		format string
+		args   []Arg
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Numbers", args{"%lld %ld %d %i", []Arg{arg{number: -1}, arg{number: 2}, arg{number: 3}, arg{number: 4}}}, "-1 2 3 4"},
+		{"Strings and characters", args{"%s%c", []Arg{arg{text: "hi"}, arg{number: '!'}}}, "hi!"},
+		{"Percent", args{"100%%", nil}, "100%"},
+		{"Percent at the end", args{"100%", nil}, "100%"},
+		{"Length on a string", args{"%ls", []Arg{arg{text: "hi"}}}, "%ls"},
+		{"Unknown conversion", args{"%q %l%", nil}, "%q %l%"},
+		{"Missing argument", args{"%d", nil}, "%d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Printf([]byte(tt.args.format), tt.args.args)); got != tt.want {
+				t.Errorf("Printf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/format"
+)
+
+// value is what an expression gives when interpreted, ints and bools are held in number and strings in text
+type value struct {
+	number int64
+	text   string
+}
+
+func (v value) Number() int64 {
+	return v.number
+}
+
+// Text is the string up to the first 0 byte, where it ends in every compiled program
+func (v value) Text() []byte {
+	if end := strings.IndexByte(v.text, 0); end >= 0 {
+		return []byte(v.text[:end])
+	}
+	return []byte(v.text)
+}
+
+func boolValue(b bool) value {
+	if b {
+		return value{number: 1}
+	}
+	return value{number: 0}
+}
+
+// RuntimeError is something a program did while running that the compiled program would crash on
+type RuntimeError struct {
+	Position Position
+	Message  string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Position.String() + ": " + e.Message
+}
+
+// outcome is how a statement finished, anything but carryingOn skips the statements after it
+type outcome int
+
+const (
+	carryingOn outcome = iota
+	scarpering
+	continuing
+	givingBack
+)
+
+// maxCallDepth is how deep calls may go before the program is stopped, where a compiled program would
+// run out of stack. Each call is a few calls of the interpreter itself, which keeps Go well within its
+// own stack
+const maxCallDepth = 1 << 18
+
+// interpreter runs a checked program straight from its tree, the reference for what compiled programs do
+type interpreter struct {
+	program *ast.Program
+	out     io.Writer
+	// depth is how many calls are running
+	depth int
+}
+
+// frame holds the parameters and locals of one call, by the index of their symbol
+type frame struct {
+	procedure *ast.ProcDecl
+	locals    []value
+	// result is what a giveback gave back
+	result value
+	// again holds the arguments when a giveback calls the procedure it is in
+	again []value
+}
+
+// interpretProgram runs thisisthepie of a checked program, writing what it prints to out, and gives
+// back what thisisthepie gives back
+func interpretProgram(program *ast.Program, out io.Writer) (result int64, err error) {
+	defer func() {
+		recovered := recover()
+		if runtimeError, isRuntimeError := recovered.(*RuntimeError); isRuntimeError {
+			err = runtimeError
+		} else if recovered != nil {
+			panic(recovered)
+		}
+	}()
+	i := &interpreter{program: program, out: out}
+	return i.call(program.Procedure("thisisthepie"), nil).number, nil
+}
+
+// call runs procedure with args. A giveback calling the procedure it is in runs it again with the new
+// arguments rather than going deeper, as the compiled programs do once their tail calls are loops
+func (i *interpreter) call(procedure *ast.ProcDecl, args []value) value {
+	for {
+		f := &frame{procedure: procedure, locals: make([]value, len(procedure.Locals))}
+		copy(f.locals, args)
+		// falling off the end gives back 0
+		i.block(f, procedure.Body)
+		if f.again == nil {
+			return f.result
+		}
+		args = f.again
+	}
+}
+
+func (i *interpreter) block(f *frame, block *ast.Block) outcome {
+	for _, statement := range block.Statements {
+		if outcome := i.statement(f, statement); outcome != carryingOn {
+			return outcome
+		}
+	}
+	return carryingOn
+}
+
+func (i *interpreter) statement(f *frame, statement ast.Statement) outcome {
+	switch statement := statement.(type) {
+	case *ast.Assign:
+		f.locals[statement.Target.Symbol.Index] = i.expression(f, statement.Value)
+	case *ast.Return:
+		if call, isCall := statement.Value.(*ast.Call); isCall && call.Symbol.Kind != ast.BuiltinSymbol && call.Name == f.procedure.Name {
+			f.again = i.arguments(f, call)
+			return givingBack
+		}
+		f.result = i.expression(f, statement.Value)
+		return givingBack
+	case *ast.If:
+		if i.expression(f, statement.Condition).number != 0 {
+			return i.block(f, statement.Then)
+		}
+		if statement.Else != nil {
+			return i.block(f, statement.Else)
+		}
+	case *ast.While:
+		for i.expression(f, statement.Condition).number != 0 {
+			outcome := i.block(f, statement.Body)
+			if outcome == scarpering {
+				break
+			}
+			if outcome == givingBack {
+				return outcome
+			}
+		}
+	case *ast.Break:
+		return scarpering
+	case *ast.Continue:
+		return continuing
+	case *ast.Call:
+		i.expression(f, statement)
+	}
+	return carryingOn
+}
+
+func (i *interpreter) expression(f *frame, expression ast.Expression) value {
+	switch expression := expression.(type) {
+	case *ast.IntLit:
+		return value{number: expression.Value}
+	case *ast.BoolLit:
+		return boolValue(expression.Value)
+	case *ast.StringLit:
+		return value{text: expression.Value}
+	case *ast.Ident:
+		return f.locals[expression.Symbol.Index]
+	case *ast.Unary:
+		return value{number: -i.expression(f, expression.Operand).number}
+	case *ast.Binary:
+		left := i.expression(f, expression.Left).number
+		right := i.expression(f, expression.Right).number
+		return binary(expression, left, right)
+	case *ast.Call:
+		args := i.arguments(f, expression)
+		if expression.Symbol.Kind == ast.BuiltinSymbol {
+			types := []ast.Type{}
+			for _, arg := range expression.Args {
+				types = append(types, ast.TypeOf(arg))
+			}
+			interpret := GetStandardFunctionInterpretation(GetStandardFunction(expression.Name).AssembledBodyName)
+			return interpret(i.out, args, types)
+		}
+		if i.depth == maxCallDepth {
+			panic(&RuntimeError{expression.Position, "calls go too deep"})
+		}
+		i.depth++
+		result := i.call(i.program.Procedure(expression.Name), args)
+		i.depth--
+		return result
+	}
+	panic("expression cannot be interpreted")
+}
+
+// arguments works out the arguments of call, never giving back nil
+func (i *interpreter) arguments(f *frame, call *ast.Call) []value {
+	args := []value{}
+	for _, arg := range call.Args {
+		args = append(args, i.expression(f, arg))
+	}
+	return args
+}
+
+// binary works out operator the way the machine instructions do, wrapping around on overflow.
+// Dividing by zero, or the smallest int by -1, faults the compiled program and is an error here
+func binary(expression *ast.Binary, left int64, right int64) value {
+	switch expression.Operator {
+	case ast.Add:
+		return value{number: left + right}
+	case ast.Subtract:
+		return value{number: left - right}
+	case ast.Multiply:
+		return value{number: left * right}
+	case ast.Divide, ast.Modulo:
+		if right == 0 {
+			panic(&RuntimeError{expression.Position, "division by zero"})
+		}
+		if left == math.MinInt64 && right == -1 {
+			panic(&RuntimeError{expression.Position, "division overflows"})
+		}
+		if expression.Operator == ast.Divide {
+			return value{number: left / right}
+		}
+		return value{number: left % right}
+	case ast.Equal:
+		return boolValue(left == right)
+	case ast.NotEqual:
+		return boolValue(left != right)
+	case ast.Less:
+		return boolValue(left < right)
+	case ast.LessEqual:
+		return boolValue(left <= right)
+	case ast.Greater:
+		return boolValue(left > right)
+	case ast.GreaterEqual:
+		return boolValue(left >= right)
+	}
+	panic("operator cannot be interpreted")
+}
+
+// printf writes formatText with args to out like the C function does, see format.Printf, and gives
+// back the number of bytes written
+func printf(out io.Writer, formatText string, args []value) value {
+	formatArgs := make([]format.Arg, len(args))
+	for index, arg := range args {
+		formatArgs[index] = arg
+	}
+	count, _ := out.Write(format.Printf([]byte(formatText), formatArgs))
+	return value{number: int64(count)}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+// interpreterPrograms are run by the interpreter and, where it can, compiled and run to check both agree
+var interpreterPrograms = []struct {
+	name       string
+	source     string
+	want       string
+	wantResult int64
+}{
+	{
+		"Builtins",
+		`halfleft thisisthepie £ $ /
+	written = printthething £ ¬hello ¬ $ #
+	printthenumber £ written $ #
+	printthevalue £ ¬ and ¬ $ #
+	printthevalue £ ( 3 < 4 ) $ #
+	printthevalue £ nay $ #
+\`,
+		"hello 6 and 10",
+		0,
+	},
+	{
+		"Loops with scarper and carryon",
+		`halfleft thisisthepie £ $ /
+	i = 0 #
+	whilst £ aye $ /
+		i = i + 1 #
+		perchance £ i % 2 == 0 $ /
+			carryon #
+		\
+		perchance £ i > 7 $ /
+			scarper #
+		\
+		printthenumber £ i $ #
+	\
+	giveback i #
+\`,
+		"1357",
+		9,
+	},
+	{
+		"Recursion and giveback from inside a loop",
+		`halfleft thisisthepie £ $ /
+	printthenumber £ factorial £ 20 $ $ #
+	giveback firstover £ 50 $ #
+\
+halfleft factorial £ n $ /
+	perchance £ n < 2 $ /
+		giveback 1 #
+	\
+	giveback n * factorial £ ( n - 1 ) $ #
+\
+halfleft firstover £ limit $ /
+	square = 1 #
+	whilst £ aye $ /
+		perchance £ square * square > limit $ /
+			giveback square #
+		\
+		square = square + 1 #
+	\
+\`,
+		"2432902008176640000",
+		8,
+	},
+	{
+		"Division truncates and numbers wrap",
+		`halfleft thisisthepie £ $ /
+	printthenumber £ ( 0 - 7 / 2 ) $ #
+	printthething £ ¬ ¬ $ #
+	printthenumber £ ( 0 - 7 % 2 ) $ #
+	printthething £ ¬ ¬ $ #
+	printthenumber £ ( 9223372036854775807 + 1 ) $ #
+\`,
+		"-3 -1 -9223372036854775808",
+		0,
+	},
+	{
+		"Falling off the end gives back 0",
+		`halfleft thisisthepie £ $ /
+	printthenumber £ nothing £ $ $ #
+\
+halfleft nothing £ $ /
+	x = 1 #
+\`,
+		"0",
+		0,
+	},
+	{
+		"Strings are printed as they are",
+		`halfleft thisisthepie £ $ /
+	printthething £ ¬100%d done %s¬ $ #
+	printthevalue £ ¬ %lld%¬ $ #
+\`,
+		"100%d done %s %lld%",
+		0,
+	},
+	{
+		"Strings end at a 0 byte",
+		`halfleft thisisthepie £ $ /
+	printthething £ ¬a\x00b¬ $ #
+	printthevalue £ ¬c\x00d¬ $ #
+\`,
+		"ac",
+		0,
+	},
+	{
+		"Names no assembler accepts as they are",
+		`halfleft thisisthepie £ $ /
+	giveback naïve:x £ 3 $ #
+\
+halfleft naïve:x £ a $ /
+	printthenumber £ a $ #
+	giveback a + 1 #
+\`,
+		"3",
+		4,
+	},
+	{
+		"Tail calls deeper than any stack",
+		`halfleft thisisthepie £ $ /
+	printthenumber £ count £ 1100000 0 $ $ #
+\
+halfleft count £ n total $ /
+	perchance £ n == 0 $ /
+		giveback total #
+	\
+	giveback count £ ( n - 1 ) ( total + n ) $ #
+\`,
+		"605000550000",
+		0,
+	},
+}
+
+func Test_interpretProgram(t *testing.T) {
+	for _, tt := range interpreterPrograms {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			result, err := interpretProgram(treeFromTokens(tokenize(tt.source)), out)
+			if err != nil {
+				t.Fatalf("interpretProgram() error = %v", err)
+			}
+			if out.String() != tt.want || result != tt.wantResult {
+				t.Errorf("interpretProgram() printed %q and gave back %d, want %q and %d", out.String(), result, tt.want, tt.wantResult)
+			}
+		})
+	}
+}
+
+// Test_interpretProgram_compiled checks the compiled programs do what the interpreter does
+func Test_interpretProgram_compiled(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("running compiled programs needs linux-amd64")
+	}
+	backend, _ := GetBackend("linux-amd64")
+	for _, tt := range interpreterPrograms {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				program := lowerProgram(treeFromTokens(tokenize(tt.source)))
+				ir.Optimise(program, level)
+				externs := cExternsFromAssemblyFiles(*usedBuiltinFunctions(program, &[]string{}))
+				source := getAssembly(backend, getAssemblyBodyFromIR(backend, program), externs, getAssemblyConstantsFromIR(program))
+				sourcePath := filepath.Join(t.TempDir(), "program.asm")
+				if err := ioutil.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+					t.Fatal(err)
+				}
+				exePath, err := backend.Build(sourcePath, BuildOptions{Linker: "builtin"})
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				got, err := exec.Command(exePath).Output()
+				result := 0
+				if exitError, exited := err.(*exec.ExitError); exited {
+					result = exitError.ExitCode()
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want || int64(result) != tt.wantResult&0xff {
+					t.Errorf("at level %d the program printed %q and exited with %d, want %q and %d", level, got, result, tt.want, tt.wantResult&0xff)
+				}
+			})
+		}
+	}
+}
+
+func Test_interpretProgram_errors(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      string
+		wantError string
+	}{
+		{
+			"Division by zero",
+			args{`halfleft thisisthepie £ $ /
+	printthething £ ¬before¬ $ #
+	zero = 0 #
+	printthenumber £ ( 1 / zero ) $ #
+\`},
+			"before",
+			":4:23: division by zero",
+		},
+		{
+			"Smallest int divided by -1",
+			args{`halfleft thisisthepie £ $ /
+	smallest = 0 - 9223372036854775807 - 1 #
+	giveback smallest % ( 0 - 1 ) #
+\`},
+			"",
+			":3:20: division overflows",
+		},
+		{
+			"Recursion that never stops",
+			args{`halfleft thisisthepie £ $ /
+	forever £ 1 $ #
+\
+halfleft forever £ n $ /
+	giveback 1 + forever £ ( n + 1 ) $ #
+\`},
+			"",
+			":5:15: calls go too deep",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			_, err := interpretProgram(treeFromTokens(tokenize(tt.args.source)), out)
+			if err == nil || err.Error() != tt.wantError || out.String() != tt.want {
+				t.Errorf("interpretProgram() printed %q with error %v, want %q and %v", out.String(), err, tt.want, tt.wantError)
+			}
+		})
+	}
+}
+
+func Test_printf(t *testing.T) {
+	type args struct {
+		format string
+		args   []value
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Numbers", args{"%lld %ld %d %i", []value{{number: -1}, {number: 2}, {number: 3}, {number: 4}}}, "-1 2 3 4"},
+		{"Strings and characters", args{"%s%c", []value{{text: "hi"}, {number: '!'}}}, "hi!"},
+		{"Percent", args{"100%%", nil}, "100%"},
+		{"Percent at the end", args{"100%", nil}, "100%"},
+		{"Unknown conversion", args{"%q %l%", nil}, "%q %l%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			result := printf(out, tt.args.format, tt.args.args)
+			if out.String() != tt.want || result.number != int64(len(tt.want)) {
+				t.Errorf("printf() wrote %q and gave back %d, want %q", out.String(), result.number, tt.want)
+			}
+		})
+	}
+}
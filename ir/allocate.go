@@ -0,0 +1,232 @@
+package ir
+
+import (
+	"sort"
+)
+
+// Registers are the machine registers an allocation may keep values in. Preserved registers keep
+// their value across calls but must be saved by the procedure using them, scratch registers need no
+// saving but any call may overwrite them
+type Registers struct {
+	Preserved []string
+	Scratch   []string
+}
+
+// Allocation says where each register of a procedure lives while the procedure runs
+type Allocation struct {
+	// Physical maps the registers kept in a machine register to its name
+	Physical map[Register]string
+	// Spilled maps the registers that did not fit to a stack slot, counted from zero after the slots of the procedure
+	Spilled map[Register]int
+	// SpillSlots is how many stack slots the spilled registers share between them
+	SpillSlots int
+	// Preserved are the preserved registers handed out, which the procedure has to give back unchanged
+	Preserved []string
+}
+
+// Interval is the stretch of a procedure over which a register holds a value that is still needed,
+// as positions in the order the instructions are written block after block
+type Interval struct {
+	Register Register
+	Start    int
+	End      int
+	// AcrossCall is true when a call happens while the value is still needed
+	AcrossCall bool
+}
+
+// Allocate gives every register of procedure a machine register or a stack slot by linear scan.
+// Values needed across a call only go in preserved registers. When every register that would do is
+// taken, whichever value is needed for longest goes to the stack, and stack slots are shared by values
+// that are never needed at the same time. Registers in unplaced are never kept anywhere by the backend
+// and get no place of their own
+func Allocate(procedure *Procedure, registers Registers, unplaced map[Register]bool) *Allocation {
+	allocation := &Allocation{Physical: map[Register]string{}, Spilled: map[Register]int{}}
+	taken := map[string]bool{}
+	freeSlots := []int{}
+	spill := func(interval *Interval) {
+		if len(freeSlots) > 0 {
+			allocation.Spilled[interval.Register] = freeSlots[len(freeSlots)-1]
+			freeSlots = freeSlots[:len(freeSlots)-1]
+			return
+		}
+		allocation.Spilled[interval.Register] = allocation.SpillSlots
+		allocation.SpillSlots++
+	}
+
+	active := []*Interval{}
+	for _, current := range LiveIntervals(procedure) {
+		if unplaced[current.Register] {
+			continue
+		}
+		// an instruction reads its operands before it writes its result, so a value last needed where
+		// current starts can hand its place over
+		stillActive := []*Interval{}
+		for _, interval := range active {
+			if interval.End > current.Start {
+				stillActive = append(stillActive, interval)
+			} else if name, inRegister := allocation.Physical[interval.Register]; inRegister {
+				delete(taken, name)
+			} else {
+				freeSlots = append(freeSlots, allocation.Spilled[interval.Register])
+			}
+		}
+		active = append(stillActive, current)
+
+		candidates := registers.Preserved
+		if !current.AcrossCall {
+			candidates = append(append([]string{}, registers.Scratch...), registers.Preserved...)
+		}
+		if name := firstFree(candidates, taken); name != "" {
+			allocation.Physical[current.Register] = name
+			taken[name] = true
+			continue
+		}
+
+		var victim *Interval
+		for _, interval := range active {
+			name, inRegister := allocation.Physical[interval.Register]
+			if inRegister && contains(candidates, name) && (victim == nil || interval.End > victim.End) {
+				victim = interval
+			}
+		}
+		if victim == nil || victim.End <= current.End {
+			spill(current)
+			continue
+		}
+		allocation.Physical[current.Register] = allocation.Physical[victim.Register]
+		delete(allocation.Physical, victim.Register)
+		spill(victim)
+	}
+
+	used := map[string]bool{}
+	for _, name := range allocation.Physical {
+		used[name] = true
+	}
+	for _, name := range registers.Preserved {
+		if used[name] {
+			allocation.Preserved = append(allocation.Preserved, name)
+		}
+	}
+	return allocation
+}
+
+func firstFree(names []string, taken map[string]bool) string {
+	for _, name := range names {
+		if !taken[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+func contains(names []string, name string) bool {
+	for _, other := range names {
+		if other == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LiveIntervals finds the interval of every register of procedure, ordered by where they start.
+// A register that is needed at the end of a block is needed over the whole of it, so a value
+// used inside a loop stays live all the way round it
+func LiveIntervals(procedure *Procedure) []*Interval {
+	liveIn, liveOut := registerLiveness(procedure)
+	intervals := map[Register]*Interval{}
+	extend := func(register Register, position int) {
+		interval, exists := intervals[register]
+		if !exists {
+			intervals[register] = &Interval{Register: register, Start: position, End: position}
+			return
+		}
+		if position < interval.Start {
+			interval.Start = position
+		}
+		if position > interval.End {
+			interval.End = position
+		}
+	}
+
+	calls := []int{}
+	position := 0
+	for _, block := range procedure.Blocks {
+		start := position
+		for _, instruction := range block.Instructions {
+			for _, arg := range instruction.Args {
+				if arg.Kind == RegisterOperand {
+					extend(arg.Register, position)
+				}
+			}
+			if instruction.Dest != NoRegister {
+				extend(instruction.Dest, position)
+			}
+			if instruction.Op == Call {
+				calls = append(calls, position)
+			}
+			position++
+		}
+		for register := range liveIn[block] {
+			extend(register, start)
+		}
+		for register := range liveOut[block] {
+			extend(register, position-1)
+		}
+	}
+
+	sorted := []*Interval{}
+	for _, interval := range intervals {
+		for _, call := range calls {
+			if interval.Start < call && call < interval.End {
+				interval.AcrossCall = true
+			}
+		}
+		sorted = append(sorted, interval)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Start != sorted[j].Start {
+			return sorted[i].Start < sorted[j].Start
+		}
+		return sorted[i].Register < sorted[j].Register
+	})
+	return sorted
+}
+
+// registerLiveness finds the registers needed on the way into and out of each block
+func registerLiveness(procedure *Procedure) (map[*Block]map[Register]bool, map[*Block]map[Register]bool) {
+	liveIn := map[*Block]map[Register]bool{}
+	liveOut := map[*Block]map[Register]bool{}
+	for changed := true; changed; {
+		changed = false
+		for index := len(procedure.Blocks) - 1; index >= 0; index-- {
+			block := procedure.Blocks[index]
+			out := map[Register]bool{}
+			for _, successor := range block.Successors() {
+				for register := range liveIn[successor] {
+					out[register] = true
+				}
+			}
+			live := map[Register]bool{}
+			for register := range out {
+				live[register] = true
+			}
+			for at := len(block.Instructions) - 1; at >= 0; at-- {
+				instruction := block.Instructions[at]
+				if instruction.Dest != NoRegister {
+					delete(live, instruction.Dest)
+				}
+				for _, arg := range instruction.Args {
+					if arg.Kind == RegisterOperand {
+						live[arg.Register] = true
+					}
+				}
+			}
+			liveOut[block] = out
+			if len(live) != len(liveIn[block]) {
+				liveIn[block] = live
+				changed = true
+			}
+		}
+	}
+	return liveIn, liveOut
+}
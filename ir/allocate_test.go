@@ -0,0 +1,107 @@
+package ir
+
+import (
+	"reflect"
+	"testing"
+)
+
+// chain builds a procedure calling double on each value in turn and then adding them up from the
+// last, so every value is needed across the calls after it and the first is needed longest
+func chain(values int) *Procedure {
+	entry := &Block{Name: "entry"}
+	procedure := &Procedure{Name: "chain", Blocks: []*Block{entry}}
+	registers := []Register{}
+	previous := Imm(1)
+	for index := 0; index < values; index++ {
+		register := procedure.NewRegister()
+		entry.Instructions = append(entry.Instructions, &Instruction{Op: Call, Dest: register, Callee: "double", Args: []Operand{previous}})
+		registers = append(registers, register)
+		previous = Reg(register)
+	}
+	total := previous
+	for index := values - 2; index >= 0; index-- {
+		sum := procedure.NewRegister()
+		entry.Instructions = append(entry.Instructions, &Instruction{Op: Add, Dest: sum, Args: []Operand{total, Reg(registers[index])}})
+		total = Reg(sum)
+	}
+	entry.Instructions = append(entry.Instructions, &Instruction{Op: Return, Dest: NoRegister, Args: []Operand{total}})
+	return procedure
+}
+
+func Test_Allocate(t *testing.T) {
+	type args struct {
+		procedure *Procedure
+		registers Registers
+	}
+	tests := []struct {
+		name string
+		args args
+		want *Allocation
+	}{
+		{
+			"Values needed across calls only go in preserved registers",
+			args{chain(2), Registers{Preserved: []string{"rbx"}, Scratch: []string{"r10"}}},
+			&Allocation{
+				Physical:  map[Register]string{0: "rbx", 1: "r10", 2: "r10"},
+				Spilled:   map[Register]int{},
+				Preserved: []string{"rbx"},
+			},
+		},
+		{
+			"The value needed for longest is spilled",
+			args{chain(3), Registers{Preserved: []string{"rbx"}, Scratch: []string{"r10"}}},
+			&Allocation{
+				Physical:   map[Register]string{1: "rbx", 2: "r10", 3: "r10", 4: "r10"},
+				Spilled:    map[Register]int{0: 0},
+				SpillSlots: 1,
+				Preserved:  []string{"rbx"},
+			},
+		},
+		{
+			"Spilled values share slots when they are never needed together",
+			args{chain(3), Registers{}},
+			&Allocation{
+				Physical:   map[Register]string{},
+				Spilled:    map[Register]int{0: 0, 1: 1, 2: 2, 3: 2, 4: 2},
+				SpillSlots: 3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allocate(tt.args.procedure, tt.args.registers, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allocate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_LiveIntervals(t *testing.T) {
+	entry := &Block{Name: "entry"}
+	loop := &Block{Name: "loop"}
+	done := &Block{Name: "done"}
+	procedure := &Procedure{Name: "count", Slots: 1, Blocks: []*Block{entry, loop, done}}
+	limit := procedure.NewRegister()
+	counter := procedure.NewRegister()
+	check := procedure.NewRegister()
+	entry.Instructions = []*Instruction{
+		{Op: Copy, Dest: limit, Args: []Operand{Imm(10)}},
+		{Op: Jump, Dest: NoRegister, Targets: []*Block{loop}},
+	}
+	loop.Instructions = []*Instruction{
+		{Op: Load, Dest: counter, Slot: 0},
+		{Op: Call, Dest: NoRegister, Callee: "printthenumber", Builtin: true, Args: []Operand{Reg(counter)}},
+		{Op: Less, Dest: check, Args: []Operand{Reg(counter), Reg(limit)}},
+		{Op: Branch, Dest: NoRegister, Args: []Operand{Reg(check)}, Targets: []*Block{loop, done}},
+	}
+	done.Instructions = []*Instruction{{Op: Return, Dest: NoRegister, Args: []Operand{Imm(0)}}}
+
+	want := []*Interval{
+		{Register: limit, Start: 0, End: 5, AcrossCall: true},
+		{Register: counter, Start: 2, End: 4, AcrossCall: true},
+		{Register: check, Start: 4, End: 5},
+	}
+	if got := LiveIntervals(procedure); !reflect.DeepEqual(got, want) {
+		t.Errorf("LiveIntervals() = %+v, want %+v", got, want)
+	}
+}
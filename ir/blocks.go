@@ -0,0 +1,86 @@
+package ir
+
+// threadJumps sends jumps and branches aimed at a block that only jumps on straight to where it goes
+func threadJumps(procedure *Procedure) bool {
+	forward := map[*Block]*Block{}
+	for _, block := range procedure.Blocks[1:] {
+		if len(block.Instructions) == 1 && block.Instructions[0].Op == Jump && block.Instructions[0].Targets[0] != block {
+			forward[block] = block.Instructions[0].Targets[0]
+		}
+	}
+	changed := false
+	for _, block := range procedure.Blocks {
+		terminator := block.Terminator()
+		if terminator == nil {
+			continue
+		}
+		for index, target := range terminator.Targets {
+			// visited stops a ring of blocks that only jump to each other
+			visited := map[*Block]bool{}
+			for next, isForwarded := forward[target]; isForwarded && !visited[target]; next, isForwarded = forward[target] {
+				visited[target] = true
+				target = next
+			}
+			if target != terminator.Targets[index] {
+				terminator.Targets[index] = target
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// removeUnreachable drops the blocks that no path from the start of the procedure leads to
+func removeUnreachable(procedure *Procedure) bool {
+	reached := map[*Block]bool{}
+	var visit func(block *Block)
+	visit = func(block *Block) {
+		if reached[block] {
+			return
+		}
+		reached[block] = true
+		for _, successor := range block.Successors() {
+			visit(successor)
+		}
+	}
+	visit(procedure.Blocks[0])
+
+	blocks := []*Block{}
+	for _, block := range procedure.Blocks {
+		if reached[block] {
+			blocks = append(blocks, block)
+		}
+	}
+	changed := len(blocks) != len(procedure.Blocks)
+	procedure.Blocks = blocks
+	return changed
+}
+
+// mergeBlocks joins a block onto the one before it when that is the only way in and it jumps straight there
+func mergeBlocks(procedure *Procedure) bool {
+	predecessors := map[*Block]int{}
+	for _, block := range procedure.Blocks {
+		for _, successor := range block.Successors() {
+			predecessors[successor]++
+		}
+	}
+	changed := false
+	for _, block := range procedure.Blocks {
+		for {
+			terminator := block.Terminator()
+			if terminator == nil || terminator.Op != Jump {
+				break
+			}
+			next := terminator.Targets[0]
+			if next == block || next == procedure.Blocks[0] || predecessors[next] != 1 {
+				break
+			}
+			block.Instructions = append(block.Instructions[:len(block.Instructions)-1], next.Instructions...)
+			// next is left unreachable for removeUnreachable to drop
+			next.Instructions = []*Instruction{{Op: Jump, Dest: NoRegister, Targets: []*Block{next}}}
+			predecessors[next] = 0
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,101 @@
+package ir
+
+// removeDeadStores drops stores to slots that are always stored to again, or never loaded, before they are next loaded
+func removeDeadStores(procedure *Procedure) bool {
+	liveIn := slotLiveness(procedure)
+	changed := false
+	for _, block := range procedure.Blocks {
+		live := liveOut(block, liveIn)
+		kept := []*Instruction{}
+		for index := len(block.Instructions) - 1; index >= 0; index-- {
+			instruction := block.Instructions[index]
+			switch instruction.Op {
+			case Store:
+				if !live[instruction.Slot] {
+					changed = true
+					continue
+				}
+				delete(live, instruction.Slot)
+			case Load:
+				live[instruction.Slot] = true
+			}
+			kept = append(kept, instruction)
+		}
+		for left, right := 0, len(kept)-1; left < right; left, right = left+1, right-1 {
+			kept[left], kept[right] = kept[right], kept[left]
+		}
+		block.Instructions = kept
+	}
+	return changed
+}
+
+// slotLiveness finds the slots each block may load before storing to them, either itself or in a block after it
+func slotLiveness(procedure *Procedure) map[*Block]map[int]bool {
+	liveIn := map[*Block]map[int]bool{}
+	for changed := true; changed; {
+		changed = false
+		for index := len(procedure.Blocks) - 1; index >= 0; index-- {
+			block := procedure.Blocks[index]
+			live := liveOut(block, liveIn)
+			for at := len(block.Instructions) - 1; at >= 0; at-- {
+				instruction := block.Instructions[at]
+				switch instruction.Op {
+				case Store:
+					delete(live, instruction.Slot)
+				case Load:
+					live[instruction.Slot] = true
+				}
+			}
+			if len(live) != len(liveIn[block]) {
+				liveIn[block] = live
+				changed = true
+			}
+		}
+	}
+	return liveIn
+}
+
+// liveOut is every slot live at the start of any block after block
+func liveOut(block *Block, liveIn map[*Block]map[int]bool) map[int]bool {
+	live := map[int]bool{}
+	for _, successor := range block.Successors() {
+		for slot := range liveIn[successor] {
+			live[slot] = true
+		}
+	}
+	return live
+}
+
+// removeDeadCode drops instructions whose result is never used, calls are kept for what they do but lose their result
+func removeDeadCode(procedure *Procedure) bool {
+	changed := false
+	for removed := true; removed; {
+		removed = false
+		uses := map[Register]int{}
+		for _, block := range procedure.Blocks {
+			for _, instruction := range block.Instructions {
+				for _, arg := range instruction.Args {
+					if arg.Kind == RegisterOperand {
+						uses[arg.Register]++
+					}
+				}
+			}
+		}
+		for _, block := range procedure.Blocks {
+			kept := []*Instruction{}
+			for _, instruction := range block.Instructions {
+				if instruction.Dest != NoRegister && uses[instruction.Dest] == 0 {
+					removed = true
+					if instruction.Op != Call {
+						continue
+					}
+					instruction.Dest = NoRegister
+				}
+				kept = append(kept, instruction)
+			}
+			block.Instructions = kept
+		}
+		changed = changed || removed
+	}
+	return changed
+}
@@ -0,0 +1,187 @@
+package ir
+
+import (
+	"math"
+)
+
+// propagate replaces loads of slots whose value is known with copies of that value, and then every use
+// of a copied register with what it copies. Constants stored to a slot are followed from block to block,
+// registers stored to or loaded from a slot only within the block that did it
+func propagate(procedure *Procedure) bool {
+	changed := false
+	entry := slotConstants(procedure)
+	for _, block := range procedure.Blocks {
+		known := map[int]Operand{}
+		for slot, value := range entry[block] {
+			known[slot] = value
+		}
+		for _, instruction := range block.Instructions {
+			switch instruction.Op {
+			case Store:
+				known[instruction.Slot] = instruction.Args[0]
+			case Load:
+				if value, isKnown := known[instruction.Slot]; isKnown {
+					*instruction = Instruction{Op: Copy, Dest: instruction.Dest, Args: []Operand{value}}
+					changed = true
+				} else {
+					// loading the slot again before it is stored to gives the same value
+					known[instruction.Slot] = Reg(instruction.Dest)
+				}
+			}
+		}
+	}
+
+	// every register is only ever given a value once, so a copy can stand in for it everywhere
+	copies := map[Register]Operand{}
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			if instruction.Op == Copy {
+				copies[instruction.Dest] = instruction.Args[0]
+			}
+		}
+	}
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			for index, arg := range instruction.Args {
+				for arg.Kind == RegisterOperand {
+					value, isCopy := copies[arg.Register]
+					if !isCopy {
+						break
+					}
+					arg = value
+				}
+				if arg != instruction.Args[index] {
+					instruction.Args[index] = arg
+					changed = true
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// slotConstants finds the slots holding the same constant whichever way each block is reached
+func slotConstants(procedure *Procedure) map[*Block]map[int]Operand {
+	in := map[*Block]map[int]Operand{procedure.Blocks[0]: {}}
+	out := map[*Block]map[int]Operand{}
+	for changed := true; changed; {
+		changed = false
+		for _, block := range procedure.Blocks {
+			state, reached := in[block]
+			if !reached {
+				continue
+			}
+			after := map[int]Operand{}
+			for slot, value := range state {
+				after[slot] = value
+			}
+			for _, instruction := range block.Instructions {
+				if instruction.Op != Store {
+					continue
+				}
+				if value := instruction.Args[0]; value.Kind != RegisterOperand {
+					after[instruction.Slot] = value
+				} else {
+					delete(after, instruction.Slot)
+				}
+			}
+			if previous, seen := out[block]; seen && sameSlots(previous, after) {
+				continue
+			}
+			out[block] = after
+			for _, successor := range block.Successors() {
+				existing, seen := in[successor]
+				if !seen {
+					merged := map[int]Operand{}
+					for slot, value := range after {
+						merged[slot] = value
+					}
+					in[successor] = merged
+					changed = true
+					continue
+				}
+				for slot, value := range existing {
+					if other, known := after[slot]; !known || other != value {
+						delete(existing, slot)
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return in
+}
+
+func sameSlots(a map[int]Operand, b map[int]Operand) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for slot, value := range a {
+		if other, known := b[slot]; !known || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// fold works out arithmetic on numbers that are already known, and turns branches on them into jumps
+func fold(procedure *Procedure) bool {
+	changed := false
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			args := instruction.Args
+			switch {
+			case instruction.Op == Negate && args[0].Kind == ImmediateOperand:
+				*instruction = Instruction{Op: Copy, Dest: instruction.Dest, Args: []Operand{Imm(-args[0].Value)}}
+				changed = true
+			case isBinary(instruction.Op) && args[0].Kind == ImmediateOperand && args[1].Kind == ImmediateOperand:
+				if value, ok := Evaluate(instruction.Op, args[0].Value, args[1].Value); ok {
+					*instruction = Instruction{Op: Copy, Dest: instruction.Dest, Args: []Operand{Imm(value)}}
+					changed = true
+				}
+			case instruction.Op == Branch && args[0].Kind != RegisterOperand:
+				// strings are never empty addresses, so only a 0 takes the second target
+				target := instruction.Targets[0]
+				if args[0].Kind == ImmediateOperand && args[0].Value == 0 {
+					target = instruction.Targets[1]
+				}
+				*instruction = Instruction{Op: Jump, Dest: NoRegister, Targets: []*Block{target}}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// Evaluate works out a binary op the way the compiled program would, it is false for
+// the divisions that would fault when the program runs rather than give a value
+func Evaluate(op Op, left int64, right int64) (int64, bool) {
+	switch op {
+	case Add:
+		return left + right, true
+	case Subtract:
+		return left - right, true
+	case Multiply:
+		return left * right, true
+	case Divide, Modulo:
+		if right == 0 || (left == math.MinInt64 && right == -1) {
+			return 0, false
+		}
+		if op == Divide {
+			return left / right, true
+		}
+		return left % right, true
+	}
+	results := map[Op]bool{
+		Equal:        left == right,
+		NotEqual:     left != right,
+		Less:         left < right,
+		LessEqual:    left <= right,
+		Greater:      left > right,
+		GreaterEqual: left >= right,
+	}
+	if results[op] {
+		return 1, true
+	}
+	return 0, true
+}
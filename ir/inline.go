@@ -0,0 +1,188 @@
+package ir
+
+import (
+	"strconv"
+)
+
+// inlineLimit is the most instructions a procedure may have to be copied into the procedures calling it
+// rather than called, enough for helpers that work out a value or two and give it back
+const inlineLimit = 16
+
+// inliner copies the bodies of small procedures into the places they are called from
+type inliner struct {
+	program *Program
+	// calls holds the procedures each procedure calls straight away
+	calls map[string][]string
+	// copies counts the bodies copied so far, so every copy gets block names of its own
+	copies int
+}
+
+// inline replaces every call to a procedure small enough and not recursive with a copy of its body,
+// and tells whether it copied any. Procedures that were called before but no longer are get dropped
+func inline(program *Program) bool {
+	i := &inliner{program: program, calls: callGraph(program)}
+	for _, procedure := range program.Procedures {
+		for i.inlineOne(procedure) {
+		}
+	}
+
+	stillCalled := map[string]bool{}
+	for _, callees := range callGraph(program) {
+		for _, callee := range callees {
+			stillCalled[callee] = true
+		}
+	}
+	wasCalled := map[string]bool{}
+	for _, callees := range i.calls {
+		for _, callee := range callees {
+			wasCalled[callee] = true
+		}
+	}
+	kept := []*Procedure{}
+	for _, procedure := range program.Procedures {
+		if !wasCalled[procedure.Name] || stillCalled[procedure.Name] {
+			kept = append(kept, procedure)
+		}
+	}
+	program.Procedures = kept
+	return i.copies > 0
+}
+
+// callGraph finds the procedures each procedure calls straight away
+func callGraph(program *Program) map[string][]string {
+	calls := map[string][]string{}
+	for _, procedure := range program.Procedures {
+		for _, block := range procedure.Blocks {
+			for _, instruction := range block.Instructions {
+				if instruction.Op == Call && !instruction.Builtin {
+					calls[procedure.Name] = append(calls[procedure.Name], instruction.Callee)
+				}
+			}
+		}
+	}
+	return calls
+}
+
+// inlineOne copies the body of the first call in procedure that should be, and tells whether there was one
+func (i *inliner) inlineOne(procedure *Procedure) bool {
+	for blockIndex, block := range procedure.Blocks {
+		for index, instruction := range block.Instructions {
+			if instruction.Op != Call || instruction.Builtin {
+				continue
+			}
+			callee := i.program.Procedure(instruction.Callee)
+			if callee == nil || size(callee) > inlineLimit || i.recursive(callee.Name) {
+				continue
+			}
+			i.copyInto(procedure, blockIndex, index, callee)
+			return true
+		}
+	}
+	return false
+}
+
+// copyInto splits the block at blockIndex around the call at index and puts a copy of callee in between.
+// The copy gets slots of its own, with its result passed back through one more slot
+func (i *inliner) copyInto(procedure *Procedure, blockIndex int, index int, callee *Procedure) {
+	i.copies++
+	prefix := callee.Name + strconv.Itoa(i.copies)
+	block := procedure.Blocks[blockIndex]
+	call := block.Instructions[index]
+
+	slotBase := procedure.Slots
+	result := slotBase + callee.Slots
+	procedure.Slots = result + 1
+	registers := map[Register]Register{}
+	register := func(register Register) Register {
+		if _, exists := registers[register]; !exists {
+			registers[register] = procedure.NewRegister()
+		}
+		return registers[register]
+	}
+	operand := func(operand Operand) Operand {
+		if operand.Kind == RegisterOperand {
+			return Reg(register(operand.Register))
+		}
+		return operand
+	}
+
+	after := &Block{Name: "after" + prefix}
+	if call.Dest != NoRegister {
+		after.Instructions = append(after.Instructions, &Instruction{Op: Load, Dest: call.Dest, Slot: result})
+	}
+	after.Instructions = append(after.Instructions, block.Instructions[index+1:]...)
+
+	copies := map[*Block]*Block{}
+	for _, calleeBlock := range callee.Blocks {
+		copies[calleeBlock] = &Block{Name: prefix + "_" + calleeBlock.Name}
+	}
+	for _, calleeBlock := range callee.Blocks {
+		copied := copies[calleeBlock]
+		for _, instruction := range calleeBlock.Instructions {
+			if instruction.Op == Return {
+				copied.Instructions = append(copied.Instructions,
+					&Instruction{Op: Store, Dest: NoRegister, Slot: result, Args: []Operand{operand(instruction.Args[0])}},
+					&Instruction{Op: Jump, Dest: NoRegister, Targets: []*Block{after}},
+				)
+				continue
+			}
+			clone := *instruction
+			clone.Args = []Operand{}
+			for _, arg := range instruction.Args {
+				clone.Args = append(clone.Args, operand(arg))
+			}
+			if clone.Dest != NoRegister {
+				clone.Dest = register(clone.Dest)
+			}
+			if clone.Op == Load || clone.Op == Store {
+				clone.Slot += slotBase
+			}
+			clone.Targets = []*Block{}
+			for _, target := range instruction.Targets {
+				clone.Targets = append(clone.Targets, copies[target])
+			}
+			copied.Instructions = append(copied.Instructions, &clone)
+		}
+	}
+
+	// the arguments go where the callee expects its parameters on entry
+	block.Instructions = block.Instructions[:index]
+	for param, arg := range call.Args {
+		block.Instructions = append(block.Instructions, &Instruction{Op: Store, Dest: NoRegister, Slot: slotBase + param, Args: []Operand{arg}})
+	}
+	block.Instructions = append(block.Instructions, &Instruction{Op: Jump, Dest: NoRegister, Targets: []*Block{copies[callee.Blocks[0]]}})
+
+	blocks := append([]*Block{}, procedure.Blocks[:blockIndex+1]...)
+	for _, calleeBlock := range callee.Blocks {
+		blocks = append(blocks, copies[calleeBlock])
+	}
+	blocks = append(blocks, after)
+	procedure.Blocks = append(blocks, procedure.Blocks[blockIndex+1:]...)
+}
+
+// recursive is true when name can end up calling itself again
+func (i *inliner) recursive(name string) bool {
+	seen := map[string]bool{}
+	pending := append([]string{}, i.calls[name]...)
+	for len(pending) > 0 {
+		next := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if next == name {
+			return true
+		}
+		if !seen[next] {
+			seen[next] = true
+			pending = append(pending, i.calls[next]...)
+		}
+	}
+	return false
+}
+
+// size counts the instructions of procedure
+func size(procedure *Procedure) int {
+	count := 0
+	for _, block := range procedure.Blocks {
+		count += len(block.Instructions)
+	}
+	return count
+}
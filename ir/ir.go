@@ -0,0 +1,163 @@
+// Package ir holds the three-address code a GaryLang program is lowered into.
+// Optimisations work on it and every backend generates its output from it.
+package ir
+
+import (
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// Register is a virtual register, a procedure has as many as it needs and backends decide where each one lives
+type Register int
+
+// NoRegister is the Dest of an instruction that gives no value
+const NoRegister Register = -1
+
+type OperandKind int
+
+const (
+	RegisterOperand OperandKind = iota
+	ImmediateOperand
+	StringOperand
+)
+
+// Operand is an argument of an instruction: a register, a number or the address of a string constant
+type Operand struct {
+	Kind     OperandKind
+	Register Register
+	// Value is the number of an immediate, or the index into Program.Strings of a string
+	Value int64
+}
+
+func Reg(register Register) Operand {
+	return Operand{Kind: RegisterOperand, Register: register}
+}
+
+func Imm(value int64) Operand {
+	return Operand{Kind: ImmediateOperand, Value: value}
+}
+
+func Str(index int) Operand {
+	return Operand{Kind: StringOperand, Value: int64(index)}
+}
+
+type Op int
+
+const (
+	// Load copies the local in Slot to Dest
+	Load Op = iota
+	// Store copies Args[0] to the local in Slot
+	Store
+	// Copy copies Args[0] to Dest
+	Copy
+	// Add to GreaterEqual combine Args[0] and Args[1] into Dest, comparisons give 1 or 0
+	Add
+	Subtract
+	Multiply
+	Divide
+	Modulo
+	Equal
+	NotEqual
+	Less
+	LessEqual
+	Greater
+	GreaterEqual
+	// Negate puts minus Args[0] in Dest
+	Negate
+	// Call passes Args to Callee and puts its result in Dest, unless Dest is NoRegister
+	Call
+	// Jump continues at Targets[0]
+	Jump
+	// Branch continues at Targets[0] if Args[0] is not zero, and at Targets[1] if it is
+	Branch
+	// Return leaves the procedure with Args[0] as its result
+	Return
+)
+
+// Instruction is a single step of a block, only the fields its Op describes are used
+type Instruction struct {
+	Op   Op
+	Dest Register
+	Args []Operand
+	Slot int
+	// Callee is the procedure or builtin a Call goes to, Builtin tells which
+	Callee  string
+	Builtin bool
+	// Types are the types of the arguments of a Call, builtins pick how they work from them
+	Types   []ast.Type
+	Targets []*Block
+	// Position is where a division or call was written, for the backends that say where a program failed
+	Position ast.Position
+}
+
+// IsTerminator is true for the instructions that end a block
+func (i *Instruction) IsTerminator() bool {
+	return i.Op == Jump || i.Op == Branch || i.Op == Return
+}
+
+// Block is a run of instructions only entered at the top, the last one is always a terminator
+type Block struct {
+	Name         string
+	Instructions []*Instruction
+}
+
+// Terminator is the instruction ending the block, or nil while it is still being built
+func (b *Block) Terminator() *Instruction {
+	if len(b.Instructions) == 0 {
+		return nil
+	}
+	if last := b.Instructions[len(b.Instructions)-1]; last.IsTerminator() {
+		return last
+	}
+	return nil
+}
+
+// Successors are the blocks that can run straight after this one
+func (b *Block) Successors() []*Block {
+	if terminator := b.Terminator(); terminator != nil {
+		return terminator.Targets
+	}
+	return nil
+}
+
+// Procedure is a lowered halfleft. Slots are its locals in memory, the parameters are the first
+// of them and hold the arguments on entry. Blocks[0] is where the procedure starts
+type Procedure struct {
+	Name      string
+	Params    int
+	Slots     int
+	Registers int
+	Blocks    []*Block
+}
+
+// NewRegister gives the procedure another virtual register
+func (p *Procedure) NewRegister() Register {
+	p.Registers++
+	return Register(p.Registers - 1)
+}
+
+// Program is every procedure that can run, thisisthepie first, and the strings they use
+type Program struct {
+	Procedures []*Procedure
+	Strings    []string
+}
+
+// Procedure finds the procedure called name, or nil if there is none
+func (p *Program) Procedure(name string) *Procedure {
+	for _, procedure := range p.Procedures {
+		if procedure.Name == name {
+			return procedure
+		}
+	}
+	return nil
+}
+
+// AddString adds value to the strings of the program unless it is already there, and gives its index
+func (p *Program) AddString(value string) int {
+	for index, existing := range p.Strings {
+		if existing == value {
+			return index
+		}
+	}
+	p.Strings = append(p.Strings, value)
+	return len(p.Strings) - 1
+}
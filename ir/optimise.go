@@ -0,0 +1,118 @@
+package ir
+
+// Pass improves a procedure in place and tells whether it changed anything
+type Pass struct {
+	Name string
+	Run  func(procedure *Procedure) bool
+}
+
+// Passes are run in this order, over and over until none of them changes the procedure
+var Passes = []Pass{
+	{"propagate", propagate},
+	{"fold", fold},
+	{"thread jumps", threadJumps},
+	{"merge blocks", mergeBlocks},
+	{"remove unreachable blocks", removeUnreachable},
+	{"remove dead stores", removeDeadStores},
+	{"remove dead code", removeDeadCode},
+}
+
+// Optimise improves every procedure of program in place. Self-recursive tail calls become jumps at every
+// level, so recursion can always stand in for a loop. Level 0 otherwise leaves the program as it was lowered,
+// level 1 runs every pass, copies small procedures into their callers, runs every pass again and then
+// renumbers what is left so frames only hold what is still used
+func Optimise(program *Program, level int) {
+	for _, procedure := range program.Procedures {
+		eliminateTailCalls(procedure)
+	}
+	if level < 1 {
+		return
+	}
+	for _, procedure := range program.Procedures {
+		improve(procedure)
+	}
+	if inline(program) {
+		for _, procedure := range program.Procedures {
+			improve(procedure)
+		}
+	}
+	for _, procedure := range program.Procedures {
+		compact(procedure)
+	}
+	compactStrings(program)
+}
+
+// improve runs the passes until none of them changes procedure
+func improve(procedure *Procedure) {
+	for changed := true; changed; {
+		changed = false
+		for _, pass := range Passes {
+			if pass.Run(procedure) {
+				changed = true
+			}
+		}
+	}
+}
+
+// isBinary is true for the ops that combine two operands
+func isBinary(op Op) bool {
+	return op >= Add && op <= GreaterEqual
+}
+
+// compact numbers the registers and slots still in use from zero, parameters keep their slots
+func compact(procedure *Procedure) {
+	registers := map[Register]Register{}
+	rename := func(register Register) Register {
+		if _, exists := registers[register]; !exists {
+			registers[register] = Register(len(registers))
+		}
+		return registers[register]
+	}
+	slots := map[int]int{}
+	for index := 0; index < procedure.Params; index++ {
+		slots[index] = index
+	}
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			for index, arg := range instruction.Args {
+				if arg.Kind == RegisterOperand {
+					instruction.Args[index].Register = rename(arg.Register)
+				}
+			}
+			if instruction.Dest != NoRegister {
+				instruction.Dest = rename(instruction.Dest)
+			}
+			if instruction.Op == Load || instruction.Op == Store {
+				if _, exists := slots[instruction.Slot]; !exists {
+					slots[instruction.Slot] = len(slots)
+				}
+				instruction.Slot = slots[instruction.Slot]
+			}
+		}
+	}
+	procedure.Registers = len(registers)
+	procedure.Slots = len(slots)
+}
+
+// compactStrings drops the strings no instruction uses any more
+func compactStrings(program *Program) {
+	strings := []string{}
+	indexes := map[int64]int64{}
+	for _, procedure := range program.Procedures {
+		for _, block := range procedure.Blocks {
+			for _, instruction := range block.Instructions {
+				for index, arg := range instruction.Args {
+					if arg.Kind != StringOperand {
+						continue
+					}
+					if _, exists := indexes[arg.Value]; !exists {
+						indexes[arg.Value] = int64(len(strings))
+						strings = append(strings, program.Strings[arg.Value])
+					}
+					instruction.Args[index].Value = indexes[arg.Value]
+				}
+			}
+		}
+	}
+	program.Strings = strings
+}
@@ -0,0 +1,78 @@
+package ir
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_Evaluate(t *testing.T) {
+	type args struct {
+		op    Op
+		left  int64
+		right int64
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   int64
+		wantOk bool
+	}{
+		{"Add", args{Add, 2, 3}, 5, true},
+		{"Divide rounds towards zero", args{Divide, -7, 2}, -3, true},
+		{"Modulo keeps the sign of the left", args{Modulo, -7, 2}, -1, true},
+		{"Divide by zero", args{Divide, 1, 0}, 0, false},
+		{"Modulo by zero", args{Modulo, 1, 0}, 0, false},
+		{"Overflowing divide", args{Divide, math.MinInt64, -1}, 0, false},
+		{"True comparison", args{LessEqual, 2, 2}, 1, true},
+		{"False comparison", args{Greater, 2, 2}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Evaluate(tt.args.op, tt.args.left, tt.args.right)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Evaluate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_Optimise(t *testing.T) {
+	entry := &Block{Name: "entry"}
+	never := &Block{Name: "never"}
+	done := &Block{Name: "done"}
+	procedure := &Procedure{Name: "pick", Params: 1, Slots: 3, Blocks: []*Block{entry, never, done}}
+	param := procedure.NewRegister()
+	check := procedure.NewRegister()
+	quotient := procedure.NewRegister()
+	printed := procedure.NewRegister()
+	result := procedure.NewRegister()
+	entry.Instructions = []*Instruction{
+		{Op: Store, Dest: NoRegister, Slot: 1, Args: []Operand{Imm(1)}},
+		{Op: Store, Dest: NoRegister, Slot: 2, Args: []Operand{Imm(7)}},
+		{Op: Load, Dest: param, Slot: 0},
+		{Op: Equal, Dest: check, Args: []Operand{Imm(1), Imm(2)}},
+		{Op: Divide, Dest: quotient, Args: []Operand{Imm(1), Imm(0)}},
+		{Op: Call, Dest: printed, Callee: "printthenumber", Builtin: true, Args: []Operand{Reg(quotient)}},
+		{Op: Branch, Dest: NoRegister, Args: []Operand{Reg(check)}, Targets: []*Block{never, done}},
+	}
+	never.Instructions = []*Instruction{{Op: Return, Dest: NoRegister, Args: []Operand{Imm(1)}}}
+	done.Instructions = []*Instruction{
+		{Op: Store, Dest: NoRegister, Slot: 2, Args: []Operand{Reg(param)}},
+		{Op: Load, Dest: result, Slot: 2},
+		{Op: Return, Dest: NoRegister, Args: []Operand{Reg(result)}},
+	}
+	program := &Program{Procedures: []*Procedure{procedure}}
+	Optimise(program, 1)
+
+	want := `
+halfleft pick params 1 slots 1
+entry:
+	r0 = load s0
+	r1 = div 1, 0
+	call builtin printthenumber(r1)
+	return r0
+`
+	if got := program.String(); got != want {
+		t.Errorf("Optimise() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package ir
+
+import (
+	"strconv"
+	"strings"
+)
+
+var opNames = map[Op]string{
+	Load:         "load",
+	Store:        "store",
+	Copy:         "copy",
+	Add:          "add",
+	Subtract:     "sub",
+	Multiply:     "mul",
+	Divide:       "div",
+	Modulo:       "mod",
+	Equal:        "eq",
+	NotEqual:     "ne",
+	Less:         "lt",
+	LessEqual:    "le",
+	Greater:      "gt",
+	GreaterEqual: "ge",
+	Negate:       "neg",
+	Call:         "call",
+	Jump:         "jump",
+	Branch:       "branch",
+	Return:       "return",
+}
+
+func (o Op) String() string {
+	return opNames[o]
+}
+
+func (r Register) String() string {
+	return "r" + strconv.Itoa(int(r))
+}
+
+// String writes a register as r0, a string as str0 and an immediate as its number
+func (o Operand) String() string {
+	switch o.Kind {
+	case RegisterOperand:
+		return o.Register.String()
+	case StringOperand:
+		return "str" + strconv.FormatInt(o.Value, 10)
+	}
+	return strconv.FormatInt(o.Value, 10)
+}
+
+// String writes an instruction the way the listing of a procedure shows it, for example r2 = add r0, 1
+func (i *Instruction) String() string {
+	args := []string{}
+	for _, arg := range i.Args {
+		args = append(args, arg.String())
+	}
+	content := ""
+	if i.Dest != NoRegister {
+		content = i.Dest.String() + " = "
+	}
+	switch i.Op {
+	case Load:
+		return content + "load s" + strconv.Itoa(i.Slot)
+	case Store:
+		return "store s" + strconv.Itoa(i.Slot) + ", " + args[0]
+	case Call:
+		callee := i.Callee
+		if i.Builtin {
+			callee = "builtin " + callee
+		}
+		return content + "call " + callee + "(" + strings.Join(args, ", ") + ")"
+	}
+	for _, target := range i.Targets {
+		args = append(args, target.Name)
+	}
+	return content + i.Op.String() + " " + strings.Join(args, ", ")
+}
+
+// String lists the strings of the program and then each procedure, block by block
+func (p *Program) String() string {
+	content := ""
+	for index, value := range p.Strings {
+		content += "str" + strconv.Itoa(index) + " = " + strconv.Quote(value) + "\n"
+	}
+	for _, procedure := range p.Procedures {
+		content += procedure.String()
+	}
+	return content
+}
+
+func (p *Procedure) String() string {
+	content := "\nhalfleft " + p.Name + " params " + strconv.Itoa(p.Params) + " slots " + strconv.Itoa(p.Slots) + "\n"
+	for _, block := range p.Blocks {
+		content += block.Name + ":\n"
+		for _, instruction := range block.Instructions {
+			content += "\t" + instruction.String() + "\n"
+		}
+	}
+	return content
+}
@@ -0,0 +1,45 @@
+package ir
+
+import (
+	"testing"
+)
+
+func Test_Program_String(t *testing.T) {
+	done := &Block{Name: "done"}
+	entry := &Block{Name: "entry"}
+	procedure := &Procedure{Name: "half", Params: 1, Slots: 1, Blocks: []*Block{entry, done}}
+	value := procedure.NewRegister()
+	half := procedure.NewRegister()
+	entry.Instructions = []*Instruction{
+		{Op: Load, Dest: value, Slot: 0},
+		{Op: Divide, Dest: half, Args: []Operand{Reg(value), Imm(2)}},
+		{Op: Call, Dest: NoRegister, Callee: "printthething", Builtin: true, Args: []Operand{Str(0)}},
+		{Op: Branch, Dest: NoRegister, Args: []Operand{Reg(half)}, Targets: []*Block{done, done}},
+	}
+	done.Instructions = []*Instruction{{Op: Return, Dest: NoRegister, Args: []Operand{Reg(half)}}}
+	program := &Program{Procedures: []*Procedure{procedure}}
+	if index := program.AddString("halved\n"); index != 0 {
+		t.Errorf("AddString() = %v, want 0", index)
+	}
+	if index := program.AddString("halved\n"); index != 0 {
+		t.Errorf("AddString() of the same string again = %v, want 0", index)
+	}
+
+	want := `str0 = "halved\n"
+
+halfleft half params 1 slots 1
+entry:
+	r0 = load s0
+	r1 = div r0, 2
+	call builtin printthething(str0)
+	branch r1, done, done
+done:
+	return r1
+`
+	if got := program.String(); got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+	if got := entry.Successors(); len(got) != 2 || got[0] != done {
+		t.Errorf("Successors() = %v, want the done block twice", got)
+	}
+}
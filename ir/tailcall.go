@@ -0,0 +1,44 @@
+package ir
+
+// eliminateTailCalls turns a procedure giving back the result of calling itself into a jump back to its
+// start, with the arguments stored over its parameters. Recursion that is only ever a tail call then runs
+// in a single frame however deep it goes
+func eliminateTailCalls(procedure *Procedure) bool {
+	var start *Block
+	for _, block := range procedure.Blocks {
+		for index, instruction := range block.Instructions {
+			if !isSelfTailCall(procedure, block, index) {
+				continue
+			}
+			if start == nil {
+				// the entry block has no label, so the old entry becomes a block of its own to jump back to
+				start = procedure.Blocks[0]
+				start.Name = "tailcall"
+				entry := &Block{Name: "entry", Instructions: []*Instruction{{Op: Jump, Dest: NoRegister, Targets: []*Block{start}}}}
+				procedure.Blocks = append([]*Block{entry}, procedure.Blocks...)
+			}
+			// every argument is already worked out, so storing them one after another cannot clobber the next
+			replacement := []*Instruction{}
+			for param, arg := range instruction.Args {
+				replacement = append(replacement, &Instruction{Op: Store, Dest: NoRegister, Slot: param, Args: []Operand{arg}})
+			}
+			replacement = append(replacement, &Instruction{Op: Jump, Dest: NoRegister, Targets: []*Block{start}})
+			block.Instructions = append(block.Instructions[:index], replacement...)
+			break
+		}
+	}
+	return start != nil
+}
+
+// isSelfTailCall is true when the instruction at index in block calls procedure and the next one gives back its result
+func isSelfTailCall(procedure *Procedure, block *Block, index int) bool {
+	call := block.Instructions[index]
+	if call.Op != Call || call.Builtin || call.Callee != procedure.Name || call.Dest == NoRegister {
+		return false
+	}
+	if index+1 >= len(block.Instructions) {
+		return false
+	}
+	next := block.Instructions[index+1]
+	return next.Op == Return && next.Args[0] == Reg(call.Dest)
+}
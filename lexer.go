@@ -0,0 +1,212 @@
+package main
+
+import (
+	"strconv"
+	"unicode"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// Position is where a token starts in the source, the nodes built from it keep the same position
+type Position = ast.Position
+
+// LexError is a problem with the characters of the source rather than with how the tokens fit together
+type LexError struct {
+	Position Position
+	Message  string
+}
+
+func (e *LexError) Error() string {
+	return e.Position.String() + ": " + e.Message
+}
+
+// Runes that are a token on their own, even when written against a word
+var singleRuneTokens = map[rune]TokeType{
+	'£':  ParamOpen,
+	'$':  ParamClose,
+	'#':  EndLine,
+	'/':  BodyStart,
+	'\\': BodyEnd,
+	'(':  GroupOpen,
+	')':  GroupClose,
+	'+':  Plus,
+	'-':  Minus,
+	'*':  Multiply,
+	'%':  Modulo,
+}
+
+// Operators made of one or two of the comparison runes, longest first
+var operatorTokens = []struct {
+	text      string
+	tokenType TokeType
+}{
+	{"==", IsEqual},
+	{"!=", NotEqual},
+	{"<=", LessEqual},
+	{">=", GreaterEqual},
+	{"=", Assign},
+	{"<", LessThan},
+	{">", GreaterThan},
+}
+
+const stringDelimiter = '¬'
+
+// tokenizeFile scans the source of file into tokens, each knowing where it was written
+func tokenizeFile(file string, input string) (*[]Token, error) {
+	l := lexer{input: []rune(input), position: Position{File: file, Line: 1, Column: 1}}
+	tokens := []Token{}
+	for {
+		l.skipSpace()
+		if l.offset >= len(l.input) {
+			return &tokens, nil
+		}
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+type lexer struct {
+	input    []rune
+	offset   int
+	position Position
+}
+
+func (l *lexer) peek(ahead int) rune {
+	if l.offset+ahead >= len(l.input) {
+		return 0
+	}
+	return l.input[l.offset+ahead]
+}
+
+func (l *lexer) advance() rune {
+	r := l.input[l.offset]
+	l.offset++
+	if r == '\n' {
+		l.position.Line++
+		l.position.Column = 1
+	} else {
+		l.position.Column++
+	}
+	return r
+}
+
+func (l *lexer) skipSpace() {
+	for l.offset < len(l.input) && unicode.IsSpace(l.input[l.offset]) {
+		l.advance()
+	}
+}
+
+func (l *lexer) errorAt(position Position, message string) error {
+	return &LexError{Position: position, Message: message}
+}
+
+func (l *lexer) next() (Token, error) {
+	start := l.position
+	r := l.peek(0)
+
+	if r == stringDelimiter {
+		return l.stringLiteral()
+	}
+	if tokenType, exists := singleRuneTokens[r]; exists {
+		l.advance()
+		return Token{Type: tokenType, Position: start}, nil
+	}
+	for _, operator := range operatorTokens {
+		if l.hasPrefix(operator.text) {
+			for range operator.text {
+				l.advance()
+			}
+			return Token{Type: operator.tokenType, Position: start}, nil
+		}
+	}
+	if !isWordRune(r) {
+		return Token{}, l.errorAt(start, "unexpected character "+strconv.QuoteRune(r))
+	}
+
+	word := []rune{}
+	for l.offset < len(l.input) && isWordRune(l.peek(0)) {
+		word = append(word, l.advance())
+	}
+	tok := parseWordToToken(string(word))
+	tok.Position = start
+	return tok, nil
+}
+
+func (l *lexer) hasPrefix(text string) bool {
+	ahead := 0
+	for _, r := range text {
+		if l.peek(ahead) != r {
+			return false
+		}
+		ahead++
+	}
+	return true
+}
+
+// isWordRune is true for the runes of names, keywords and numbers
+func isWordRune(r rune) bool {
+	if r == 0 || r == stringDelimiter || unicode.IsSpace(r) {
+		return false
+	}
+	if _, exists := singleRuneTokens[r]; exists {
+		return false
+	}
+	return r != '=' && r != '!' && r != '<' && r != '>'
+}
+
+// stringLiteral reads ¬...¬ on a single line, decoding its escapes into the bytes they stand for
+func (l *lexer) stringLiteral() (Token, error) {
+	start := l.position
+	l.advance()
+	value := []byte{}
+	for {
+		if l.offset >= len(l.input) || l.peek(0) == '\n' {
+			return Token{}, l.errorAt(start, "unterminated string, expected ¬ before the end of the line")
+		}
+		escapeStart := l.position
+		r := l.advance()
+		if r == stringDelimiter {
+			return Token{Type: StringConst, Value: getAdr(string(value)), Position: start}, nil
+		}
+		if r != '\\' {
+			value = append(value, string(r)...)
+			continue
+		}
+		if l.offset >= len(l.input) || l.peek(0) == '\n' {
+			return Token{}, l.errorAt(start, "unterminated string, expected ¬ before the end of the line")
+		}
+		decoded, err := l.escape(escapeStart)
+		if err != nil {
+			return Token{}, err
+		}
+		value = append(value, decoded...)
+	}
+}
+
+// escape decodes what follows a \ inside a string
+func (l *lexer) escape(start Position) ([]byte, error) {
+	r := l.advance()
+	switch r {
+	case 'n':
+		return []byte{'\n'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case '\\':
+		return []byte{'\\'}, nil
+	case stringDelimiter:
+		return []byte(string(stringDelimiter)), nil
+	case 'x':
+		digits := string([]rune{l.peek(0), l.peek(1)})
+		value, err := strconv.ParseUint(digits, 16, 8)
+		if err != nil {
+			return nil, l.errorAt(start, "\\x must be followed by two hex digits")
+		}
+		l.advance()
+		l.advance()
+		return []byte{byte(value)}, nil
+	}
+	return nil, l.errorAt(start, "unknown escape \\"+string(r))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_tokenizeFile(t *testing.T) {
+	type args struct {
+		input string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantTypes []TokeType
+		wantValue map[int]string
+	}{
+		{
+			"Delimiters written against words",
+			args{"printthething£x$#\tperchance£a<=2$/\\"},
+			[]TokeType{Name, ParamOpen, Name, ParamClose, EndLine, If, ParamOpen, Name, LessEqual, Number, ParamClose, BodyStart, BodyEnd},
+			map[int]string{0: "printthething", 2: "x", 9: "2"},
+		},
+		{
+			"Strings holding delimiters",
+			args{"printthething £ ¬a # b / c $¬ $ #"},
+			[]TokeType{Name, ParamOpen, StringConst, ParamClose, EndLine},
+			map[int]string{2: "a # b / c $"},
+		},
+		{
+			"Empty string",
+			args{"x = ¬¬ #"},
+			[]TokeType{Name, Assign, StringConst, EndLine},
+			map[int]string{2: ""},
+		},
+		{
+			"Escapes",
+			args{`¬line\n\ttab \\ \¬ \x41\x7e¬`},
+			[]TokeType{StringConst},
+			map[int]string{0: "line\n\ttab \\ ¬ A~"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenizeFile("test.gry", tt.args.input)
+			if err != nil {
+				t.Fatalf("tokenizeFile() error = %v", err)
+			}
+			gotTypes := []TokeType{}
+			for _, tok := range *got {
+				gotTypes = append(gotTypes, tok.Type)
+			}
+			if !reflect.DeepEqual(gotTypes, tt.wantTypes) {
+				t.Errorf("tokenizeFile() types = %v, want %v", gotTypes, tt.wantTypes)
+			}
+			for index, want := range tt.wantValue {
+				if value := (*got)[index].Value; value == nil || *value != want {
+					t.Errorf("tokenizeFile() token %v = %v, want %q", index, value, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_tokenizeFile_positions(t *testing.T) {
+	got, err := tokenizeFile("test.gry", "halfleft £\n\t¬é¬ $")
+	if err != nil {
+		t.Fatalf("tokenizeFile() error = %v", err)
+	}
+	want := []Position{
+		{File: "test.gry", Line: 1, Column: 1},
+		{File: "test.gry", Line: 1, Column: 10},
+		{File: "test.gry", Line: 2, Column: 2},
+		{File: "test.gry", Line: 2, Column: 6},
+	}
+	for index, tok := range *got {
+		if tok.Position != want[index] {
+			t.Errorf("tokenizeFile() token %v at %v, want %v", index, tok.Position, want[index])
+		}
+	}
+}
+
+func Test_tokenizeFile_errors(t *testing.T) {
+	type args struct {
+		input string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Unterminated string",
+			args{"x = 1 #\nprintthething £ ¬oops $ #\n"},
+			"test.gry:2:17: unterminated string, expected ¬ before the end of the line",
+		},
+		{
+			"Unterminated string at the end of the file",
+			args{`y = ¬trailing\`},
+			"test.gry:1:5: unterminated string, expected ¬ before the end of the line",
+		},
+		{
+			"Unknown escape",
+			args{`¬\q¬`},
+			"test.gry:1:2: unknown escape \\q",
+		},
+		{
+			"Short hex escape",
+			args{`¬\x4¬`},
+			"test.gry:1:2: \\x must be followed by two hex digits",
+		},
+		{
+			"Lone exclamation mark",
+			args{"a ! b"},
+			"test.gry:1:3: unexpected character '!'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokenizeFile("test.gry", tt.args.input)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("tokenizeFile() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package linuxAsmFiles 
+
+const (
+Alignconstbytes = `align 16 ; align data constants to the 16 byte boundary
+`
+Allocatestack = `; -----------------------------------------------------------------------------
+; Allocate stack memory
+; The frame below the saved rbp holds the variables first, then the saved
+; registers, then room for the parameters that are passed on the stack.
+; The frame size is always a multiple of 16, so together with the pushed rbp
+; the stack stays aligned on the 16 byte boundary.
+; -----------------------------------------------------------------------------
+push rbp
+mov rbp,rsp
+sub rsp,$frameSize
+`
+C = `; ---------------------------------------------------------------------------
+; C management
+; ---------------------------------------------------------------------------
+global main
+`
+Codesection = `; ---------------------------------------------------------------------------
+; Code segment:
+; ---------------------------------------------------------------------------
+section .text
+`
+Datasection = `; ---------------------------------------------------------------------------
+; Data segment:
+; ---------------------------------------------------------------------------
+section .data
+`
+End = `
+; -----------------------------------------------------------------------------
+; Mark the stack as non-executable for the linker
+; -----------------------------------------------------------------------------
+section .note.GNU-stack noalloc noexec nowrite progbits
+
+; ----
+; END ----
+; ----
+`
+Exit = `; -----------------------------------------------------------------------------
+; Quit
+; -----------------------------------------------------------------------------
+ret
+`
+Releasestack = `; -----------------------------------------------------------------------------
+; Release stack memory
+; -----------------------------------------------------------------------------
+mov rsp,rbp
+pop rbp
+`
+Runtime = `; ---------------------------------------------------------------------------
+; Runtime for static executables
+; Stands in for the C library when linking without gcc: _start calls main
+; and exits with what it gives back, printf writes straight to stdout and
+; malloc and free hand out memory mapped from the kernel.
+; ---------------------------------------------------------------------------
+bits 64
+section .data
+freelist: dq 0
+heapnext: dq 0
+heapend: dq 0
+section .text
+global _start
+global printf
+global malloc
+global free
+extern main
+; -----------------------------------------------------------------------------
+; Entry point: [rsp] is argc with argv after it
+; -----------------------------------------------------------------------------
+_start:
+xor ebp,ebp
+mov rdi,qword [rsp]
+lea rsi,[rsp+8]
+and rsp,-16
+call main
+mov rdi,rax
+mov eax,60
+syscall
+; -----------------------------------------------------------------------------
+; printf understands %d, %i, %s, %c and %% with any number of l in front,
+; anything else is written as it is. Gives back the number of bytes written
+; -----------------------------------------------------------------------------
+printf:
+push rbp
+mov rbp,rsp
+; the register arguments go next to each other below rbp, in order
+push r9
+push r8
+push rcx
+push rdx
+push rsi
+push rbx
+push r12
+push r13
+push r14
+; [rbp-112] to [rbp-72] is room to write a number
+sub rsp,40
+; rbx walks the format, r12 points at the next argument, r13 counts the bytes
+; written and r14 is where the conversion being read started
+mov rbx,rdi
+lea r12,[rbp-40]
+xor r13d,r13d
+.next:
+movzx rax,byte [rbx]
+test rax,rax
+jz .done
+cmp rax,37
+je .conversion
+mov rsi,rbx
+.literal:
+add rbx,1
+movzx rax,byte [rbx]
+test rax,rax
+jz .writeliteral
+cmp rax,37
+jne .literal
+.writeliteral:
+mov rdx,rbx
+sub rdx,rsi
+call .write
+jmp .next
+.conversion:
+mov r14,rbx
+add rbx,1
+movzx rax,byte [rbx]
+cmp rax,37
+je .percent
+cmp rax,115
+je .string
+cmp rax,99
+je .character
+.length:
+cmp rax,108
+jne .decimal
+add rbx,1
+movzx rax,byte [rbx]
+jmp .length
+.decimal:
+cmp rax,100
+je .number
+cmp rax,105
+je .number
+; not a conversion after all, so it is written as it is up to where it stopped
+mov rsi,r14
+mov rdx,rbx
+sub rdx,r14
+call .write
+jmp .next
+.percent:
+mov rsi,rbx
+mov edx,1
+add rbx,1
+call .write
+jmp .next
+.string:
+add rbx,1
+call .argument
+mov rsi,rax
+mov rdx,rax
+.length0:
+movzx rax,byte [rdx]
+test rax,rax
+jz .writestring
+add rdx,1
+jmp .length0
+.writestring:
+sub rdx,rsi
+call .write
+jmp .next
+.character:
+add rbx,1
+call .argument
+lea rsi,[rbp-112]
+mov byte [rsi],al
+mov edx,1
+call .write
+jmp .next
+.number:
+add rbx,1
+call .argument
+; the digits are written from the end of the room backwards
+lea rsi,[rbp-72]
+mov r8,rax
+test rax,rax
+jns .digits
+neg rax
+.digits:
+mov ecx,10
+.digit:
+xor edx,edx
+div rcx
+add rdx,48
+sub rsi,1
+mov byte [rsi],dl
+test rax,rax
+jnz .digit
+test r8,r8
+jns .writenumber
+mov edx,45
+sub rsi,1
+mov byte [rsi],dl
+.writenumber:
+lea rdx,[rbp-72]
+sub rdx,rsi
+call .write
+jmp .next
+.done:
+mov rax,r13
+mov rbx,qword [rbp-48]
+mov r12,qword [rbp-56]
+mov r13,qword [rbp-64]
+mov r14,qword [rbp-72]
+mov rsp,rbp
+pop rbp
+ret
+; rax is the next argument, after the five in registers come the ones the
+; caller left on the stack above the return address
+.argument:
+mov rax,qword [r12]
+add r12,8
+cmp r12,rbp
+jne .gotargument
+lea r12,[rbp+16]
+.gotargument:
+ret
+; writes rdx bytes from rsi to stdout, adding them to the count
+.write:
+test rdx,rdx
+jle .written
+mov eax,1
+mov edi,1
+syscall
+test rax,rax
+jle .written
+add r13,rax
+add rsi,rax
+sub rdx,rax
+jmp .write
+.written:
+ret
+; -----------------------------------------------------------------------------
+; malloc gives back rdi bytes, or 0 when there is no more memory. Each block
+; has its size in the 16 bytes in front of it, which hold the link to the next
+; block while it is on the free list
+; -----------------------------------------------------------------------------
+malloc:
+add rdi,31
+and rdi,-16
+; the first free block big enough is taken whole, rcx is the link pointing at it
+lea rcx,[rel freelist]
+.search:
+mov rax,qword [rcx]
+test rax,rax
+jz .bump
+cmp qword [rax],rdi
+jae .take
+lea rcx,[rax+8]
+jmp .search
+.take:
+mov rdx,qword [rax+8]
+mov qword [rcx],rdx
+add rax,16
+ret
+.bump:
+mov rax,qword [rel heapnext]
+mov rdx,rax
+add rdx,rdi
+cmp rdx,qword [rel heapend]
+ja .grow
+mov qword [rel heapnext],rdx
+mov qword [rax],rdi
+add rax,16
+ret
+; maps a megabyte or the block if it is bigger, what was left of the last
+; mapping is never used
+.grow:
+push rdi
+mov rsi,rdi
+cmp rsi,1048576
+jae .map
+mov rsi,1048576
+.map:
+push rsi
+xor edi,edi
+mov edx,3
+mov r10,34
+mov r8,-1
+xor r9d,r9d
+mov eax,9
+syscall
+pop rsi
+pop rdi
+cmp rax,-4096
+ja .failed
+mov qword [rel heapnext],rax
+add rsi,rax
+mov qword [rel heapend],rsi
+jmp .bump
+.failed:
+xor eax,eax
+ret
+; -----------------------------------------------------------------------------
+; free puts the block at rdi on the free list
+; -----------------------------------------------------------------------------
+free:
+test rdi,rdi
+jz .freed
+sub rdi,16
+mov rax,qword [rel freelist]
+mov qword [rdi+8],rax
+mov qword [rel freelist],rdi
+.freed:
+ret
+`
+Start64bit = `; ---------------------------------------------------------------------------
+; Tell compiler to generate 64 bit code
+; ---------------------------------------------------------------------------
+bits 64
+`
+)
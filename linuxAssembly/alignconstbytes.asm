@@ -0,0 +1 @@
+align 16 ; align data constants to the 16 byte boundary
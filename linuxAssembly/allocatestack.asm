@@ -0,0 +1,10 @@
+; -----------------------------------------------------------------------------
+; Allocate stack memory
+; The frame below the saved rbp holds the variables first, then the saved
+; registers, then room for the parameters that are passed on the stack.
+; The frame size is always a multiple of 16, so together with the pushed rbp
+; the stack stays aligned on the 16 byte boundary.
+; -----------------------------------------------------------------------------
+push rbp
+mov rbp,rsp
+sub rsp,$frameSize
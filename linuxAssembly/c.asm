@@ -0,0 +1,4 @@
+; ---------------------------------------------------------------------------
+; C management
+; ---------------------------------------------------------------------------
+global main
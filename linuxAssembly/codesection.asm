@@ -0,0 +1,4 @@
+; ---------------------------------------------------------------------------
+; Code segment:
+; ---------------------------------------------------------------------------
+section .text
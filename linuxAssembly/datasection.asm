@@ -0,0 +1,4 @@
+; ---------------------------------------------------------------------------
+; Data segment:
+; ---------------------------------------------------------------------------
+section .data
@@ -0,0 +1,9 @@
+
+; -----------------------------------------------------------------------------
+; Mark the stack as non-executable for the linker
+; -----------------------------------------------------------------------------
+section .note.GNU-stack noalloc noexec nowrite progbits
+
+; ----
+; END ----
+; ----
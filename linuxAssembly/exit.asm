@@ -0,0 +1,4 @@
+; -----------------------------------------------------------------------------
+; Quit
+; -----------------------------------------------------------------------------
+ret
@@ -0,0 +1,5 @@
+; -----------------------------------------------------------------------------
+; Release stack memory
+; -----------------------------------------------------------------------------
+mov rsp,rbp
+pop rbp
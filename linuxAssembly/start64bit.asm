@@ -0,0 +1,4 @@
+; ---------------------------------------------------------------------------
+; Tell compiler to generate 64 bit code
+; ---------------------------------------------------------------------------
+bits 64
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/linuxAsmFiles"
+	"github.com/Jordank321/GaryLang/x86"
+)
+
+func init() {
+	RegisterBackend("linux-amd64", &nasmBackend{
+		Start64bit:      linuxAsmFiles.Start64bit,
+		Datasection:     linuxAsmFiles.Datasection,
+		Alignconstbytes: linuxAsmFiles.Alignconstbytes,
+		Codesection:     linuxAsmFiles.Codesection,
+		C:               linuxAsmFiles.C,
+		Allocatestack:   linuxAsmFiles.Allocatestack,
+		Releasestack:    linuxAsmFiles.Releasestack,
+		Exit:            linuxAsmFiles.Exit,
+		End:             linuxAsmFiles.End,
+
+		ArgumentRegisters: []string{"rdi", "rsi", "rdx", "rcx", "r8", "r9"},
+		ShadowSpace:       0,
+		// calls fill the argument registers one after another and every instruction
+		// works in rax, rcx and rdx, so none of those are handed out
+		PreservedRegisters: []string{"rbx", "r12", "r13", "r14", "r15"},
+		ScratchRegisters:   []string{"r10", "r11"},
+
+		ObjectFormat:        "elf64",
+		ObjectExtension:     ".o",
+		ExecutableExtension: "",
+		LinkFlags:           []string{"-m64", "-no-pie"},
+		Assemble:            assembleELF64,
+		LinkStatic:          linkStaticELF64,
+	})
+}
+
+// linkStaticELF64 assembles source and the runtime standing in for the C library and links them
+// into an executable starting at the _start of the runtime
+func linkStaticELF64(source string) ([]byte, error) {
+	program, err := x86.Assemble(source)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := x86.Assemble(linuxAsmFiles.Runtime)
+	if err != nil {
+		return nil, err
+	}
+	return x86.Link("_start", program, runtime)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func Test_linkStaticELF64(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("running the program needs linux-amd64")
+	}
+	type args struct {
+		main string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		want     string
+		wantExit int
+	}{
+		{
+			"printf conversions",
+			args{`lea rdi,[rel format]
+lea rsi,[rel word]
+mov edx,33
+mov rcx,-9223372036854775808
+mov r8,7
+mov r9,8
+mov qword [rsp],9
+call printf`},
+			"hi! -9223372036854775808 7 8 9 100%",
+			35,
+		},
+		{
+			"printf gives back the bytes written",
+			args{`lea rdi,[rel word]
+call printf`},
+			"hi",
+			2,
+		},
+		{
+			"free hands the block back to malloc",
+			args{`mov rdi,100
+call malloc
+mov qword [rsp],rax
+mov qword [rax+96],rax
+mov rdi,rax
+call free
+mov rdi,50
+call malloc
+cmp rax,qword [rsp]
+sete al
+movzx rax,al`},
+			"",
+			1,
+		},
+		{
+			"malloc maps more than a megabyte when asked",
+			args{`mov rdi,3000000
+call malloc
+mov qword [rax+2999992],rax
+test rax,rax
+setne al
+movzx rax,al`},
+			"",
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := `bits 64
+section .data
+format: db "%s%c %lld %d %i %ld 100%",0
+word: db "hi",0
+section .text
+global main
+extern printf
+extern malloc
+extern free
+main:
+push rbp
+mov rbp,rsp
+sub rsp,16
+` + tt.args.main + `
+mov rsp,rbp
+pop rbp
+ret
+`
+			executable, err := linkStaticELF64(source)
+			if err != nil {
+				t.Fatalf("linkStaticELF64() error = %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "program")
+			if err := ioutil.WriteFile(path, executable, 0755); err != nil {
+				t.Fatal(err)
+			}
+			got, err := exec.Command(path).Output()
+			exit := 0
+			if exitError, exited := err.(*exec.ExitError); exited {
+				exit = exitError.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || exit != tt.wantExit {
+				t.Errorf("program printed %q and exited with %d, want %q and %d", got, exit, tt.want, tt.wantExit)
+			}
+		})
+	}
+}
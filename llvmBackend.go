@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+var llvmOps = map[ir.Op]string{
+	ir.Add:      "add",
+	ir.Subtract: "sub",
+	ir.Multiply: "mul",
+	ir.Divide:   "sdiv",
+	ir.Modulo:   "srem",
+}
+
+var llvmComparisons = map[ir.Op]string{
+	ir.Equal:        "eq",
+	ir.NotEqual:     "ne",
+	ir.Less:         "slt",
+	ir.LessEqual:    "sle",
+	ir.Greater:      "sgt",
+	ir.GreaterEqual: "sge",
+}
+
+// llvmExterns are the declarations of the C library functions builtins can depend on
+var llvmExterns = map[string]string{
+	"printf": "declare i32 @printf(i8*, ...)",
+	"malloc": "declare i8* @malloc(i64)",
+	"free":   "declare void @free(i8*)",
+	// llvm.trap stops the program, for what would fault a compiled program
+	"llvm.trap": "declare void @llvm.trap() noreturn nounwind",
+}
+
+func init() {
+	RegisterProgramBackend("llvm", llvmBackend{})
+}
+
+// llvmBackend writes the optimised IR of the program as textual LLVM IR, for clang or llc to make
+// native code of for whichever machine they target. Every value is an i64, strings being the address
+// of their bytes. Adding, subtracting and multiplying wrap around on overflow, and dividing by zero,
+// or the smallest number by -1, traps like the native targets fault rather than being left undefined
+type llvmBackend struct{}
+
+func (llvmBackend) Build(program *ast.Program, lowered *ir.Program, sourcePath string, options BuildOptions) (string, error) {
+	if options.Assembler != "" || options.Linker != "" {
+		return "", fmt.Errorf("llvm only writes a .ll file, which is not assembled or linked")
+	}
+	outputPath := strings.TrimSuffix(sourcePath, ".gry") + ".ll"
+	return outputPath, ioutil.WriteFile(outputPath, []byte(compileLLVM(lowered)), 0644)
+}
+
+// llvmCompiler writes the procedures of the IR as LLVM functions
+type llvmCompiler struct {
+	program *ir.Program
+	// constants are the lengths of the constants of the program, by name, the builtins refer to theirs
+	constants map[string]int
+	body      strings.Builder
+	// copies are what each register copied from a value stands for, the IR only gives a register a
+	// value once so they can be used in its place
+	copies map[ir.Register]ir.Operand
+	// temporaries counts the values the procedure needs that are not its registers
+	temporaries int
+	// traps is set once the procedure has a division that can branch to its trap block
+	traps bool
+}
+
+// compileLLVM writes the constants of the program, declares the C functions its builtins use and
+// defines a function for each procedure. main runs thisisthepie, exiting with what it gives back
+func compileLLVM(program *ir.Program) string {
+	c := &llvmCompiler{program: program, constants: map[string]int{}}
+	c.line("; compiled by garylang, every value is an i64 and strings are the address of their bytes")
+	c.line("")
+	for index, value := range program.Strings {
+		c.constant(stringConstantName(index), append([]byte(value), 0))
+	}
+	builtins := *usedBuiltinFunctions(program, &[]string{})
+	externs := []string{}
+	for _, builtin := range builtins {
+		constants := GetStandardFunctionConstants(builtin)
+		names := []string{}
+		for name := range constants {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, exists := c.constants[name]; !exists {
+				c.constant(name, constants[name])
+			}
+		}
+		for _, extern := range GetStandardFunctionExterns(builtin) {
+			externs = appendIfMissing(externs, extern)
+		}
+	}
+	if len(c.constants) > 0 {
+		c.line("")
+	}
+	for _, procedure := range program.Procedures {
+		for _, block := range procedure.Blocks {
+			for _, instruction := range block.Instructions {
+				if llvmDivisionChecked(instruction) {
+					externs = appendIfMissing(externs, "llvm.trap")
+				}
+			}
+		}
+	}
+	sort.Strings(externs)
+	for _, extern := range externs {
+		c.line(llvmExterns[extern])
+	}
+	if len(externs) > 0 {
+		c.line("")
+	}
+	for _, procedure := range program.Procedures {
+		c.procedure(procedure)
+	}
+	c.line("define i32 @main() {")
+	c.line("\t%%result = call i64 %s()", llvmProcedureName("thisisthepie"))
+	c.line("\t%%status = trunc i64 %%result to i32")
+	c.line("\tret i32 %%status")
+	c.line("}")
+	return c.body.String()
+}
+
+func (c *llvmCompiler) line(format string, args ...interface{}) {
+	fmt.Fprintf(&c.body, format+"\n", args...)
+}
+
+func (c *llvmCompiler) constant(name string, value []byte) {
+	c.constants[name] = len(value)
+	c.line("@%s = private unnamed_addr constant [%d x i8] c\"%s\"", name, len(value), llvmString(string(value)))
+}
+
+// pointer is an i8* to the start of the constant called name
+func (c *llvmCompiler) pointer(name string) string {
+	array := "[" + strconv.Itoa(c.constants[name]) + " x i8]"
+	return "getelementptr inbounds (" + array + ", " + array + "* @" + name + ", i64 0, i64 0)"
+}
+
+// temporary names another value, one that is not a register of the procedure
+func (c *llvmCompiler) temporary() string {
+	c.temporaries++
+	return "%t" + strconv.Itoa(c.temporaries-1)
+}
+
+// toPointer turns value, the address of a string, into an i8*
+func (c *llvmCompiler) toPointer(value string) string {
+	pointer := c.temporary()
+	c.line("\t%s = inttoptr i64 %s to i8*", pointer, value)
+	return pointer
+}
+
+// printf calls printf with the format and the typed args, putting what it gives back in dest if the
+// call has one
+func (c *llvmCompiler) printf(dest string, format string, args ...string) {
+	printed := c.temporary()
+	if dest == "" {
+		dest = c.temporary()
+	}
+	c.line("\t%s = call i32 (i8*, ...) @printf(%s)", printed, strings.Join(append([]string{"i8* " + format}, args...), ", "))
+	c.line("\t%s = sext i32 %s to i64", dest, printed)
+}
+
+// procedure writes procedure as an internal function. Its slots are allocated in the entry block,
+// which nothing jumps back to, and the parameters are stored in the first of them
+func (c *llvmCompiler) procedure(procedure *ir.Procedure) {
+	c.temporaries = 0
+	c.traps = false
+	c.copies = map[ir.Register]ir.Operand{}
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			if instruction.Op == ir.Copy {
+				c.copies[instruction.Dest] = instruction.Args[0]
+			}
+		}
+	}
+
+	params := []string{}
+	for index := 0; index < procedure.Params; index++ {
+		params = append(params, "i64 %p"+strconv.Itoa(index))
+	}
+	c.line("define internal i64 %s(%s) {", llvmProcedureName(procedure.Name), strings.Join(params, ", "))
+	for index, block := range procedure.Blocks {
+		c.line("%s:", llvmLabel(block.Name))
+		if index == 0 {
+			for slot := 0; slot < procedure.Slots; slot++ {
+				c.line("\t%%s%d = alloca i64", slot)
+			}
+			for param := 0; param < procedure.Params; param++ {
+				c.line("\tstore i64 %%p%d, i64* %%s%d", param, param)
+			}
+		}
+		for _, instruction := range block.Instructions {
+			c.instruction(instruction)
+		}
+	}
+	if c.traps {
+		c.line("trap:")
+		c.line("\tcall void @llvm.trap()")
+		c.line("\tunreachable")
+	}
+	c.line("}")
+	c.line("")
+}
+
+// operand is the i64 value of operand, a string being the address of its constant
+func (c *llvmCompiler) operand(operand ir.Operand) string {
+	switch operand.Kind {
+	case ir.RegisterOperand:
+		if value, isCopy := c.copies[operand.Register]; isCopy {
+			return c.operand(value)
+		}
+		return "%r" + strconv.Itoa(int(operand.Register))
+	case ir.StringOperand:
+		name := stringConstantName(int(operand.Value))
+		return "ptrtoint ([" + strconv.Itoa(c.constants[name]) + " x i8]* @" + name + " to i64)"
+	}
+	return strconv.FormatInt(operand.Value, 10)
+}
+
+func (c *llvmCompiler) instruction(instruction *ir.Instruction) {
+	args := []string{}
+	for _, arg := range instruction.Args {
+		args = append(args, c.operand(arg))
+	}
+	dest := ""
+	if instruction.Dest != ir.NoRegister {
+		dest = "%r" + strconv.Itoa(int(instruction.Dest))
+	}
+	switch instruction.Op {
+	case ir.Load:
+		c.line("\t%s = load i64, i64* %%s%d", dest, instruction.Slot)
+	case ir.Store:
+		c.line("\tstore i64 %s, i64* %%s%d", args[0], instruction.Slot)
+	case ir.Copy:
+		// uses of the register are given the value it copies instead
+	case ir.Negate:
+		c.line("\t%s = sub i64 0, %s", dest, args[0])
+	case ir.Call:
+		if instruction.Builtin {
+			GetStandardFunctionLLVM(GetStandardFunction(instruction.Callee).AssembledBodyName)(c, dest, args, instruction.Types)
+			return
+		}
+		typed := []string{}
+		for _, arg := range args {
+			typed = append(typed, "i64 "+arg)
+		}
+		call := "call i64 " + llvmProcedureName(instruction.Callee) + "(" + strings.Join(typed, ", ") + ")"
+		if dest == "" {
+			c.line("\t%s", call)
+			return
+		}
+		c.line("\t%s = %s", dest, call)
+	case ir.Jump:
+		c.line("\tbr label %s", llvmLabelOperand(instruction.Targets[0].Name))
+	case ir.Branch:
+		condition := c.temporary()
+		c.line("\t%s = icmp ne i64 %s, 0", condition, args[0])
+		c.line("\tbr i1 %s, label %s, label %s", condition, llvmLabelOperand(instruction.Targets[0].Name), llvmLabelOperand(instruction.Targets[1].Name))
+	case ir.Return:
+		c.line("\tret i64 %s", args[0])
+	default:
+		if op, isArithmetic := llvmOps[instruction.Op]; isArithmetic {
+			if llvmDivisionChecked(instruction) {
+				c.checkDivision(args[0], args[1])
+			}
+			c.line("\t%s = %s i64 %s, %s", dest, op, args[0], args[1])
+			return
+		}
+		// comparisons give an i1, which is widened to 1 or 0
+		compared := c.temporary()
+		c.line("\t%s = icmp %s i64 %s, %s", compared, llvmComparisons[instruction.Op], args[0], args[1])
+		c.line("\t%s = zext i1 %s to i64", dest, compared)
+	}
+}
+
+// llvmDivisionChecked is true for the divisions that are checked before they are made, those whose
+// divisor is not a number other than 0 and -1
+func llvmDivisionChecked(instruction *ir.Instruction) bool {
+	if instruction.Op != ir.Divide && instruction.Op != ir.Modulo {
+		return false
+	}
+	divisor := instruction.Args[1]
+	return divisor.Kind != ir.ImmediateOperand || divisor.Value == 0 || divisor.Value == -1
+}
+
+// checkDivision branches to the trap block when left cannot be divided by right, as sdiv and srem
+// leave dividing by zero and the smallest number by -1 undefined. The division goes in a block of
+// its own after the branch
+func (c *llvmCompiler) checkDivision(left string, right string) {
+	byZero := c.temporary()
+	c.line("\t%s = icmp eq i64 %s, 0", byZero, right)
+	smallest := c.temporary()
+	c.line("\t%s = icmp eq i64 %s, %d", smallest, left, int64(math.MinInt64))
+	minusOne := c.temporary()
+	c.line("\t%s = icmp eq i64 %s, -1", minusOne, right)
+	overflows := c.temporary()
+	c.line("\t%s = and i1 %s, %s", overflows, smallest, minusOne)
+	fails := c.temporary()
+	c.line("\t%s = or i1 %s, %s", fails, byZero, overflows)
+	divide := "divide" + strconv.Itoa(c.temporaries)
+	c.line("\tbr i1 %s, label %%trap, label %s", fails, llvmLabelOperand(divide))
+	c.line("%s:", llvmLabel(divide))
+	c.traps = true
+}
+
+// llvmProcedureName is the global name of the function a procedure becomes. The . keeps it apart
+// from anything in the C library
+func llvmProcedureName(name string) string {
+	return "@" + llvmIdentifier("halfleft."+name)
+}
+
+// llvmLabel is how a block called name is labelled, and llvmLabelOperand how a branch refers to it
+func llvmLabel(name string) string {
+	return llvmIdentifier(name)
+}
+
+func llvmLabelOperand(name string) string {
+	return "%" + llvmIdentifier(name)
+}
+
+// llvmIdentifier is name as it is written after an @ or %, in quotes unless it is only made of the
+// characters LLVM allows in a name without them
+func llvmIdentifier(name string) string {
+	for index, b := range []byte(name) {
+		if !(b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == '.' || b == '_' || b == '$' || b == '-' || b >= '0' && b <= '9' && index > 0) {
+			return "\"" + llvmString(name) + "\""
+		}
+	}
+	return name
+}
+
+// llvmString is value as it is written between the quotes of an LLVM string, every byte that is not
+// printable ascii, and " and \, is written as \ and two hex digits
+func llvmString(value string) string {
+	literal := ""
+	for _, b := range []byte(value) {
+		if b < ' ' || b > '~' || b == '"' || b == '\\' {
+			literal += fmt.Sprintf("\\%02X", b)
+		} else {
+			literal += string(b)
+		}
+	}
+	return literal
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+func Test_compileLLVM(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	i = 0 #
+	whilst £ i < 2 $ /
+		printthevalue £ ¬"a"\\\n¬ $ #
+		i = i + 1 #
+	\
+	perchance £ i == 2 $ /
+		giveback neg £ i $ #
+	\
+	printthenumber £ 5 $ #
+\
+halfleft neg £ n $ /
+	giveback - n #
+\`
+	want := `; compiled by garylang, every value is an i64 and strings are the address of their bytes
+
+@p0 = private unnamed_addr constant [6 x i8] c"\22a\22\5C\0A\00"
+@numberformat = private unnamed_addr constant [5 x i8] c"%lld\00"
+@stringformat = private unnamed_addr constant [3 x i8] c"%s\00"
+
+declare i32 @printf(i8*, ...)
+
+define internal i64 @halfleft.thisisthepie() {
+entry:
+	%s0 = alloca i64
+	store i64 0, i64* %s0
+	br label %whilst1
+whilst1:
+	%r0 = load i64, i64* %s0
+	%t0 = icmp slt i64 %r0, 2
+	%r1 = zext i1 %t0 to i64
+	%t1 = icmp ne i64 %r1, 0
+	br i1 %t1, label %body1, label %endwhilst1
+body1:
+	%t2 = inttoptr i64 ptrtoint ([6 x i8]* @p0 to i64) to i8*
+	%t3 = call i32 (i8*, ...) @printf(i8* getelementptr inbounds ([3 x i8], [3 x i8]* @stringformat, i64 0, i64 0), i8* %t2)
+	%t4 = sext i32 %t3 to i64
+	%r2 = load i64, i64* %s0
+	%r3 = add i64 %r2, 1
+	store i64 %r3, i64* %s0
+	br label %whilst1
+endwhilst1:
+	%r4 = load i64, i64* %s0
+	%t5 = icmp eq i64 %r4, 2
+	%r5 = zext i1 %t5 to i64
+	%t6 = icmp ne i64 %r5, 0
+	br i1 %t6, label %then2, label %endperchance2
+then2:
+	%r7 = load i64, i64* %s0
+	%r6 = call i64 @halfleft.neg(i64 %r7)
+	ret i64 %r6
+endperchance2:
+	%t7 = call i32 (i8*, ...) @printf(i8* getelementptr inbounds ([5 x i8], [5 x i8]* @numberformat, i64 0, i64 0), i64 5)
+	%t8 = sext i32 %t7 to i64
+	ret i64 0
+}
+
+define internal i64 @halfleft.neg(i64 %p0) {
+entry:
+	%s0 = alloca i64
+	store i64 %p0, i64* %s0
+	%r0 = load i64, i64* %s0
+	%r1 = sub i64 0, %r0
+	ret i64 %r1
+}
+
+define i32 @main() {
+	%result = call i64 @halfleft.thisisthepie()
+	%status = trunc i64 %result to i32
+	ret i32 %status
+}
+`
+	if got := compileLLVM(lowerProgram(treeFromTokens(tokenize(source)))); got != want {
+		t.Errorf("compileLLVM() = %v, want %v", got, want)
+	}
+}
+
+func Test_compileLLVM_division(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	giveback half £ 7 2 $ #
+\
+halfleft half £ n d $ /
+	giveback n / d + n % 2 #
+\`
+	want := `; compiled by garylang, every value is an i64 and strings are the address of their bytes
+
+declare void @llvm.trap() noreturn nounwind
+
+define internal i64 @halfleft.thisisthepie() {
+entry:
+	%r0 = call i64 @halfleft.half(i64 7, i64 2)
+	ret i64 %r0
+}
+
+define internal i64 @halfleft.half(i64 %p0, i64 %p1) {
+entry:
+	%s0 = alloca i64
+	%s1 = alloca i64
+	store i64 %p0, i64* %s0
+	store i64 %p1, i64* %s1
+	%r0 = load i64, i64* %s0
+	%r1 = load i64, i64* %s1
+	%t0 = icmp eq i64 %r1, 0
+	%t1 = icmp eq i64 %r0, -9223372036854775808
+	%t2 = icmp eq i64 %r1, -1
+	%t3 = and i1 %t1, %t2
+	%t4 = or i1 %t0, %t3
+	br i1 %t4, label %trap, label %divide5
+divide5:
+	%r2 = sdiv i64 %r0, %r1
+	%r3 = load i64, i64* %s0
+	%r4 = srem i64 %r3, 2
+	%r5 = add i64 %r2, %r4
+	ret i64 %r5
+trap:
+	call void @llvm.trap()
+	unreachable
+}
+
+define i32 @main() {
+	%result = call i64 @halfleft.thisisthepie()
+	%status = trunc i64 %result to i32
+	ret i32 %status
+}
+`
+	if got := compileLLVM(lowerProgram(treeFromTokens(tokenize(source)))); got != want {
+		t.Errorf("compileLLVM() = %v, want %v", got, want)
+	}
+}
+
+func Test_llvmString(t *testing.T) {
+	type args struct {
+		value string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Printable", args{"Hello  world! 100%"}, "Hello  world! 100%"},
+		{"Quotes and backslashes", args{`say "\"`}, `say \22\5C\22`},
+		{"Control characters", args{"a\n\tb\x00"}, `a\0A\09b\00`},
+		{"Bytes outside ascii", args{"¬1"}, `\C2\AC1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := llvmString(tt.args.value); got != tt.want {
+				t.Errorf("llvmString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_llvmIdentifier(t *testing.T) {
+	type args struct {
+		name string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Plain", args{"halfleft.total2"}, "halfleft.total2"},
+		{"Punctuation LLVM allows", args{"a-b$c_d"}, "a-b$c_d"},
+		{"Starting with a digit", args{"2nd"}, `"2nd"`},
+		{"Other bytes", args{"naïve1_entry"}, `"na\C3\AFve1_entry"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := llvmIdentifier(tt.args.name); got != tt.want {
+				t.Errorf("llvmIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_llvmBackend_Build checks the IR lli runs does what the interpreter does, at every level
+func Test_llvmBackend_Build(t *testing.T) {
+	if _, err := exec.LookPath("lli"); err != nil {
+		t.Skip("running LLVM IR needs lli")
+	}
+	backend := GetProgramBackend("llvm")
+	for _, tt := range interpreterPrograms {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				program := treeFromTokens(tokenize(tt.source))
+				lowered := lowerProgram(program)
+				ir.Optimise(lowered, level)
+				llPath, err := backend.Build(program, lowered, filepath.Join(t.TempDir(), "program.gry"), BuildOptions{})
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				got, err := exec.Command("lli", llPath).Output()
+				result := 0
+				if exitError, exited := err.(*exec.ExitError); exited {
+					result = exitError.ExitCode()
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want || int64(result) != tt.wantResult&0xff {
+					t.Errorf("at level %d the program printed %q and exited with %d, want %q and %d", level, got, result, tt.want, tt.wantResult&0xff)
+				}
+			})
+		}
+	}
+}
+
+// Test_llvmBackend_Build_errors checks the divisions a compiled program faults on stop lli with a trap
+// rather than giving something undefined
+func Test_llvmBackend_Build_errors(t *testing.T) {
+	if _, err := exec.LookPath("lli"); err != nil {
+		t.Skip("running LLVM IR needs lli")
+	}
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"Division by zero", args{`halfleft thisisthepie £ $ /
+	zero = 0 #
+	giveback 1 / zero #
+\`}},
+		{"Smallest int divided by -1", args{`halfleft thisisthepie £ $ /
+	smallest = 0 - 9223372036854775807 - 1 #
+	giveback smallest % ( 0 - 1 ) #
+\`}},
+	}
+	backend := GetProgramBackend("llvm")
+	for _, tt := range tests {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				program := treeFromTokens(tokenize(tt.args.source))
+				lowered := lowerProgram(program)
+				ir.Optimise(lowered, level)
+				llPath, err := backend.Build(program, lowered, filepath.Join(t.TempDir(), "program.gry"), BuildOptions{})
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				// a program stopped by a signal has no exit code
+				err = exec.Command("lli", llPath).Run()
+				if exitError, exited := err.(*exec.ExitError); !exited || exitError.ExitCode() != -1 {
+					t.Errorf("at level %d lli gave %v, want the program stopped by a trap", level, err)
+				}
+			})
+		}
+	}
+}
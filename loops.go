@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// parseLoop reads whilst £ condition $ / ... \
+func (p *parser) parseLoop() *ast.While {
+	start := *p.peek()
+	p.expect(While, "whilst")
+	loop := &ast.While{Position: start.Position, Condition: p.parseCondition()}
+	p.expect(BodyStart, "/ to open the whilst block")
+	p.loopDepth++
+	loop.Body = p.parseBlock()
+	p.loopDepth--
+	return loop
+}
+
+// parseLoopControl reads scarper # or carryon #, which only make sense inside a whilst loop
+func (p *parser) parseLoopControl() ast.Statement {
+	control := *p.peek()
+	if p.loopDepth == 0 {
+		failAt(control.Position, "%s can only be used inside a whilst loop", tokenText[control.Type])
+	}
+	p.position++
+	p.expect(EndLine, "# after "+tokenText[control.Type])
+	if control.Type == Break {
+		return &ast.Break{Position: control.Position}
+	}
+	return &ast.Continue{Position: control.Position}
+}
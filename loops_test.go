@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+func Test_parseLoop(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Whilst with scarper and carryon",
+			args{"whilst £ i < 10 $ / perchance £ i == 5 $ / scarper # \\ carryon # \\"},
+			"(whilst (< i 10) [(perchance (== i 5) [scarper]) carryon])",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ast.Sprint(parserFor(tt.args.source).parseStatement()); got != tt.want {
+				t.Errorf("parseStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseLoopControl_outsideLoop(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("parseStatement() accepted scarper outside of a loop")
+		}
+	}()
+	parserFor("scarper #").parseStatement()
+}
+
+func Test_getAssemblyBodyFromIR_loops(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	i = 0 #
+	whilst £ i < 3 $ /
+		i = i + 1 #
+		whilst £ aye $ /
+			scarper #
+		\
+		perchance £ i == 2 $ /
+			carryon #
+		\
+		printthenumber £ i $ #
+	\
+\`))
+	got := getAssemblyBodyFromIR(windowsBackend(), lowerProgram(tree))
+	for _, want := range []string{
+		".whilst1:\nmov rax,qword [rbp-8]\n",
+		"setl al\nmovzx rax,al\ntest rax,rax\njz .endwhilst1\n",
+		".whilst2:\nmov rax,qword 1\ntest rax,rax\njz .endwhilst2\n.body2:\n.endwhilst2:\n",
+		"jz .endperchance3\n.then3:\njmp .whilst1\n.endperchance3:\n",
+		"Invoke printf,numberformat,r10\njmp .whilst1\n.endwhilst1:\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("getAssemblyBodyFromIR() = %v, missing %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+var binaryOps = map[ast.Operator]ir.Op{
+	ast.Add:          ir.Add,
+	ast.Subtract:     ir.Subtract,
+	ast.Multiply:     ir.Multiply,
+	ast.Divide:       ir.Divide,
+	ast.Modulo:       ir.Modulo,
+	ast.Equal:        ir.Equal,
+	ast.NotEqual:     ir.NotEqual,
+	ast.Less:         ir.Less,
+	ast.LessEqual:    ir.LessEqual,
+	ast.Greater:      ir.Greater,
+	ast.GreaterEqual: ir.GreaterEqual,
+}
+
+// loopBlocks are where carryon and scarper go in the whilst loop being lowered
+type loopBlocks struct {
+	start *ir.Block
+	end   *ir.Block
+}
+
+// lowerer turns the statements of one checked procedure into blocks of instructions
+type lowerer struct {
+	program   *ir.Program
+	procedure *ir.Procedure
+	// current is the block being added to, nil after a terminator until the next block starts
+	current *ir.Block
+	labels  int
+	loops   []loopBlocks
+}
+
+// lowerProgram lowers every procedure that can be reached from thisisthepie, in the order usedProcedures finds them
+func lowerProgram(program *ast.Program) *ir.Program {
+	lowered := &ir.Program{}
+	for _, procedure := range usedProcedures(program) {
+		lowered.Procedures = append(lowered.Procedures, lowerProcedure(lowered, procedure))
+	}
+	return lowered
+}
+
+func lowerProcedure(program *ir.Program, procedure *ast.ProcDecl) *ir.Procedure {
+	l := lowerer{
+		program: program,
+		procedure: &ir.Procedure{
+			Name:   procedure.Name,
+			Params: len(procedure.Params),
+			Slots:  len(procedure.Locals),
+		},
+	}
+	l.start(&ir.Block{Name: "entry"})
+	l.block(procedure.Body)
+	// falling off the end gives back 0
+	l.terminate(&ir.Instruction{Op: ir.Return, Dest: ir.NoRegister, Args: []ir.Operand{ir.Imm(0)}})
+	return l.procedure
+}
+
+// nextLabel numbers the blocks of the next statement in the procedure
+func (l *lowerer) nextLabel() string {
+	l.labels++
+	return strconv.Itoa(l.labels)
+}
+
+// start makes block the one instructions are added to
+func (l *lowerer) start(block *ir.Block) {
+	l.procedure.Blocks = append(l.procedure.Blocks, block)
+	l.current = block
+}
+
+func (l *lowerer) emit(instruction *ir.Instruction) {
+	if l.current != nil {
+		l.current.Instructions = append(l.current.Instructions, instruction)
+	}
+}
+
+// terminate ends the current block, the statements after it up to the next block can never run
+func (l *lowerer) terminate(instruction *ir.Instruction) {
+	l.emit(instruction)
+	l.current = nil
+}
+
+func (l *lowerer) jump(target *ir.Block) {
+	l.terminate(&ir.Instruction{Op: ir.Jump, Dest: ir.NoRegister, Targets: []*ir.Block{target}})
+}
+
+func (l *lowerer) block(block *ast.Block) {
+	for _, statement := range block.Statements {
+		if l.current == nil {
+			// nothing after a giveback, scarper or carryon can run
+			return
+		}
+		l.statement(statement)
+	}
+}
+
+func (l *lowerer) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.Assign:
+		value := l.expression(statement.Value)
+		l.emit(&ir.Instruction{Op: ir.Store, Dest: ir.NoRegister, Args: []ir.Operand{value}, Slot: statement.Target.Symbol.Index})
+	case *ast.Return:
+		value := l.expression(statement.Value)
+		l.terminate(&ir.Instruction{Op: ir.Return, Dest: ir.NoRegister, Args: []ir.Operand{value}})
+	case *ast.If:
+		l.conditional(statement)
+	case *ast.While:
+		l.loop(statement)
+	case *ast.Break:
+		l.jump(l.loops[len(l.loops)-1].end)
+	case *ast.Continue:
+		l.jump(l.loops[len(l.loops)-1].start)
+	case *ast.Call:
+		l.call(statement, ir.NoRegister)
+	}
+}
+
+// conditional branches to the then block or to the otherwise block, both of which carry on after the perchance
+func (l *lowerer) conditional(conditional *ast.If) {
+	number := l.nextLabel()
+	then := &ir.Block{Name: "then" + number}
+	end := &ir.Block{Name: "endperchance" + number}
+	otherwise := end
+	if conditional.Else != nil {
+		otherwise = &ir.Block{Name: "otherwise" + number}
+	}
+
+	condition := l.expression(conditional.Condition)
+	l.terminate(&ir.Instruction{Op: ir.Branch, Dest: ir.NoRegister, Args: []ir.Operand{condition}, Targets: []*ir.Block{then, otherwise}})
+	l.start(then)
+	l.block(conditional.Then)
+	l.jump(end)
+	if conditional.Else != nil {
+		l.start(otherwise)
+		l.block(conditional.Else)
+		l.jump(end)
+	}
+	l.start(end)
+}
+
+// loop checks the condition in a block of its own, which the body jumps back to
+func (l *lowerer) loop(loop *ast.While) {
+	number := l.nextLabel()
+	blocks := loopBlocks{start: &ir.Block{Name: "whilst" + number}, end: &ir.Block{Name: "endwhilst" + number}}
+	body := &ir.Block{Name: "body" + number}
+
+	l.jump(blocks.start)
+	l.start(blocks.start)
+	condition := l.expression(loop.Condition)
+	l.terminate(&ir.Instruction{Op: ir.Branch, Dest: ir.NoRegister, Args: []ir.Operand{condition}, Targets: []*ir.Block{body, blocks.end}})
+	l.start(body)
+	l.loops = append(l.loops, blocks)
+	l.block(loop.Body)
+	l.loops = l.loops[:len(l.loops)-1]
+	l.jump(blocks.start)
+	l.start(blocks.end)
+}
+
+// expression gives the operand holding value, literals are used as they are and everything else gets a register
+func (l *lowerer) expression(value ast.Expression) ir.Operand {
+	switch value := value.(type) {
+	case *ast.IntLit:
+		return ir.Imm(value.Value)
+	case *ast.BoolLit:
+		if value.Value {
+			return ir.Imm(1)
+		}
+		return ir.Imm(0)
+	case *ast.StringLit:
+		return ir.Str(l.program.AddString(value.Value))
+	case *ast.Ident:
+		dest := l.procedure.NewRegister()
+		l.emit(&ir.Instruction{Op: ir.Load, Dest: dest, Slot: value.Symbol.Index})
+		return ir.Reg(dest)
+	case *ast.Unary:
+		operand := l.expression(value.Operand)
+		dest := l.procedure.NewRegister()
+		l.emit(&ir.Instruction{Op: ir.Negate, Dest: dest, Args: []ir.Operand{operand}})
+		return ir.Reg(dest)
+	case *ast.Binary:
+		left := l.expression(value.Left)
+		right := l.expression(value.Right)
+		dest := l.procedure.NewRegister()
+		l.emit(&ir.Instruction{Op: binaryOps[value.Operator], Dest: dest, Args: []ir.Operand{left, right}, Position: value.Position})
+		return ir.Reg(dest)
+	case *ast.Call:
+		dest := l.procedure.NewRegister()
+		l.call(value, dest)
+		return ir.Reg(dest)
+	}
+	panic("expression cannot be lowered")
+}
+
+func (l *lowerer) call(call *ast.Call, dest ir.Register) {
+	instruction := &ir.Instruction{
+		Op:       ir.Call,
+		Dest:     dest,
+		Callee:   call.Name,
+		Builtin:  call.Symbol.Kind == ast.BuiltinSymbol,
+		Position: call.Position,
+	}
+	for _, arg := range call.Args {
+		instruction.Args = append(instruction.Args, l.expression(arg))
+		instruction.Types = append(instruction.Types, ast.TypeOf(arg))
+	}
+	l.emit(instruction)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_lowerProgram(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Loops, conditionals and calls",
+			args{`halfleft thisisthepie £ $ /
+	n = 0 #
+	whilst £ n < 5 $ /
+		n = n + 1 #
+		perchance £ n == 2 $ /
+			carryon #
+		\ otherwise /
+			shout £ ¬n is¬ n $ #
+		\
+	\
+\
+halfleft shout £ words n $ /
+	printthething £ words $ #
+	giveback n #
+\`},
+			`str0 = "n is"
+
+halfleft thisisthepie params 0 slots 1
+entry:
+	store s0, 0
+	jump whilst1
+whilst1:
+	r0 = load s0
+	r1 = lt r0, 5
+	branch r1, body1, endwhilst1
+body1:
+	r2 = load s0
+	r3 = add r2, 1
+	store s0, r3
+	r4 = load s0
+	r5 = eq r4, 2
+	branch r5, then2, otherwise2
+then2:
+	jump whilst1
+otherwise2:
+	r6 = load s0
+	call shout(str0, r6)
+	jump endperchance2
+endperchance2:
+	jump whilst1
+endwhilst1:
+	return 0
+
+halfleft shout params 2 slots 2
+entry:
+	r0 = load s0
+	call builtin printthething(r0)
+	r1 = load s1
+	return r1
+`,
+		},
+		{
+			"Nothing after a giveback is lowered",
+			args{`halfleft thisisthepie £ $ /
+	giveback 1 #
+	printthenumber £ 2 $ #
+\`},
+			`
+halfleft thisisthepie params 0 slots 0
+entry:
+	return 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lowerProgram(treeFromTokens(tokenize(tt.args.source))).String(); got != tt.want {
+				t.Errorf("lowerProgram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
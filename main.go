@@ -1,320 +1,205 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
-	"strconv"
 	"strings"
 
-	"github.com/Jordank321/GaryLang/asmFiles"
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
 )
 
 //go:generate go run scripts/includeasm.go
 
-var nextParamNumber = 0
-
 func main() {
-	filePath := os.Args[1]
-	fileBytes, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		panic(err)
+	targetName := flag.String("target", defaultBackend, "platform to compile for, one of "+strings.Join(backendNames(), ", "))
+	// the flag package has no -O with the level stuck to it, so each level is a flag of its own
+	noOptimisation := flag.Bool("O0", false, "compile exactly what was written")
+	optimise := flag.Bool("O1", true, "fold constants, copy small procedures into their callers and remove code and variables that make no difference, the default")
+	options := BuildOptions{}
+	flag.StringVar(&options.Assembler, "assembler", "", "builtin or nasm, by default the builtin assembler when the target has one")
+	flag.StringVar(&options.Linker, "linker", "", "gcc or builtin, builtin writes a static executable needing no C library, by default gcc")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: garylang [flags] file.gry\n       garylang run file.gry|file.gryc|file.wasm")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.Arg(0) == "run" {
+		runFile(flag.Arg(1))
+		return
+	}
+	optimisationLevel := 1
+	if *noOptimisation || !*optimise {
+		optimisationLevel = 0
+	}
+	programBackend := GetProgramBackend(*targetName)
+	var backend Backend
+	if programBackend == nil {
+		var err error
+		backend, err = GetBackend(*targetName)
+		if err != nil {
+			exitWithError(err)
+		}
+	}
+	if err := options.Check(); err != nil {
+		exitWithError(err)
 	}
 
-	tokens := tokenize(string(fileBytes))
-	tree := treeFromTokens(tokens)
-	body := getAssemblyBodyFromTree(tree)
-	asmFiles := usedBuiltinFunctions(tree, &[]string{})
-	externs := cExternsFromAssemblyFiles(*asmFiles)
-	consts := getAssemblyConstantsFromTree(tree)
+	filePath := flag.Arg(0)
+	program := readProgram(filePath)
+
+	lowered := lowerProgram(program)
+	ir.Optimise(lowered, optimisationLevel)
+	if programBackend != nil {
+		if _, err := programBackend.Build(program, lowered, filePath, options); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	body := getAssemblyBodyFromIR(backend, lowered)
+	builtins := usedBuiltinFunctions(lowered, &[]string{})
+	externs := cExternsFromAssemblyFiles(*builtins)
+	consts := getAssemblyConstantsFromIR(lowered)
 
-	asmContents := getAssembly(body, externs, *asmFiles, consts)
+	asmContents := getAssembly(backend, body, externs, consts)
 
 	dir, name := path.Split(filePath)
 
 	asmPath := dir + strings.Replace(name, ".gry", ".asm", -1)
-	ioutil.WriteFile(asmPath, []byte(asmContents), os.ModeExclusive)
-
-	objPath := dir + strings.Replace(name, ".gry", ".obj", -1)
-	out, err := exec.Command("nasm", asmPath, "-fwin64", "-o"+objPath).Output()
-	println(string(out))
+	err := ioutil.WriteFile(asmPath, []byte(asmContents), 0644)
 	if err != nil {
-		panic(err)
+		exitWithError(err)
 	}
 
-	exePath := dir + strings.Replace(name, ".gry", ".exe", -1)
-	out, err = exec.Command("gcc", objPath, "-m64", "-o"+exePath).Output()
-	println(string(out))
+	_, err = backend.Build(asmPath, options)
 	if err != nil {
-		panic(err)
+		exitWithError(err)
 	}
 }
 
-func getAssembly(body string, externImports []string, builtInAsmFunctions []string, consts map[string][]byte) string {
-	content := asmFiles.Start64bit
-	content += asmFiles.Datasection
-	content += constantsAsAsmString(consts)
-	content += asmFiles.Alignconstbytes
-	content += asmFiles.Codesection
-	content += asmFiles.Invoke
-	content += asmFiles.C
-	for _, extern := range externImports {
-		content += "extern " + extern + "\n"
+// readProgram parses and checks the program in the file at filePath, exiting with its diagnostics if it has errors
+func readProgram(filePath string) *ast.Program {
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		exitWithError(err)
 	}
-	content += "\nmain:\n"
-	content += asmFiles.Allocatestack
-	content += "\n" + body + "\n"
-	content += asmFiles.Releasestack
-	content += asmFiles.Exit
-	return content
-}
 
-func constantsAsAsmString(consts map[string][]byte) string {
-	result := ""
-	for name, bytes := range consts {
-		if bytes[len(bytes)-1] == 0 {
-			result += name + ": db \"" + string(bytes[:len(bytes)-1]) + "\",0\n"
-		} else {
-			result += name + ": db \"" + string(bytes) + "\"\n"
-		}
+	diagnostics := NewDiagnostics(filePath, string(fileBytes))
+	program := &ast.Program{}
+	tokens, err := tokenizeFile(filePath, string(fileBytes))
+	if lexError, isLexError := err.(*LexError); isLexError {
+		diagnostics.Error(lexError.Position, "%s", lexError.Message)
+	} else {
+		program = parseProgram(tokens, diagnostics)
+		checkProgram(program, diagnostics)
 	}
-	return result
-}
-
-func cExternsFromAssemblyFiles(asmFiles []string) []string {
-	externs := []string{}
-	for _, asmFile := range asmFiles {
-		externs = append(externs, GetStandardFunctionExterns(asmFile)...)
+	fmt.Fprint(os.Stderr, diagnostics.String())
+	if diagnostics.HasErrors() {
+		os.Exit(1)
 	}
-	return externs
+	return program
 }
 
-func getAssemblyConstantsFromTree(tree FunctionCallTree) map[string][]byte {
-	currentConstants := map[string][]byte{}
-	initBody := tree.Definition.Body
-	for _, call := range initBody {
-		if call.Definition.AssembledBodyFile != nil {
-			for _, parm := range call.Definition.Parameters {
-				constName := call.ParamConstNames[parm]
-				currentConstants[constName] = call.Parameters[parm].EvalValue
-			}
-		}
+// runFile interprets the program in the file at filePath, or runs it in the virtual machine if it is
+// bytecode or the wasm interpreter if it is a module, exiting with the status the compiled program would
+func runFile(filePath string) {
+	out := bufio.NewWriter(os.Stdout)
+	var result int64
+	var err error
+	if strings.HasSuffix(filePath, ".gryc") {
+		result, err = runBytecodeFile(filePath, out)
+	} else if strings.HasSuffix(filePath, ".wasm") {
+		result, err = runWasmFile(filePath, out)
+	} else {
+		result, err = interpretProgram(readProgram(filePath), out)
 	}
-	return currentConstants
-}
-
-func getAssemblyBodyFromTree(tree FunctionCallTree) string {
-	currentBody := ""
-	initBody := tree.Definition.Body
-	for _, call := range initBody {
-		if call.Definition.AssembledBodyFile != nil {
-			assembly := *call.Definition.AssembledBodyFile
-			for paramName, constName := range call.ParamConstNames {
-				assembly = strings.Replace(assembly, "$"+paramName, "$"+constName, -1)
-			}
-			currentBody += assembly
-		}
+	out.Flush()
+	if err != nil {
+		exitWithError(err)
 	}
-	return currentBody
+	// the C runtime and the builtin one both exit with the low byte of what thisisthepie gives back
+	os.Exit(int(uint8(result)))
 }
 
-// func readAsmFile(file string) string {
-// 	contents, err := ioutil.ReadFile("./windowsAssembly/" + file + ".asm")
-// 	if err != nil {
-// 		panic(err)
-// 	}
-// 	return strings.Replace(string(contents), "\r\n", "\n", -1)
-// }
-
-func usedBuiltinFunctions(tree FunctionCallTree, used *[]string) *[]string {
-	for _, param := range tree.Parameters {
-		usedBuiltinFunctions(param, used)
-	}
-	if tree.Definition == nil {
-		return used
-	}
-	asmFile := tree.Definition.AssembledBodyName
-	if asmFile != nil {
-		newUsed := appendIfMissing(*used, *asmFile)
-		*used = newUsed
-	}
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, "garylang: "+err.Error())
+	os.Exit(1)
+}
 
-	for _, call := range (*tree.Definition).Body {
-		usedBuiltinFunctions(call, used)
-	}
-	return used
+func getAssembly(backend Backend, body string, externImports []string, consts map[string][]byte) string {
+	content := backend.Prologue()
+	content += backend.DataSection(consts)
+	content += backend.CodeSection(externImports)
+	content += "\n" + body + "\n"
+	content += backend.Epilogue()
+	return content
 }
 
-func treeFromTokens(tokens *[]Token) FunctionCallTree {
-	groups := map[string]*[]Token{}
-	var currentFuncGroup []Token
-	for _, tokenCur := range *tokens {
-		if tokenCur.Type == ProcedureDefine {
-			if len(currentFuncGroup) > 0 {
-				groups[*currentFuncGroup[1].Value] = &currentFuncGroup
-			}
-			currentFuncGroup = []Token{tokenCur}
-			continue
-		} else if len(currentFuncGroup) > 0 {
-			currentFuncGroup = append(currentFuncGroup, tokenCur)
+func cExternsFromAssemblyFiles(asmFiles []string) []string {
+	externs := []string{}
+	for _, asmFile := range asmFiles {
+		for _, extern := range GetStandardFunctionExterns(asmFile) {
+			externs = appendIfMissing(externs, extern)
 		}
 	}
-	groups[*currentFuncGroup[1].Value] = &currentFuncGroup
-
-	definitions := map[string]FunctionDefinitionTree{}
-	for procName, procTokens := range groups {
-		definitions[procName] = funcTree(procTokens)
-	}
-
-	initFunc := definitions["thisisthepie"]
-	return FunctionCallTree{
-		Definition: &initFunc,
-	}
+	return externs
 }
 
-func funcTree(tokens *[]Token) FunctionDefinitionTree {
-	setupStandardFunctions()
-	tree := FunctionDefinitionTree{}
-
-	inBody := false
-	inParams := false
-	var leftHandSide *string
-	var rightHandSide *string
-	var callCur *FunctionCallTree
-	callCurParamNumber := 0
-
-	assignParam := func(param string) {
-		callCur.ParamConstNames[param] = "p" + strconv.Itoa(nextParamNumber)
-		nextParamNumber++
-	}
-
-	for _, tokenCur := range *tokens {
-		if tokenCur.Type == ParamOpen && !inBody && !inParams {
-			inParams = true
-			continue
-		}
-		if tokenCur.Type == ParamClose && !inBody && inParams {
-			inParams = false
-			continue
-		}
-		if inParams && tokenCur.Type == Name {
-			tree.Parameters = append(tree.Parameters, *tokenCur.Value)
-			continue
-		}
-
-		if tokenCur.Type == BodyStart && !inBody {
-			inBody = true
-			continue
-		}
-		if tokenCur.Type == BodyEnd && inBody {
-			inBody = true
-			continue
-		}
-
-		if inBody && !inParams && tokenCur.Type == Name {
-			def := standardFunctions[*tokenCur.Value]
-			if def == nil {
-				if leftHandSide == nil {
-					leftHandSide = tokenCur.Value
-				} else {
-					rightHandSide = tokenCur.Value
-				}
-			} else {
-				callCur = &FunctionCallTree{
-					Definition:      def,
-					Parameters:      map[string]FunctionCallTree{},
-					ParamConstNames: map[string]string{},
-				}
-			}
+// usedProcedures lists the procedures reachable from thisisthepie, in the order they are first called
+func usedProcedures(program *ast.Program) []*ast.ProcDecl {
+	procedures := []*ast.ProcDecl{}
+	visited := map[*ast.ProcDecl]bool{}
+	var visit func(procedure *ast.ProcDecl)
+	visit = func(procedure *ast.ProcDecl) {
+		if procedure == nil || visited[procedure] {
+			return
 		}
-		if inBody && !inParams && tokenCur.Type == Assign {
-			def := GetStandardFunction("assign")
-			callCur = &FunctionCallTree{
-				Definition:      def,
-				Parameters:      map[string]FunctionCallTree{},
-				ParamConstNames: map[string]string{},
+		visited[procedure] = true
+		procedures = append(procedures, procedure)
+		ast.Inspect(procedure.Body, func(node ast.Node) bool {
+			if call, isCall := node.(*ast.Call); isCall && GetStandardFunction(call.Name) == nil {
+				visit(program.Procedure(call.Name))
 			}
-		}
-		if inBody && !inParams && tokenCur.Type == Number && leftHandSide != nil {
-			rightHandSide = tokenCur.Value
-		}
-		if inBody && !inParams && tokenCur.Type == StringConst && leftHandSide != nil {
-			rightHandSide = tokenCur.Value
-		}
-		if tokenCur.Type == ParamOpen && inBody && !inParams {
-			inParams = true
-			continue
-		}
-		if inBody && inParams && tokenCur.Type == StringConst {
-			paramName := callCur.Definition.Parameters[callCurParamNumber]
-			callCurParamNumber++
-			callCur.Parameters[paramName] = FunctionCallTree{EvalValue: append([]byte(*tokenCur.Value), 0)}
-			assignParam(paramName)
-		}
-		if tokenCur.Type == ParamClose && inBody && inParams {
-			inParams = false
-			tree.Body = append(tree.Body, *callCur)
-			callCur = nil
-			callCurParamNumber = 0
-			continue
-		}
+			return true
+		})
+	}
+	visit(program.Procedure("thisisthepie"))
+	return procedures
+}
 
-		if leftHandSide != nil && callCur != nil && rightHandSide != nil {
-			lhsName := callCur.Definition.Parameters[0]
-			rhsName := callCur.Definition.Parameters[1]
-			callCur.Parameters[lhsName] = FunctionCallTree{EvalValue: []byte{0}}
-			assignParam(lhsName)
-			callCur.Parameters[rhsName] = FunctionCallTree{EvalValue: []byte(*rightHandSide)}
-			assignParam(rhsName)
-			callCur.Parameters["valLength"] = FunctionCallTree{EvalValue: []byte{byte(len(*rightHandSide))}}
-			assignParam("valLength")
-			tree.Body = append(tree.Body, *callCur)
-			callCur = nil
-			leftHandSide = nil
-			rightHandSide = nil
-			continue
-		}
+// checkProgram runs the passes after parsing, types are only checked once every name has been resolved
+func checkProgram(program *ast.Program, diagnostics *Diagnostics) {
+	resolveProgram(program, diagnostics)
+	if !diagnostics.HasErrors() {
+		checkTypes(program, diagnostics)
 	}
+}
 
-	return tree
+// treeFromTokens parses and checks source that is not read from a file
+func treeFromTokens(tokens *[]Token) *ast.Program {
+	diagnostics := NewDiagnostics("", "")
+	program := parseProgram(tokens, diagnostics)
+	checkProgram(program, diagnostics)
+	if diagnostics.HasErrors() {
+		panic(diagnostics.String())
+	}
+	return program
 }
 
+// tokenize scans source that is not read from a file
 func tokenize(input string) *[]Token {
-	tokens := []Token{}
-	lines := strings.Split(strings.Replace(input, "\r\n", "\n", -1), "\n")
-	for _, line := range lines {
-		words := strings.Split(line, " ")
-		var stringTok *Token
-		for _, word := range words {
-			if len(word) > 1 {
-				if word[1] == '¬' && word[len(word)-1] != '¬' {
-					stringTok = &Token{
-						Type:  StringConst,
-						Value: getAdr(word[2:] + " "),
-					}
-					continue
-				} else if word[1] != '¬' && word[len(word)-1] == '¬' {
-					stringTok.Value = getAdr(*stringTok.Value + word[:len(word)-2])
-					tokens = append(tokens, *stringTok)
-					continue
-				} else if stringTok != nil {
-					stringTok.Value = getAdr(*stringTok.Value + word + " ")
-					continue
-				}
-			}
-			if len(word) == 0 && stringTok != nil {
-				stringTok.Value = getAdr(*stringTok.Value + " ")
-				continue
-			}
-
-			tok := parseWordToToken(strings.TrimSpace(word))
-			tokens = append(tokens, tok)
-		}
+	tokens, err := tokenizeFile("", input)
+	if err != nil {
+		panic(err)
 	}
-	return &tokens
+	return tokens
 }
 
+// parseWordToToken classifies a word of the source as a keyword, number or name
 func parseWordToToken(input string) Token {
 	tok := Token{}
 
@@ -323,51 +208,48 @@ func parseWordToToken(input string) Token {
 		tok.Type = ProcedureDefine
 	case "alien":
 		tok.Type = ModuleImport
-	case "£":
-		tok.Type = ParamOpen
-	case "$":
-		tok.Type = ParamClose
-	case "#":
-		tok.Type = EndLine
-	case "/":
-		tok.Type = BodyStart
-	case "\\":
-		tok.Type = BodyEnd
-	case "=":
-		tok.Type = Assign
+	case "giveback":
+		tok.Type = Return
+	case "perchance":
+		tok.Type = If
+	case "otherwise":
+		tok.Type = Else
+	case "whilst":
+		tok.Type = While
+	case "scarper":
+		tok.Type = Break
+	case "carryon":
+		tok.Type = Continue
+	case "aye", "nay":
+		tok.Type = BoolConst
+		tok.Value = getAdr(input)
 	default:
-		if len(input) >= 2 && input[1] == '¬' && input[len(input)-1] == '¬' {
-			tok.Type = StringConst
-			tok.Value = getAdr(input[2 : len(input)-2])
-		} else if _, err := strconv.Atoi(input); err == nil {
+		if isNumber(input) {
 			tok.Type = Number
-			tok.Value = getAdr(input)
 		} else {
 			tok.Type = Name
-			tok.Value = getAdr(input)
 		}
+		tok.Value = getAdr(input)
 	}
 
 	return tok
 }
 
-type FunctionDefinitionTree struct {
-	Parameters        []string
-	Body              []FunctionCallTree
-	AssembledBodyName *string
-	AssembledBodyFile *string
-}
-
-type FunctionCallTree struct {
-	Definition      *FunctionDefinitionTree
-	EvalValue       []byte
-	Parameters      map[string]FunctionCallTree
-	ParamConstNames map[string]string
+// isNumber is true for words made only of digits, however many, so a number too big for an int is
+// reported as one rather than as a name
+func isNumber(word string) bool {
+	for _, r := range word {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return word != ""
 }
 
 type Token struct {
-	Type  TokeType
-	Value *string
+	Type     TokeType
+	Value    *string
+	Position Position
 }
 
 type TokeType int
@@ -384,6 +266,25 @@ const (
 	StringConst
 	Assign
 	Number
+	Plus
+	Minus
+	Multiply
+	Modulo
+	IsEqual
+	NotEqual
+	LessThan
+	LessEqual
+	GreaterThan
+	GreaterEqual
+	GroupOpen
+	GroupClose
+	Return
+	If
+	Else
+	While
+	Break
+	Continue
+	BoolConst
 	EOF
 )
 
@@ -7,9 +7,32 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Jordank321/GaryLang/asmFiles"
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
 )
 
+// initialExample is the tree of
+//
+//	halfleft thisisthepie £ $ /
+//		pie = 3 #
+//		printthething £ ¬Hello  world!¬ $ #
+//	\
+//
+// checked the same way treeFromTokens checks what it parses
+func initialExample() *ast.Program {
+	program := &ast.Program{Procedures: []*ast.ProcDecl{
+		&ast.ProcDecl{
+			Name: "thisisthepie",
+			Body: &ast.Block{Statements: []ast.Statement{
+				&ast.Assign{Target: &ast.Ident{Name: "pie"}, Value: &ast.IntLit{Value: 3}},
+				&ast.Call{Name: "printthething", Args: []ast.Expression{&ast.StringLit{Value: "Hello  world!"}}},
+			}},
+		},
+	}}
+	checkProgram(program, NewDiagnostics("", ""))
+	return program
+}
+
 func Test_tokenize(t *testing.T) {
 	type args struct {
 		input string
@@ -29,54 +52,69 @@ func Test_tokenize(t *testing.T) {
 			},
 			&[]Token{
 				Token{
-					Type: ProcedureDefine,
+					Type:     ProcedureDefine,
+					Position: Position{Line: 1, Column: 1},
 				},
 				Token{
-					Type:  Name,
-					Value: getAdr("thisisthepie"),
+					Type:     Name,
+					Value:    getAdr("thisisthepie"),
+					Position: Position{Line: 1, Column: 10},
 				},
 				Token{
-					Type: ParamOpen,
+					Type:     ParamOpen,
+					Position: Position{Line: 1, Column: 23},
 				},
 				Token{
-					Type: ParamClose,
+					Type:     ParamClose,
+					Position: Position{Line: 1, Column: 25},
 				},
 				Token{
-					Type: BodyStart,
+					Type:     BodyStart,
+					Position: Position{Line: 1, Column: 27},
 				},
 				Token{
-					Type:  Name,
-					Value: getAdr("pie"),
+					Type:     Name,
+					Value:    getAdr("pie"),
+					Position: Position{Line: 2, Column: 2},
 				},
 				Token{
-					Type: Assign,
+					Type:     Assign,
+					Position: Position{Line: 2, Column: 6},
 				},
 				Token{
-					Type:  Number,
-					Value: getAdr("3"),
+					Type:     Number,
+					Value:    getAdr("3"),
+					Position: Position{Line: 2, Column: 8},
 				},
 				Token{
-					Type: EndLine,
+					Type:     EndLine,
+					Position: Position{Line: 2, Column: 10},
 				},
 				Token{
-					Type:  Name,
-					Value: getAdr("printthething"),
+					Type:     Name,
+					Value:    getAdr("printthething"),
+					Position: Position{Line: 3, Column: 2},
 				},
 				Token{
-					Type: ParamOpen,
+					Type:     ParamOpen,
+					Position: Position{Line: 3, Column: 16},
 				},
 				Token{
-					Type:  StringConst,
-					Value: getAdr("Hello  world!"),
+					Type:     StringConst,
+					Value:    getAdr("Hello  world!"),
+					Position: Position{Line: 3, Column: 18},
 				},
 				Token{
-					Type: ParamClose,
+					Type:     ParamClose,
+					Position: Position{Line: 3, Column: 34},
 				},
 				Token{
-					Type: EndLine,
+					Type:     EndLine,
+					Position: Position{Line: 3, Column: 36},
 				},
 				Token{
-					Type: BodyEnd,
+					Type:     BodyEnd,
+					Position: Position{Line: 4, Column: 1},
 				},
 			},
 		},
@@ -97,7 +135,7 @@ func Test_treeFromTokens(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want FunctionCallTree
+		want *ast.Program
 	}{
 		{
 			"Initial Example",
@@ -155,56 +193,7 @@ func Test_treeFromTokens(t *testing.T) {
 					},
 				},
 			},
-			FunctionCallTree{
-				Definition: &FunctionDefinitionTree{
-					Body: []FunctionCallTree{
-						FunctionCallTree{
-							Definition: &FunctionDefinitionTree{
-								AssembledBodyName: getAdr("setbytes"),
-								AssembledBodyFile: getAdr(asmFiles.Setbytes),
-								Parameters: []string{
-									"varName",
-									"value",
-									"valLength",
-								},
-							},
-							Parameters: map[string]FunctionCallTree{
-								"varName": FunctionCallTree{
-									EvalValue: []byte{0},
-								},
-								"value": FunctionCallTree{
-									EvalValue: []byte("3"),
-								},
-								"valLength": FunctionCallTree{
-									EvalValue: []byte{1},
-								},
-							},
-							ParamConstNames: map[string]string{
-								"varName":   "p0",
-								"value":     "p1",
-								"valLength": "p2",
-							},
-						},
-						FunctionCallTree{
-							Definition: &FunctionDefinitionTree{
-								AssembledBodyName: getAdr("printf"),
-								AssembledBodyFile: getAdr(asmFiles.Printf),
-								Parameters: []string{
-									"printString",
-								},
-							},
-							Parameters: map[string]FunctionCallTree{
-								"printString": FunctionCallTree{
-									EvalValue: append([]byte("Hello  world!"), 0),
-								},
-							},
-							ParamConstNames: map[string]string{
-								"printString": "p3",
-							},
-						},
-					},
-				},
-			},
+			initialExample(),
 		},
 	}
 	for _, tt := range tests {
@@ -227,7 +216,7 @@ func Test_treeFromTokens(t *testing.T) {
 
 func Test_usedBuiltinFunctions(t *testing.T) {
 	type args struct {
-		tree FunctionCallTree
+		tree *ast.Program
 		used *[]string
 	}
 	tests := []struct {
@@ -238,71 +227,47 @@ func Test_usedBuiltinFunctions(t *testing.T) {
 		{
 			"Initial Examples",
 			args{
-				tree: FunctionCallTree{
-					Definition: &FunctionDefinitionTree{
-						Body: []FunctionCallTree{
-							FunctionCallTree{
-								Definition: &FunctionDefinitionTree{
-									AssembledBodyName: getAdr("setbytes"),
-									AssembledBodyFile: getAdr(asmFiles.Setbytes),
-									Parameters: []string{
-										"varName",
-										"value",
-									},
-								},
-								Parameters: map[string]FunctionCallTree{
-									"varName": FunctionCallTree{
-										EvalValue: []byte("pie"),
-									},
-									"value": FunctionCallTree{
-										EvalValue: []byte("3"),
-									},
-								},
-								ParamConstNames: map[string]string{
-									"varName": "p0",
-									"value":   "p1",
-								},
-							},
-							FunctionCallTree{
-								Definition: &FunctionDefinitionTree{
-									AssembledBodyName: getAdr("printf"),
-									AssembledBodyFile: getAdr(asmFiles.Printf),
-									Parameters: []string{
-										"printString",
-									},
-								},
-								Parameters: map[string]FunctionCallTree{
-									"printString": FunctionCallTree{
-										EvalValue: append([]byte("Hello  world!"), 0),
-									},
-								},
-								ParamConstNames: map[string]string{
-									"printString": "p2",
-								},
-							},
-						},
-					},
-				},
+				tree: initialExample(),
 				used: &[]string{},
 			},
 			&[]string{
-				"setbytes",
+				"printf",
+			},
+		},
+		{
+			"Recursive Procedures",
+			args{
+				tree: treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	countdown £ 3 $ #
+\
+halfleft countdown £ n $ /
+	printthenumber £ n $ #
+	countdown £ ( n - 1 ) $ #
+	shout £ $ #
+\
+halfleft shout £ $ /
+	printthething £ ¬!¬ $ #
+\`)),
+				used: &[]string{},
+			},
+			&[]string{
+				"printnumber",
 				"printf",
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := usedBuiltinFunctions(tt.args.tree, tt.args.used); !reflect.DeepEqual(got, tt.want) {
+			if got := usedBuiltinFunctions(lowerProgram(tt.args.tree), tt.args.used); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("usedBuiltinFunctions() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func Test_getAssemblyBodyFromTree(t *testing.T) {
+func Test_getAssemblyBodyFromIR(t *testing.T) {
 	type args struct {
-		tree FunctionCallTree
+		tree *ast.Program
 	}
 	tests := []struct {
 		name string
@@ -312,76 +277,100 @@ func Test_getAssemblyBodyFromTree(t *testing.T) {
 		{
 			"Initial Example",
 			args{
-				tree: FunctionCallTree{
-					Definition: &FunctionDefinitionTree{
-						Body: []FunctionCallTree{
-							FunctionCallTree{
-								Definition: &FunctionDefinitionTree{
-									AssembledBodyName: getAdr("setbytes"),
-									AssembledBodyFile: getAdr(asmFiles.Setbytes),
-									Parameters: []string{
-										"varName",
-										"value",
-									},
-								},
-								Parameters: map[string]FunctionCallTree{
-									"varName": FunctionCallTree{
-										EvalValue: []byte("pie"),
-									},
-									"value": FunctionCallTree{
-										EvalValue: []byte("3"),
-									},
-								},
-								ParamConstNames: map[string]string{
-									"varName": "p0",
-									"value":   "p1",
-								},
-							},
-							FunctionCallTree{
-								Definition: &FunctionDefinitionTree{
-									AssembledBodyName: getAdr("printf"),
-									AssembledBodyFile: getAdr(asmFiles.Printf),
-									Parameters: []string{
-										"printString",
-									},
-								},
-								Parameters: map[string]FunctionCallTree{
-									"printString": FunctionCallTree{
-										EvalValue: append([]byte("Hello  world!"), 0),
-									},
-								},
-								ParamConstNames: map[string]string{
-									"printString": "p2",
-								},
-							},
-						},
-					},
-				},
+				tree: initialExample(),
+			},
+			`
+main:
+; -----------------------------------------------------------------------------
+; Allocate stack memory
+; The frame below the saved rbp holds the variables first, then the saved
+; registers, then 8*4 bytes of shadow space and room for the parameters that
+; are passed on the stack.
+; !!! The frame size is always a multiple of 16, so together with the pushed
+; rbp the stack stays aligned on the 16 byte boundary.
+; -----------------------------------------------------------------------------
+push rbp
+mov rbp,rsp
+sub rsp,48
+mov rax,qword 3
+mov qword [rbp-8],rax
+Invoke printf,stringformat,p0
+mov rax,qword 0
+.giveback:
+; -----------------------------------------------------------------------------
+; Release stack memory
+; -----------------------------------------------------------------------------
+mov rsp,rbp
+pop rbp
+; -----------------------------------------------------------------------------
+; Quit
+; -----------------------------------------------------------------------------
+ret
+`,
+		},
+		{
+			"Variables",
+			args{
+				tree: treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	greeting = ¬hi¬ #
+	count = 42 #
+	copy = count #
+	printthething £ greeting $ #
+	printthenumber £ copy $ #
+\`)),
 			},
-			`; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; 4x of them are assigned to registers.
-; 3x of them are assigned to stack spaces.
+			`
+main:
 ; -----------------------------------------------------------------------------
-; Call printf with seven parameters
+; Allocate stack memory
+; The frame below the saved rbp holds the variables first, then the saved
+; registers, then 8*4 bytes of shadow space and room for the parameters that
+; are passed on the stack.
+; !!! The frame size is always a multiple of 16, so together with the pushed
+; rbp the stack stays aligned on the 16 byte boundary.
+; -----------------------------------------------------------------------------
+push rbp
+mov rbp,rsp
+sub rsp,64
+mov rax,qword p0
+mov qword [rbp-8],rax
+mov rax,qword 42
+mov qword [rbp-16],rax
+mov rax,qword [rbp-16]
+mov qword [rbp-24],rax
+mov rax,qword [rbp-8]
+mov r10,rax
+Invoke printf,stringformat,r10
+mov rax,qword [rbp-24]
+mov r10,rax
+Invoke printf,numberformat,r10
+mov rax,qword 0
+.giveback:
 ; -----------------------------------------------------------------------------
-Invoke printf,$p0
+; Release stack memory
+; -----------------------------------------------------------------------------
+mov rsp,rbp
+pop rbp
+; -----------------------------------------------------------------------------
+; Quit
+; -----------------------------------------------------------------------------
+ret
 `,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getAssemblyBodyFromTree(tt.args.tree); got != tt.want {
+			if got := getAssemblyBodyFromIR(windowsBackend(), lowerProgram(tt.args.tree)); got != tt.want {
 				fmt.Println(got)
-				t.Errorf("getAssemblyBodyFromTree() = %v, want %v", got, tt.want)
+				t.Errorf("getAssemblyBodyFromIR() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func Test_getAssemblyConstantsFromTree(t *testing.T) {
+func Test_getAssemblyConstantsFromIR(t *testing.T) {
 	type args struct {
-		tree FunctionCallTree
+		tree *ast.Program
 	}
 	tests := []struct {
 		name string
@@ -391,63 +380,18 @@ func Test_getAssemblyConstantsFromTree(t *testing.T) {
 		{
 			"Initial Example",
 			args{
-				FunctionCallTree{
-					Definition: &FunctionDefinitionTree{
-						Body: []FunctionCallTree{
-							FunctionCallTree{
-								Definition: &FunctionDefinitionTree{
-									AssembledBodyName: getAdr("setbytes"),
-									AssembledBodyFile: getAdr(asmFiles.Setbytes),
-									Parameters: []string{
-										"varName",
-										"value",
-									},
-								},
-								Parameters: map[string]FunctionCallTree{
-									"varName": FunctionCallTree{
-										EvalValue: []byte("pie"),
-									},
-									"value": FunctionCallTree{
-										EvalValue: []byte("3"),
-									},
-								},
-								ParamConstNames: map[string]string{
-									"varName": "p0",
-									"value":   "p1",
-								},
-							},
-							FunctionCallTree{
-								Definition: &FunctionDefinitionTree{
-									AssembledBodyName: getAdr("printf"),
-									AssembledBodyFile: getAdr(asmFiles.Printf),
-									Parameters: []string{
-										"printString",
-									},
-								},
-								Parameters: map[string]FunctionCallTree{
-									"printString": FunctionCallTree{
-										EvalValue: append([]byte("Hello  world!"), 0),
-									},
-								},
-								ParamConstNames: map[string]string{
-									"printString": "p2",
-								},
-							},
-						},
-					},
-				},
+				initialExample(),
 			},
 			map[string][]byte{
-				"p0": []byte("pie"),
-				"p1": []byte("3"),
-				"p2": append([]byte("Hello  world!"), 0),
+				"p0":           append([]byte("Hello  world!"), 0),
+				"stringformat": append([]byte("%s"), 0),
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getAssemblyConstantsFromTree(tt.args.tree); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getAssemblyConstantsFromTree() = %v, want %v", got, tt.want)
+			if got := getAssemblyConstantsFromIR(lowerProgram(tt.args.tree)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAssemblyConstantsFromIR() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -466,8 +410,9 @@ func Test_cExternsFromAssemblyFiles(t *testing.T) {
 			"Initial Example",
 			args{
 				[]string{
-					"setbytes",
+					"store",
 					"printf",
+					"printnumber",
 				},
 			},
 			[]string{
@@ -486,10 +431,10 @@ func Test_cExternsFromAssemblyFiles(t *testing.T) {
 
 func Test_getAssemblyBody(t *testing.T) {
 	type args struct {
-		body                string
-		externImports       []string
-		builtInAsmFunctions []string
-		contants            map[string][]byte
+		backend       Backend
+		body          string
+		externImports []string
+		contants      map[string][]byte
 	}
 	tests := []struct {
 		name string
@@ -499,21 +444,15 @@ func Test_getAssemblyBody(t *testing.T) {
 		{
 			"Simple Example",
 			args{
-				`; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; 4x of them are assigned to registers.
-; 3x of them are assigned to stack spaces.
-; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; -----------------------------------------------------------------------------
-Invoke printf,$printString
+				windowsBackend(),
+				`main:
+Invoke printf,printString
+mov rax,qword 0
+ret
 `,
 				[]string{
 					"printf",
 				},
-				[]string{
-					"thisisbuiltin",
-				},
 				map[string][]byte{
 					"printString": append([]byte("Something that is forever constant!"), 0),
 				},
@@ -538,22 +477,22 @@ section .text use64
 %macro Invoke 1-*
         %if %0 > 1
                 %rotate 1
-                mov rcx,qword %1
+                mov rcx,%1
                 %rotate 1
                 %if %0 > 2
-                        mov rdx,qword %1
+                        mov rdx,%1
                         %rotate 1
                         %if  %0 > 3
-                                mov r8,qword %1
+                                mov r8,%1
                                 %rotate 1
                                 %if  %0 > 4
-                                        mov r9,qword %1
+                                        mov r9,%1
                                         %rotate 1
                                         %if  %0 > 5
                                                 %assign max %0-5
                                                 %assign i 32
                                                 %rep max
-                                                        mov rax,qword %1
+                                                        mov rax,%1
                                                         mov qword [rsp+i],rax
                                                         %assign i i+8
                                                         %rotate 1
@@ -579,30 +518,11 @@ global main
 extern printf
 
 main:
-; -----------------------------------------------------------------------------
-; Allocate stack memory
-; -----------------------------------------------------------------------------
-sub rsp,8*7
-
-; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; 4x of them are assigned to registers.
-; 3x of them are assigned to stack spaces.
-; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; -----------------------------------------------------------------------------
-Invoke printf,$printString
-
-; -----------------------------------------------------------------------------
-; Release stack memory
-; -----------------------------------------------------------------------------
-add rsp,8*7
-; -----------------------------------------------------------------------------
-; Quit
-; -----------------------------------------------------------------------------
+Invoke printf,printString
 mov rax,qword 0
 ret
 
+
 ; ----
 ; END ----
 ; ----
@@ -611,7 +531,7 @@ ret
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getAssembly(tt.args.body, tt.args.externImports, tt.args.builtInAsmFunctions, tt.args.contants); got != tt.want {
+			if got := getAssembly(tt.args.backend, tt.args.body, tt.args.externImports, tt.args.contants); got != tt.want {
 				gotLines := strings.Split(got, "\n")
 				wantLines := strings.Split(tt.want, "\n")
 				if len(gotLines) != len(wantLines) {
@@ -631,3 +551,172 @@ ret
 		})
 	}
 }
+
+func Test_getProcedureAssembly(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	total £ 1 2 3 4 5 $ #
+\
+halfleft total £ a b c d e $ /
+	printthenumber £ ( a + e ) $ #
+\`))
+	type args struct {
+		backend   string
+		procedure *ir.Procedure
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantContains []string
+	}{
+		{
+			"Windows caller",
+			args{"windows-amd64", lowerProgram(tree).Procedure("thisisthepie")},
+			[]string{
+				"\nmain:\n",
+				"sub rsp,48\n",
+				"Invoke halfleft_total,1,2,3,4,5\n",
+			},
+		},
+		{
+			"Windows callee",
+			args{"windows-amd64", lowerProgram(tree).Procedure("total")},
+			[]string{
+				"\nhalfleft_total:\n",
+				"mov rax,rcx\nmov qword [rbp-8],rax\n",
+				"mov rax,r9\nmov qword [rbp-32],rax\n",
+				"mov rax,[rbp+48]\nmov qword [rbp-40],rax\n",
+				"mov rax,r10\nmov rcx,r11\nadd rax,rcx\nmov r10,rax\nInvoke printf,numberformat,r10\n",
+			},
+		},
+		{
+			"Linux callee",
+			args{"linux-amd64", lowerProgram(tree).Procedure("total")},
+			[]string{
+				"\nhalfleft_total:\n",
+				"sub rsp,48\n",
+				"mov rax,rdi\nmov qword [rbp-8],rax\n",
+				"mov rax,r8\nmov qword [rbp-40],rax\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, _ := GetBackend(tt.args.backend)
+			got := getProcedureAssembly(backend, tt.args.procedure)
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got, want) {
+					t.Errorf("getProcedureAssembly() = %v, missing %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_getProcedureAssembly_giveback(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	x = double £ 21 $ #
+	printthenumber £ double £ x $ $ #
+	giveback x - 42 #
+\
+halfleft double £ n $ /
+	giveback n * 2 #
+\`))
+	backend, _ := GetBackend("linux-amd64")
+	got := getAssemblyBodyFromIR(backend, lowerProgram(tree))
+	for _, want := range []string{
+		"mov rdi,21\nxor eax,eax\ncall halfleft_double\nmov qword [rbp-8],rax\n",
+		"mov rdi,r10\nxor eax,eax\ncall halfleft_double\nmov r10,rax\nlea rdi,[rel numberformat]\nmov rsi,r10\nxor eax,eax\ncall printf\n",
+		"sub rax,rcx\n.giveback:\n",
+		"\nhalfleft_double:\n",
+		"imul rax,rcx\n.giveback:\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("getAssemblyBodyFromIR() = %v, missing %v", got, want)
+		}
+	}
+}
+
+func Test_getProcedureAssembly_savedRegisters(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	x = double £ 21 $ #
+	printthenumber £ x $ #
+	giveback x #
+\
+halfleft double £ n $ /
+	perchance £ n > 100 $ /
+		double £ 1 $ #
+	\
+	giveback n * 2 #
+\`))
+	program := lowerProgram(tree)
+	ir.Optimise(program, 1)
+	backend, _ := GetBackend("linux-amd64")
+	got := getProcedureAssembly(backend, program.Procedure("thisisthepie"))
+	for _, want := range []string{
+		"sub rsp,16\nmov qword [rbp-8],rbx\n",
+		"call halfleft_double\nmov rbx,rax\nlea rdi,[rel numberformat]\nmov rsi,rbx\nxor eax,eax\ncall printf\nmov rax,rbx\n",
+		".giveback:\nmov rbx,qword [rbp-8]\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("getProcedureAssembly() = %v, missing %v", got, want)
+		}
+	}
+}
+
+func Test_parseStatement(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Giveback",
+			args{"giveback 7 #"},
+			"(giveback 7)",
+		},
+		{
+			"Call on the right of an assignment",
+			args{"x = double £ 2 $ #"},
+			"(= x (call double 2))",
+		},
+		{
+			"Call as an argument",
+			args{"double £ double £ 3 $ $ #"},
+			"(call double (call double 3))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ast.Sprint(parserFor(tt.args.source).parseStatement()); got != tt.want {
+				t.Errorf("parseStatement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_procedureLabel(t *testing.T) {
+	type args struct {
+		name string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Entry point", args{"thisisthepie"}, "main"},
+		{"Plain", args{"total2"}, "halfleft_total2"},
+		{"Underscores", args{"add_up"}, "halfleft_add__up"},
+		{"Starting with a digit", args{"2nd"}, "halfleft__32nd"},
+		{"Other bytes", args{"naïve:x"}, "halfleft_na_C3_AFve_3Ax"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := procedureLabel(tt.args.name); got != tt.want {
+				t.Errorf("procedureLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ir"
+	"github.com/Jordank321/GaryLang/x86"
+)
+
+// nasmBackend writes NASM x86-64 assembly from a set of snippets, assembles it with nasm
+// or the assembler built in and links it against the C runtime with gcc
+type nasmBackend struct {
+	Start64bit      string
+	Datasection     string
+	Alignconstbytes string
+	Codesection     string
+	Invoke          string
+	C               string
+	Allocatestack   string
+	Releasestack    string
+	Exit            string
+	End             string
+
+	// ArgumentRegisters carry the first arguments of a call, the rest go on the stack
+	ArgumentRegisters []string
+	// ShadowSpace is reserved at rsp below any stack arguments for every call
+	ShadowSpace int
+	// PreservedRegisters must hold the same value when a procedure returns as when it was called
+	PreservedRegisters []string
+	// ScratchRegisters may be overwritten by any call, neither list includes a register
+	// the snippets or the emitted instructions use for something else
+	ScratchRegisters []string
+
+	ObjectFormat        string
+	ObjectExtension     string
+	ExecutableExtension string
+	LinkFlags           []string
+	// Assemble turns the output into an object without running nasm, nil when there is no way to
+	Assemble func(source string) ([]byte, error)
+	// LinkStatic turns the output into an executable without the C library, nil when there is no way to
+	LinkStatic func(source string) ([]byte, error)
+}
+
+func (b *nasmBackend) Prologue() string {
+	return b.Start64bit
+}
+
+func (b *nasmBackend) DataSection(consts map[string][]byte) string {
+	names := []string{}
+	for name := range consts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	content := b.Datasection
+	for _, name := range names {
+		content += b.Constant(name, consts[name])
+	}
+	content += b.Alignconstbytes
+	return content
+}
+
+// Constant quotes runs of printable characters and writes every other byte as a number,
+// since NASM does not decode escapes inside "..."
+func (b *nasmBackend) Constant(name string, bytes []byte) string {
+	parts := []string{}
+	quoted := ""
+	for _, c := range bytes {
+		if c >= ' ' && c <= '~' && c != '"' {
+			quoted += string(c)
+			continue
+		}
+		if quoted != "" {
+			parts = append(parts, "\""+quoted+"\"")
+			quoted = ""
+		}
+		parts = append(parts, strconv.Itoa(int(c)))
+	}
+	if quoted != "" {
+		parts = append(parts, "\""+quoted+"\"")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "\"\"")
+	}
+	return name + ": db " + strings.Join(parts, ",") + "\n"
+}
+
+func (b *nasmBackend) CodeSection(externs []string) string {
+	content := b.Codesection
+	content += b.Invoke
+	content += b.C
+	for _, extern := range externs {
+		content += "extern " + extern + "\n"
+	}
+	return content
+}
+
+func (b *nasmBackend) Registers() ir.Registers {
+	return ir.Registers{Preserved: b.PreservedRegisters, Scratch: b.ScratchRegisters}
+}
+
+// FrameSize is how far rsp is moved below the saved rbp: the locals, then the saved registers,
+// then the shadow space and stack arguments of calls, rounded up to keep rsp on a 16 byte boundary
+func (b *nasmBackend) FrameSize(frame Frame) int {
+	frameSize := (frame.Locals+len(frame.Saved))*8 + b.ShadowSpace
+	if frame.CallArgs > len(b.ArgumentRegisters) {
+		frameSize += (frame.CallArgs - len(b.ArgumentRegisters)) * 8
+	}
+	if frameSize%16 != 0 {
+		frameSize += 16 - frameSize%16
+	}
+	return frameSize
+}
+
+func (b *nasmBackend) ProcedureStart(name string, frame Frame) string {
+	content := "\n" + name + ":\n"
+	content += strings.Replace(b.Allocatestack, "$frameSize", strconv.Itoa(b.FrameSize(frame)), -1)
+	for index, register := range frame.Saved {
+		content += "mov qword " + b.Local(frame.Locals+index) + "," + register + "\n"
+	}
+	return content
+}
+
+func (b *nasmBackend) Local(index int) string {
+	return "[rbp-" + strconv.Itoa((index+1)*8) + "]"
+}
+
+func (b *nasmBackend) Parameter(index int) string {
+	if index < len(b.ArgumentRegisters) {
+		return b.ArgumentRegisters[index]
+	}
+	// above the saved rbp and return address, past the shadow space
+	offset := 16 + b.ShadowSpace + (index-len(b.ArgumentRegisters))*8
+	return "[rbp+" + strconv.Itoa(offset) + "]"
+}
+
+// returnLabel is local to each procedure label, so every procedure has its own
+const returnLabel = ".giveback"
+
+func (b *nasmBackend) Return(value string) string {
+	return "mov rax," + value + "\njmp " + returnLabel + "\n"
+}
+
+func (b *nasmBackend) Label(name string) string {
+	return name + ":\n"
+}
+
+func (b *nasmBackend) Jump(label string) string {
+	return "jmp " + label + "\n"
+}
+
+func (b *nasmBackend) JumpIfZero(label string) string {
+	return "test rax,rax\njz " + label + "\n"
+}
+
+func (b *nasmBackend) ProcedureEnd(frame Frame) string {
+	content := returnLabel + ":\n"
+	for index, register := range frame.Saved {
+		content += "mov " + register + ",qword " + b.Local(frame.Locals+index) + "\n"
+	}
+	return content + b.Releasestack + b.Exit
+}
+
+// Call uses the Invoke macro when the snippets have one, without it every call is written out in full
+func (b *nasmBackend) Call(function string, args []string) string {
+	if b.Invoke != "" {
+		return "Invoke " + strings.Join(append([]string{function}, args...), ",") + "\n"
+	}
+	content := ""
+	for index, arg := range args {
+		if index < len(b.ArgumentRegisters) {
+			content += moveArgument(b.ArgumentRegisters[index], arg)
+			continue
+		}
+		content += moveArgument("rax", arg)
+		content += "mov qword [rsp+" + strconv.Itoa(b.ShadowSpace+(index-len(b.ArgumentRegisters))*8) + "],rax\n"
+	}
+	// al holds the number of vector registers used by a varargs call, which is always zero here
+	return content + "xor eax,eax\ncall " + function + "\n"
+}
+
+// moveArgument puts arg in register, the address of a constant is taken relative to rip
+func moveArgument(register string, arg string) string {
+	if _, err := strconv.ParseInt(arg, 10, 64); err != nil && !strings.HasPrefix(arg, "[") && !x86.IsRegister(arg) {
+		return "lea " + register + ",[rel " + arg + "]\n"
+	}
+	return "mov " + register + "," + arg + "\n"
+}
+
+func (b *nasmBackend) Epilogue() string {
+	return b.End
+}
+
+func (b *nasmBackend) Build(sourcePath string, options BuildOptions) (string, error) {
+	if err := options.Check(); err != nil {
+		return "", err
+	}
+	basePath := strings.TrimSuffix(sourcePath, ".asm")
+	exePath := basePath + b.ExecutableExtension
+
+	if options.Linker == "builtin" {
+		if b.LinkStatic == nil {
+			return "", fmt.Errorf("there is no builtin linker for this target, use gcc")
+		}
+		source, err := ioutil.ReadFile(sourcePath)
+		if err != nil {
+			return "", err
+		}
+		executable, err := b.LinkStatic(string(source))
+		if err != nil {
+			return "", err
+		}
+		return exePath, ioutil.WriteFile(exePath, executable, 0755)
+	}
+
+	objPath := basePath + b.ObjectExtension
+	if options.Assembler == "builtin" || options.Assembler == "" && b.Assemble != nil {
+		if b.Assemble == nil {
+			return "", fmt.Errorf("there is no builtin assembler for this target, use nasm")
+		}
+		source, err := ioutil.ReadFile(sourcePath)
+		if err != nil {
+			return "", err
+		}
+		object, err := b.Assemble(string(source))
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(objPath, object, 0644); err != nil {
+			return "", err
+		}
+	} else {
+		if err := runTool("nasm", sourcePath, "-f"+b.ObjectFormat, "-o"+objPath); err != nil {
+			return "", err
+		}
+	}
+
+	linkArgs := append([]string{objPath}, b.LinkFlags...)
+	if err := runTool("gcc", append(linkArgs, "-o"+exePath)...); err != nil {
+		return "", err
+	}
+	return exePath, nil
+}
+
+// runTool runs a program the build needs, passing on what it prints, such as warnings, when it
+// succeeds and giving it back in the error when it fails
+func runTool(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v\n%s", name, err, out)
+	}
+	if len(out) > 0 {
+		os.Stderr.Write(out)
+	}
+	return nil
+}
+
+// assembleELF64 assembles source with the assembler built in, for targets linking ELF64 objects
+func assembleELF64(source string) ([]byte, error) {
+	object, err := x86.Assemble(source)
+	if err != nil {
+		return nil, err
+	}
+	return object.Relocatable(), nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+func Test_nasmBackend_Constant(t *testing.T) {
+	type args struct {
+		name  string
+		bytes []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Printable string",
+			args{"p0", append([]byte("Hello  world!"), 0)},
+			"p0: db \"Hello  world!\",0\n",
+		},
+		{
+			"Control characters and quotes",
+			args{"p1", append([]byte("say \"hi\"\n\tbye"), 0)},
+			"p1: db \"say \",34,\"hi\",34,10,9,\"bye\",0\n",
+		},
+		{
+			"Bytes outside ascii",
+			args{"p2", []byte("¬\x01")},
+			"p2: db 194,172,1\n",
+		},
+		{
+			"Empty",
+			args{"p3", []byte{}},
+			"p3: db \"\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := windowsBackend().Constant(tt.args.name, tt.args.bytes); got != tt.want {
+				t.Errorf("Constant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nasmBackend_Build(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("running the programs needs linux-amd64")
+	}
+	_, gccErr := exec.LookPath("gcc")
+	type args struct {
+		source  string
+		level   int
+		options BuildOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Strings and a loop",
+			args{`halfleft thisisthepie £ $ /
+	printthething £ ¬hello ¬ $ #
+	i = 0 #
+	whilst £ i < 3 $ /
+		printthenumber £ i $ #
+		i = i + 1 #
+	\
+\`, 1, BuildOptions{Assembler: "builtin"}},
+			"hello 012",
+		},
+		{
+			"Recursion keeps values in saved registers",
+			args{`halfleft thisisthepie £ $ /
+	printthenumber £ factorial £ 10 $ $ #
+\
+halfleft factorial £ n $ /
+	perchance £ n < 2 $ /
+		giveback 1 #
+	\
+	giveback n * factorial £ ( n - 1 ) $ #
+\`, 1, BuildOptions{}},
+			"3628800",
+		},
+		{
+			"Arguments past the registers go on the stack",
+			args{`halfleft thisisthepie £ $ /
+	total £ 1 2 3 4 5 6 7 $ #
+\
+halfleft total £ a b c d e f g $ /
+	printthenumber £ ( a - g ) $ #
+\`, 0, BuildOptions{}},
+			"-6",
+		},
+		{
+			"Static executable with the runtime of its own",
+			args{`halfleft thisisthepie £ $ /
+	printthevalue £ ¬100%¬ $ #
+	printthevalue £ ( 0 - 42 ) $ #
+	total £ 1 2 3 4 5 6 7 $ #
+\
+halfleft total £ a b c d e f g $ /
+	printthenumber £ ( a - g ) $ #
+\`, 0, BuildOptions{Linker: "builtin"}},
+			"100%-42-6",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gccErr != nil && tt.args.options.Linker != "builtin" {
+				t.Skip("linking against the C library needs gcc")
+			}
+			backend, _ := GetBackend("linux-amd64")
+			program := lowerProgram(treeFromTokens(tokenize(tt.args.source)))
+			ir.Optimise(program, tt.args.level)
+			externs := cExternsFromAssemblyFiles(*usedBuiltinFunctions(program, &[]string{}))
+			source := getAssembly(backend, getAssemblyBodyFromIR(backend, program), externs, getAssemblyConstantsFromIR(program))
+			sourcePath := filepath.Join(t.TempDir(), "program.asm")
+			if err := ioutil.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			exePath, err := backend.Build(sourcePath, tt.args.options)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			got, err := exec.Command(exePath).Output()
+			if err != nil {
+				t.Fatalf("running the program: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Build() program printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_nasmBackend_nasm checks nasm takes the assembly of every target it assembles for, at every level
+func Test_nasmBackend_nasm(t *testing.T) {
+	if _, err := exec.LookPath("nasm"); err != nil {
+		t.Skip("assembling with nasm needs nasm")
+	}
+	for _, target := range []string{"windows-amd64", "linux-amd64"} {
+		backend, _ := GetBackend(target)
+		for _, tt := range interpreterPrograms {
+			for level := 0; level <= 1; level++ {
+				t.Run(target+"/"+tt.name, func(t *testing.T) {
+					program := lowerProgram(treeFromTokens(tokenize(tt.source)))
+					ir.Optimise(program, level)
+					externs := cExternsFromAssemblyFiles(*usedBuiltinFunctions(program, &[]string{}))
+					source := getAssembly(backend, getAssemblyBodyFromIR(backend, program), externs, getAssemblyConstantsFromIR(program))
+					dir := t.TempDir()
+					sourcePath := filepath.Join(dir, "program.asm")
+					if err := ioutil.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+						t.Fatal(err)
+					}
+					output, err := exec.Command("nasm", sourcePath, "-f"+backend.(*nasmBackend).ObjectFormat, "-o"+filepath.Join(dir, "program.o")).CombinedOutput()
+					if err != nil {
+						t.Errorf("at level %d nasm gave %v: %s", level, err, output)
+					}
+				})
+			}
+		}
+	}
+}
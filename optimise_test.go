@@ -0,0 +1,184 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+)
+
+func Test_Optimise(t *testing.T) {
+	type args struct {
+		source string
+		level  int
+	}
+	source := `halfleft thisisthepie £ $ /
+	width = 3 #
+	area = width * 4 #
+	unused = area - 1 #
+	perchance £ area > 10 $ /
+		printthenumber £ area $ #
+	\ otherwise /
+		printthething £ ¬small¬ $ #
+	\
+	n = 0 #
+	whilst £ n < 5 $ /
+		n = n + 1 #
+	\
+	printthenumber £ n $ #
+\`
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Level 1 folds constants and removes what is never used",
+			args{source, 1},
+			`
+halfleft thisisthepie params 0 slots 1
+entry:
+	call builtin printthenumber(12)
+	store s0, 0
+	jump whilst2
+whilst2:
+	r0 = load s0
+	r1 = lt r0, 5
+	branch r1, body2, endwhilst2
+body2:
+	r2 = load s0
+	r3 = add r2, 1
+	store s0, r3
+	jump whilst2
+endwhilst2:
+	r4 = load s0
+	call builtin printthenumber(r4)
+	return 0
+`,
+		},
+		{
+			"Self-recursive tail calls jump back to the start even at level 0",
+			args{`halfleft thisisthepie £ $ /
+	printthenumber £ sum £ 100000 0 $ $ #
+	printthenumber £ factorial £ 5 $ $ #
+\
+halfleft sum £ n total $ /
+	perchance £ n == 0 $ /
+		giveback total #
+	\
+	giveback sum £ ( n - 1 ) ( total + n ) $ #
+\
+halfleft factorial £ n $ /
+	perchance £ n < 2 $ /
+		giveback 1 #
+	\
+	giveback n * factorial £ ( n - 1 ) $ #
+\`, 0},
+			`
+halfleft thisisthepie params 0 slots 0
+entry:
+	r0 = call sum(100000, 0)
+	call builtin printthenumber(r0)
+	r1 = call factorial(5)
+	call builtin printthenumber(r1)
+	return 0
+
+halfleft sum params 2 slots 2
+entry:
+	jump tailcall
+tailcall:
+	r0 = load s0
+	r1 = eq r0, 0
+	branch r1, then1, endperchance1
+then1:
+	r2 = load s1
+	return r2
+endperchance1:
+	r4 = load s0
+	r5 = sub r4, 1
+	r6 = load s1
+	r7 = load s0
+	r8 = add r6, r7
+	store s0, r5
+	store s1, r8
+	jump tailcall
+
+halfleft factorial params 1 slots 1
+entry:
+	r0 = load s0
+	r1 = lt r0, 2
+	branch r1, then1, endperchance1
+then1:
+	return 1
+endperchance1:
+	r2 = load s0
+	r4 = load s0
+	r5 = sub r4, 1
+	r3 = call factorial(r5)
+	r6 = mul r2, r3
+	return r6
+`,
+		},
+		{
+			"Level 1 copies small procedures into their callers and drops them",
+			args{`halfleft thisisthepie £ $ /
+	printthenumber £ sum £ 100000 0 $ $ #
+	printthenumber £ square £ 7 $ $ #
+\
+halfleft sum £ n total $ /
+	perchance £ n == 0 $ /
+		giveback total #
+	\
+	giveback sum £ ( n - 1 ) ( total + n ) $ #
+\
+halfleft square £ n $ /
+	giveback n * n #
+\`, 1},
+			`
+halfleft thisisthepie params 0 slots 2
+entry:
+	store s0, 100000
+	store s1, 0
+	jump sum1_tailcall
+sum1_tailcall:
+	r0 = load s0
+	r1 = eq r0, 0
+	branch r1, sum1_then1, sum1_endperchance1
+sum1_then1:
+	r2 = load s1
+	call builtin printthenumber(r2)
+	call builtin printthenumber(49)
+	return 0
+sum1_endperchance1:
+	r3 = load s0
+	r4 = sub r3, 1
+	r5 = load s1
+	r6 = add r5, r3
+	store s0, r4
+	store s1, r6
+	jump sum1_tailcall
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := lowerProgram(treeFromTokens(tokenize(tt.args.source)))
+			ir.Optimise(program, tt.args.level)
+			if got := program.String(); got != tt.want {
+				t.Errorf("Optimise() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Optimise_level0(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	x = 1 + 2 #
+	printthenumber £ x $ #
+\`
+	want := lowerProgram(treeFromTokens(tokenize(source))).String()
+	program := lowerProgram(treeFromTokens(tokenize(source)))
+	ir.Optimise(program, 0)
+	if got := program.String(); got != want {
+		t.Errorf("Optimise() at level 0 = %v, want %v", got, want)
+	}
+}
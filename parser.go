@@ -0,0 +1,254 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// parser builds the syntax tree of a program by recursive descent over its tokens
+type parser struct {
+	tokens      []Token
+	diagnostics *Diagnostics
+	position    int
+	loopDepth   int
+	// unterminated is set once a missing \ has been reported, every enclosing block is missing one too
+	unterminated bool
+}
+
+func (p *parser) peek() *Token {
+	if p.position >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.position]
+}
+
+func (p *parser) expect(tokenType TokeType, description string) {
+	next := p.peek()
+	if next == nil {
+		failAt(p.previousPosition(), "expected %s", description)
+	}
+	if next.Type != tokenType {
+		failAt(next.Position, "expected %s but found %s", description, describeToken(*next))
+	}
+	p.position++
+}
+
+// previousPosition is the position of the last token read, where anything missing after it belongs
+func (p *parser) previousPosition() Position {
+	if p.position == 0 || len(p.tokens) == 0 {
+		return Position{}
+	}
+	if p.position > len(p.tokens) {
+		return p.tokens[len(p.tokens)-1].Position
+	}
+	return p.tokens[p.position-1].Position
+}
+
+// endsBlock is true for the tokens that no statement can run past
+func endsBlock(tok *Token) bool {
+	return tok == nil || tok.Type == BodyEnd || tok.Type == ProcedureDefine
+}
+
+// parseProgram reads every procedure in tokens, reporting every problem it finds rather than stopping at the first
+func parseProgram(tokens *[]Token, diagnostics *Diagnostics) *ast.Program {
+	p := parser{tokens: *tokens, diagnostics: diagnostics}
+	program := &ast.Program{}
+	for {
+		next := p.peek()
+		if next == nil {
+			return program
+		}
+		if next.Type != ProcedureDefine {
+			diagnostics.Error(next.Position, "unexpected %s outside of a procedure, procedures start with halfleft", describeToken(*next))
+			p.skipProcedure()
+			continue
+		}
+		if procedure := p.parseProcedure(); procedure != nil {
+			program.Procedures = append(program.Procedures, procedure)
+		}
+	}
+}
+
+// parseProcedure reads halfleft name £ params $ / body \, a procedure whose declaration
+// has a problem is still returned, with an empty body, so calls to it can be checked
+func (p *parser) parseProcedure() *ast.ProcDecl {
+	p.position++
+	name := p.peek()
+	if name == nil || name.Type != Name {
+		p.diagnostics.Error(p.previousPosition(), "expected the name of the procedure after halfleft")
+		p.skipProcedure()
+		return nil
+	}
+	p.position++
+	procedure := &ast.ProcDecl{
+		Position: name.Position,
+		Name:     *name.Value,
+		Body:     &ast.Block{Position: name.Position},
+	}
+	if !p.parseParams(procedure) {
+		p.skipProcedure()
+		return procedure
+	}
+
+	p.unterminated = false
+	procedure.Body = p.parseBlock()
+	if stray := p.peek(); stray != nil && stray.Type != ProcedureDefine {
+		p.diagnostics.Error(stray.Position, "unexpected %s after the end of procedure %s", describeToken(*stray), procedure.Name)
+		p.skipProcedure()
+	}
+	return procedure
+}
+
+// parseParams reads £ params $ / reporting anything out of place
+func (p *parser) parseParams(procedure *ast.ProcDecl) bool {
+	expected := ParamOpen
+	for {
+		next := p.peek()
+		if next == nil || next.Type == ProcedureDefine {
+			p.diagnostics.Error(procedure.Position, "expected %s in the declaration of %s", tokenText[expected], procedure.Name)
+			return false
+		}
+		p.position++
+		switch {
+		case expected == ParamClose && next.Type == Name:
+			procedure.Params = append(procedure.Params, &ast.Param{Position: next.Position, Name: *next.Value})
+		case next.Type != expected:
+			p.diagnostics.Error(next.Position, "expected %s in the declaration of %s but found %s", tokenText[expected], procedure.Name, describeToken(*next))
+			return false
+		case expected == ParamOpen:
+			expected = ParamClose
+		case expected == ParamClose:
+			expected = BodyStart
+		default:
+			return true
+		}
+	}
+}
+
+// skipProcedure moves on to the next halfleft
+func (p *parser) skipProcedure() {
+	for next := p.peek(); next != nil && next.Type != ProcedureDefine; next = p.peek() {
+		p.position++
+	}
+}
+
+// parseBlock reads statements up to and including the \ closing the block, the / opening it
+// has just been read. A statement with a problem is reported and left out so the rest can be checked
+func (p *parser) parseBlock() *ast.Block {
+	opening := p.tokens[p.position-1]
+	block := &ast.Block{Position: opening.Position}
+	for {
+		next := p.peek()
+		if next == nil || next.Type == ProcedureDefine {
+			if !p.unterminated {
+				p.diagnostics.Error(opening.Position, "missing \\ to close the block opened here")
+				p.unterminated = true
+			}
+			return block
+		}
+		if next.Type == BodyEnd {
+			p.position++
+			return block
+		}
+		if statement, ok := p.tryStatement(); ok {
+			block.Statements = append(block.Statements, statement)
+		}
+	}
+}
+
+// tryStatement parses a statement, reporting why it could not be parsed and skipping past its # when it fails
+func (p *parser) tryStatement() (statement ast.Statement, ok bool) {
+	startIndex := p.position
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		diagnostic, isDiagnostic := recovered.(Diagnostic)
+		if !isDiagnostic {
+			panic(recovered)
+		}
+		p.diagnostics.Add(diagnostic)
+		// a statement that failed once its tokens were all read has nothing left to skip
+		if p.position == startIndex || p.tokens[p.position-1].Type != EndLine {
+			p.skipStatement()
+		}
+		ok = false
+	}()
+	return p.parseStatement(), true
+}
+
+// skipStatement moves past the next #, or up to the end of the block if that comes first
+func (p *parser) skipStatement() {
+	for next := p.peek(); !endsBlock(next); next = p.peek() {
+		p.position++
+		if next.Type == EndLine {
+			return
+		}
+	}
+}
+
+func (p *parser) parseStatement() ast.Statement {
+	start := *p.peek()
+	switch start.Type {
+	case If:
+		return p.parseConditional()
+	case While:
+		return p.parseLoop()
+	case Break, Continue:
+		return p.parseLoopControl()
+	case EndLine:
+		failAt(start.Position, "empty statement")
+	case Return:
+		p.position++
+		value := p.parseExpression()
+		p.endStatement(start)
+		return &ast.Return{Position: start.Position, Value: value}
+	}
+
+	if start.Type == Name && p.position+1 < len(p.tokens) && p.tokens[p.position+1].Type == Assign {
+		p.position += 2
+		value := p.parseExpression()
+		p.endStatement(start)
+		return &ast.Assign{
+			Position: start.Position,
+			Target:   &ast.Ident{Position: start.Position, Name: *start.Value},
+			Value:    value,
+		}
+	}
+
+	value := p.parseExpression()
+	p.endStatement(start)
+	call, isCall := value.(*ast.Call)
+	if !isCall {
+		failAt(start.Position, "statement must be an assignment, a giveback or a call")
+	}
+	return call
+}
+
+// endStatement reads the # ending the statement that began with start
+func (p *parser) endStatement(start Token) {
+	next := p.peek()
+	if endsBlock(next) {
+		failAt(start.Position, "missing # at the end of this statement")
+	}
+	if next.Type != EndLine {
+		failAt(next.Position, "unexpected %s in expression", describeToken(*next))
+	}
+	p.position++
+}
+
+// parseCondition reads the expression between £ and $ after perchance or whilst
+func (p *parser) parseCondition() ast.Expression {
+	p.expect(ParamOpen, "£ before the condition")
+	opening := p.tokens[p.position-1]
+	condition := p.parseExpression()
+	next := p.peek()
+	if endsBlock(next) || next.Type == EndLine {
+		failAt(opening.Position, "missing $ after the condition")
+	}
+	if next.Type != ParamClose {
+		failAt(next.Position, "unexpected %s in expression", describeToken(*next))
+	}
+	p.position++
+	return condition
+}
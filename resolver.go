@@ -0,0 +1,192 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// scope is the names visible at one level of the program: the procedures and builtins
+// at the top, then the parameters of a procedure, then the variables of each block inside it
+type scope struct {
+	parent  *scope
+	symbols map[string]*ast.Symbol
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, symbols: map[string]*ast.Symbol{}}
+}
+
+// lookup finds name in this scope or the nearest one enclosing it
+func (s *scope) lookup(name string) *ast.Symbol {
+	for current := s; current != nil; current = current.parent {
+		if symbol, exists := current.symbols[name]; exists {
+			return symbol
+		}
+	}
+	return nil
+}
+
+// resolver binds every name in a program to the symbol it refers to
+type resolver struct {
+	program     *ast.Program
+	diagnostics *Diagnostics
+	global      *scope
+	scope       *scope
+	procedure   *ast.ProcDecl
+}
+
+// resolveProgram points every Ident and Call at its symbol and gives every procedure its locals, reporting
+// names that are undefined or defined twice, calls with the wrong number of arguments, a missing
+// thisisthepie and, when there is nothing worse to report, procedures that are never called
+func resolveProgram(program *ast.Program, diagnostics *Diagnostics) {
+	r := resolver{program: program, diagnostics: diagnostics, global: newScope(nil)}
+	for _, name := range GetStandardFunctionNames() {
+		r.global.symbols[name] = &ast.Symbol{Name: name, Kind: ast.BuiltinSymbol, Type: GetStandardFunction(name).Result}
+	}
+	for _, procedure := range program.Procedures {
+		existing := r.global.symbols[procedure.Name]
+		switch {
+		case existing == nil:
+			procedure.Symbol = &ast.Symbol{Position: procedure.Position, Name: procedure.Name, Kind: ast.ProcedureSymbol}
+			r.global.symbols[procedure.Name] = procedure.Symbol
+		case existing.Kind == ast.BuiltinSymbol:
+			diagnostics.Error(procedure.Position, "procedure %s has the same name as a standard function", procedure.Name)
+		default:
+			diagnostics.Error(procedure.Position, "procedure %s is already defined", procedure.Name)
+		}
+	}
+	for _, procedure := range program.Procedures {
+		r.resolveProcedure(procedure)
+	}
+
+	if program.Procedure("thisisthepie") == nil {
+		diagnostics.Error(Position{}, "there is no halfleft thisisthepie for the program to start from")
+		return
+	}
+	if diagnostics.HasErrors() {
+		// calls that could not be parsed would make their procedures look unused
+		return
+	}
+	used := map[*ast.ProcDecl]bool{}
+	for _, procedure := range usedProcedures(program) {
+		used[procedure] = true
+	}
+	for _, procedure := range program.Procedures {
+		if !used[procedure] {
+			diagnostics.Warning(procedure.Position, "procedure %s is never called", procedure.Name)
+		}
+	}
+}
+
+func (r *resolver) resolveProcedure(procedure *ast.ProcDecl) {
+	r.procedure = procedure
+	procedure.Locals = nil
+	r.scope = newScope(r.global)
+	for _, param := range procedure.Params {
+		if _, exists := r.scope.symbols[param.Name]; exists {
+			r.diagnostics.Error(param.Position, "parameter %s is already defined", param.Name)
+			continue
+		}
+		r.define(param.Position, param.Name, ast.ParamSymbol)
+	}
+	r.block(procedure.Body)
+	r.scope = nil
+}
+
+// define adds a parameter or variable to the innermost scope and gives it the next slot of the procedure
+func (r *resolver) define(position Position, name string, kind ast.SymbolKind) *ast.Symbol {
+	symbol := &ast.Symbol{Position: position, Name: name, Kind: kind, Index: len(r.procedure.Locals)}
+	r.scope.symbols[name] = symbol
+	r.procedure.Locals = append(r.procedure.Locals, symbol)
+	return symbol
+}
+
+// block resolves the statements of a block in a scope of its own, so variables first set inside it end with it
+func (r *resolver) block(block *ast.Block) {
+	if block == nil {
+		return
+	}
+	r.scope = newScope(r.scope)
+	for _, statement := range block.Statements {
+		r.statement(statement)
+	}
+	r.scope = r.scope.parent
+}
+
+func (r *resolver) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.Assign:
+		// the value is resolved first so x = x # cannot read x before it has been set
+		r.expression(statement.Value)
+		target := statement.Target
+		symbol := r.scope.lookup(target.Name)
+		switch {
+		case symbol == nil:
+			symbol = r.define(target.Position, target.Name, ast.LocalSymbol)
+		case symbol.Kind == ast.ProcedureSymbol || symbol.Kind == ast.BuiltinSymbol:
+			r.diagnostics.Error(target.Position, "cannot assign to %s, it is a procedure", target.Name)
+			return
+		}
+		target.Symbol = symbol
+	case *ast.Return:
+		r.expression(statement.Value)
+	case *ast.If:
+		r.expression(statement.Condition)
+		r.block(statement.Then)
+		r.block(statement.Else)
+	case *ast.While:
+		r.expression(statement.Condition)
+		r.block(statement.Body)
+	case *ast.Call:
+		r.call(statement)
+	}
+}
+
+func (r *resolver) expression(expression ast.Expression) {
+	switch expression := expression.(type) {
+	case *ast.Ident:
+		symbol := r.scope.lookup(expression.Name)
+		switch {
+		case symbol == nil:
+			r.diagnostics.Error(expression.Position, "undefined name %s", expression.Name)
+		case symbol.Kind == ast.ProcedureSymbol || symbol.Kind == ast.BuiltinSymbol:
+			r.diagnostics.Error(expression.Position, "%s is a procedure, call it with £ $", expression.Name)
+		default:
+			expression.Symbol = symbol
+		}
+	case *ast.Call:
+		r.call(expression)
+	case *ast.Binary:
+		r.expression(expression.Left)
+		r.expression(expression.Right)
+	case *ast.Unary:
+		r.expression(expression.Operand)
+	}
+}
+
+// call binds the procedure or builtin being called and checks it is given as many arguments as it takes
+func (r *resolver) call(call *ast.Call) {
+	for _, arg := range call.Args {
+		r.expression(arg)
+	}
+	symbol := r.scope.lookup(call.Name)
+	params := 0
+	switch {
+	case symbol == nil:
+		r.diagnostics.Error(call.Position, "call to unknown procedure %s", call.Name)
+		return
+	case symbol.Kind == ast.BuiltinSymbol:
+		params = len(GetStandardFunction(call.Name).Parameters)
+	case symbol.Kind == ast.ProcedureSymbol:
+		params = len(r.program.Procedure(call.Name).Params)
+	default:
+		r.diagnostics.Error(call.Position, "%s is a variable, not a procedure", call.Name)
+		return
+	}
+	call.Symbol = symbol
+	switch {
+	case len(call.Args) > params:
+		r.diagnostics.Error(call.Args[params].Pos(), "too many arguments to %s, it takes %s", call.Name, argumentCount(params))
+	case len(call.Args) < params:
+		r.diagnostics.Error(call.Position, "%s takes %s but is given %d", call.Name, argumentCount(params), len(call.Args))
+	}
+}
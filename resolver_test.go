@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+func Test_resolveProgram(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			"Undefined name",
+			args{"halfleft thisisthepie £ $ /\n\tprintthenumber £ x $ #\n\\"},
+			[]string{"test.gry:2:19: error: undefined name x"},
+		},
+		{
+			"Used before it is assigned",
+			args{"halfleft thisisthepie £ $ /\n\tx = x + 1 #\n\\"},
+			[]string{"test.gry:2:6: error: undefined name x"},
+		},
+		{
+			"Variable ends with its block",
+			args{"halfleft thisisthepie £ $ /\n\tperchance £ 1 $ /\n\t\ty = 2 #\n\t\\\n\tprintthenumber £ y $ #\n\\"},
+			[]string{"test.gry:5:19: error: undefined name y"},
+		},
+		{
+			"Duplicate parameter",
+			args{"halfleft thisisthepie £ $ /\n\tpair £ 1 2 $ #\n\\\nhalfleft pair £ a a $ /\n\\"},
+			[]string{"test.gry:4:19: error: parameter a is already defined"},
+		},
+		{
+			"Procedure named after a standard function",
+			args{"halfleft thisisthepie £ $ /\n\\\nhalfleft printthenumber £ n $ /\n\\"},
+			[]string{"test.gry:3:10: error: procedure printthenumber has the same name as a standard function"},
+		},
+		{
+			"Procedure used as a value",
+			args{"halfleft thisisthepie £ $ /\n\tx = thisisthepie #\n\\"},
+			[]string{"test.gry:2:6: error: thisisthepie is a procedure, call it with £ $"},
+		},
+		{
+			"Assigning to a procedure",
+			args{"halfleft thisisthepie £ $ /\n\tprintthething = 1 #\n\\"},
+			[]string{"test.gry:2:2: error: cannot assign to printthething, it is a procedure"},
+		},
+		{
+			"Calling a variable",
+			args{"halfleft thisisthepie £ $ /\n\tx = 1 #\n\tx £ $ #\n\\"},
+			[]string{"test.gry:3:2: error: x is a variable, not a procedure"},
+		},
+		{
+			"Assigning in a nested block sets the outer variable",
+			args{"halfleft thisisthepie £ $ /\n\tx = 1 #\n\twhilst £ x < 3 $ /\n\t\tx = x + 1 #\n\t\\\n\tprintthenumber £ x $ #\n\\"},
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := NewDiagnostics("test.gry", tt.args.source)
+			resolveProgram(parseProgram(tokenize(tt.args.source), diagnostics), diagnostics)
+			got := []string{}
+			for _, line := range strings.Split(diagnostics.String(), "\n") {
+				if strings.HasPrefix(line, "test.gry") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("resolveProgram() reported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveProgram_symbols(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	printthenumber £ sum £ 1 2 $ $ #
+\
+halfleft sum £ a b $ /
+	total = a + b #
+	perchance £ total > 2 $ /
+		extra = 1 #
+		total = total + extra #
+	\
+	giveback total #
+\`))
+	procedure := tree.Procedure("sum")
+	locals := []string{}
+	for _, symbol := range procedure.Locals {
+		locals = append(locals, symbol.Name)
+	}
+	if got := strings.Join(locals, " "); got != "a b total extra" {
+		t.Errorf("resolveProgram() gave sum the locals %v", got)
+	}
+
+	kinds := map[string]ast.SymbolKind{}
+	ast.Inspect(tree, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Ident:
+			if node.Symbol == nil || node.Symbol.Name != node.Name {
+				t.Errorf("resolveProgram() bound %v at %v to %v", node.Name, node.Position, node.Symbol)
+			} else if node.Symbol != procedure.Locals[node.Symbol.Index] {
+				t.Errorf("resolveProgram() bound %v to a symbol outside of the locals", node.Name)
+			}
+		case *ast.Call:
+			if node.Symbol == nil {
+				t.Errorf("resolveProgram() did not bind the call to %v", node.Name)
+			} else {
+				kinds[node.Name] = node.Symbol.Kind
+			}
+		}
+		return true
+	})
+	if kinds["printthenumber"] != ast.BuiltinSymbol || kinds["sum"] != ast.ProcedureSymbol {
+		t.Errorf("resolveProgram() bound the calls to %v", kinds)
+	}
+}
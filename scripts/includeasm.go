@@ -1,26 +1,42 @@
 package main
 
 import (
-	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 )
 
-// Reads all .txt files in the current folder
-// and encodes them as strings literals in textfiles.go
+// Each snippet folder is encoded into its own package
+var snippetFolders = []struct {
+	folder  string
+	pkgName string
+}{
+	{"windowsAssembly", "asmFiles"},
+	{"linuxAssembly", "linuxAsmFiles"},
+}
+
+// Reads all .asm files in the snippet folders
+// and encodes them as strings literals in <package>/<package>.go
 func main() {
-	fs, _ := ioutil.ReadDir("./windowsAssembly")
-	out, _ := os.Create("./asmFiles/asmFiles.go")
-	out.Write([]byte("package asmFiles \n\nconst (\n"))
+	for _, snippets := range snippetFolders {
+		includeFolder(snippets.folder, snippets.pkgName)
+	}
+}
+
+func includeFolder(folder string, pkgName string) {
+	fs, _ := ioutil.ReadDir("./" + folder)
+	os.MkdirAll("./"+pkgName, 0755)
+	out, _ := os.Create("./" + pkgName + "/" + pkgName + ".go")
+	defer out.Close()
+	out.Write([]byte("package " + pkgName + " \n\nconst (\n"))
 	for _, f := range fs {
 		if strings.HasSuffix(f.Name(), ".asm") {
 			out.Write([]byte(strings.Title(strings.TrimSuffix(f.Name(), ".asm")) + " = `"))
-			f, err := os.Open("./windowsAssembly/" + f.Name())
+			contents, err := ioutil.ReadFile("./" + folder + "/" + f.Name())
 			if err != nil {
 				panic(err)
 			}
-			io.Copy(out, f)
+			out.Write([]byte(strings.Replace(string(contents), "\r\n", "\n", -1)))
 			out.Write([]byte("`\n"))
 		}
 	}
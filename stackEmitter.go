@@ -0,0 +1,84 @@
+package main
+
+import "github.com/Jordank321/GaryLang/ir"
+
+// stackEmitter is what the compilers for stack machines share. Each register of the IR is given a
+// local after the slots, except a register read only once, by the instruction straight after the one
+// giving it its value, which is left on the stack for it instead
+type stackEmitter struct {
+	// firstLocal is the first local after the slots, registers are given the locals from it on
+	firstLocal int
+	locals     map[ir.Register]int
+	// uses counts how often each register of the procedure is read
+	uses map[ir.Register]int
+	// onStack is the register whose value is on the stack rather than in its local, NoRegister when
+	// there is none
+	onStack ir.Register
+	// store writes the instruction putting the value on top of the stack in local
+	store func(local int64)
+}
+
+// start readies the emitter for procedure, counting what reads each of its registers
+func (e *stackEmitter) start(procedure *ir.Procedure, store func(local int64)) {
+	e.firstLocal = procedure.Slots
+	e.locals = map[ir.Register]int{}
+	e.uses = map[ir.Register]int{}
+	e.onStack = ir.NoRegister
+	e.store = store
+	for _, block := range procedure.Blocks {
+		for _, instruction := range block.Instructions {
+			for _, arg := range instruction.Args {
+				if arg.Kind == ir.RegisterOperand {
+					e.uses[arg.Register]++
+				}
+			}
+		}
+	}
+}
+
+// flush puts a value left on the stack in its local, for when it is not used straight away
+func (e *stackEmitter) flush() {
+	if e.onStack != ir.NoRegister {
+		register := e.onStack
+		e.onStack = ir.NoRegister
+		e.store(e.local(register))
+	}
+}
+
+// local is the local holding register
+func (e *stackEmitter) local(register ir.Register) int64 {
+	if _, exists := e.locals[register]; !exists {
+		e.locals[register] = e.firstLocal + len(e.locals)
+	}
+	return int64(e.locals[register])
+}
+
+// localCount is how many locals the procedure needs, its slots included
+func (e *stackEmitter) localCount() int {
+	return e.firstLocal + len(e.locals)
+}
+
+// take is whether the value of register is the one on the stack, which the instruction reading it
+// then has, so it need not push it
+func (e *stackEmitter) take(register ir.Register) bool {
+	if register != e.onStack {
+		return false
+	}
+	e.onStack = ir.NoRegister
+	return true
+}
+
+// keep deals with the value an instruction giving register left on the stack, leaving it there when
+// one instruction reads it and putting it in its local when more do. It is false when nothing reads
+// the value, which is left for the compiler to drop
+func (e *stackEmitter) keep(register ir.Register) bool {
+	switch {
+	case register == ir.NoRegister || e.uses[register] == 0:
+		return false
+	case e.uses[register] == 1:
+		e.onStack = register
+	default:
+		e.store(e.local(register))
+	}
+	return true
+}
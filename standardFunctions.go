@@ -1,17 +1,79 @@
 package main
 
 import (
-	"github.com/Jordank321/GaryLang/asmFiles"
+	"io"
+	"sort"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
 )
 
-var standardFunctions map[string]*FunctionDefinitionTree
+// StandardFunction is a procedure the compiler writes straight into the assembly rather than calling
+type StandardFunction struct {
+	Parameters []string
+	// ParameterTypes are what each parameter must be given, ast.Unknown accepts any type
+	ParameterTypes    []ast.Type
+	Result            ast.Type
+	AssembledBodyName string
+}
+
+var standardFunctions map[string]*StandardFunction
+var standardFunctionBodies map[string]func(backend Backend, args []string, types []ast.Type) string
+var standardFunctionInterpretations map[string]func(out io.Writer, args []value, types []ast.Type) value
+var standardFunctionBytecode map[string]func(c *bytecodeCompiler, args []ir.Operand, types []ast.Type)
+var standardFunctionC map[string]func(c *cCompiler, args []string, types []ast.Type) string
+var standardFunctionWasm map[string]func(types []ast.Type) string
+var standardFunctionLLVM map[string]func(c *llvmCompiler, dest string, args []string, types []ast.Type)
+var standardFunctionARM64 map[string]func(c *arm64Compiler, args []ir.Operand, types []ast.Type)
+var standardFunctionConstants map[string]map[string][]byte
 var externDependencies map[string][]string
 var setup bool
 
-func GetStandardFunction(function string) *FunctionDefinitionTree {
+func GetStandardFunction(function string) *StandardFunction {
 	setupStandardFunctions()
 	return standardFunctions[function]
 }
+func GetStandardFunctionNames() []string {
+	setupStandardFunctions()
+	names := []string{}
+	for name := range standardFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+func GetStandardFunctionBody(function string) func(backend Backend, args []string, types []ast.Type) string {
+	setupStandardFunctions()
+	return standardFunctionBodies[function]
+}
+func GetStandardFunctionInterpretation(function string) func(out io.Writer, args []value, types []ast.Type) value {
+	setupStandardFunctions()
+	return standardFunctionInterpretations[function]
+}
+func GetStandardFunctionBytecode(function string) func(c *bytecodeCompiler, args []ir.Operand, types []ast.Type) {
+	setupStandardFunctions()
+	return standardFunctionBytecode[function]
+}
+func GetStandardFunctionC(function string) func(c *cCompiler, args []string, types []ast.Type) string {
+	setupStandardFunctions()
+	return standardFunctionC[function]
+}
+func GetStandardFunctionWasm(function string) func(types []ast.Type) string {
+	setupStandardFunctions()
+	return standardFunctionWasm[function]
+}
+func GetStandardFunctionLLVM(function string) func(c *llvmCompiler, dest string, args []string, types []ast.Type) {
+	setupStandardFunctions()
+	return standardFunctionLLVM[function]
+}
+func GetStandardFunctionARM64(function string) func(c *arm64Compiler, args []ir.Operand, types []ast.Type) {
+	setupStandardFunctions()
+	return standardFunctionARM64[function]
+}
+func GetStandardFunctionConstants(function string) map[string][]byte {
+	setupStandardFunctions()
+	return standardFunctionConstants[function]
+}
 func GetStandardFunctionExterns(function string) []string {
 	setupStandardFunctions()
 	return externDependencies[function]
@@ -21,25 +83,166 @@ func setupStandardFunctions() {
 	if setup {
 		return
 	}
-	standardFunctions = map[string]*FunctionDefinitionTree{
-		"printthething": &FunctionDefinitionTree{
+	standardFunctions = map[string]*StandardFunction{
+		"printthething": &StandardFunction{
 			Parameters:        []string{"printString"},
-			AssembledBodyName: getAdr("printf"),
-			AssembledBodyFile: getAdr(asmFiles.Printf),
+			ParameterTypes:    []ast.Type{ast.String},
+			Result:            ast.Int,
+			AssembledBodyName: "printf",
+		},
+		"printthenumber": &StandardFunction{
+			Parameters:        []string{"number"},
+			ParameterTypes:    []ast.Type{ast.Int},
+			Result:            ast.Int,
+			AssembledBodyName: "printnumber",
+		},
+		"printthevalue": &StandardFunction{
+			Parameters:        []string{"value"},
+			ParameterTypes:    []ast.Type{ast.Unknown},
+			Result:            ast.Int,
+			AssembledBodyName: "printvalue",
+		},
+	}
+	// printf prints its string with %s, so any % in it is printed as it is rather than read as a
+	// conversion wanting arguments that were never given
+	standardFunctionBodies = map[string]func(backend Backend, args []string, types []ast.Type) string{
+		"printf": func(backend Backend, args []string, types []ast.Type) string {
+			return backend.Call("printf", append([]string{"stringformat"}, args...))
+		},
+		"printnumber": func(backend Backend, args []string, types []ast.Type) string {
+			return backend.Call("printf", append([]string{"numberformat"}, args...))
+		},
+		// printvalue picks the format from the type of its argument, bools print as 1 or 0
+		"printvalue": func(backend Backend, args []string, types []ast.Type) string {
+			format := "numberformat"
+			if types[0] == ast.String {
+				format = "stringformat"
+			}
+			return backend.Call("printf", append([]string{format}, args...))
+		},
+	}
+	// the interpreter does what the bodies above make the compiled program do
+	standardFunctionInterpretations = map[string]func(out io.Writer, args []value, types []ast.Type) value{
+		"printf": func(out io.Writer, args []value, types []ast.Type) value {
+			return printf(out, "%s", args)
+		},
+		"printnumber": func(out io.Writer, args []value, types []ast.Type) value {
+			return printf(out, "%lld", args)
+		},
+		"printvalue": func(out io.Writer, args []value, types []ast.Type) value {
+			if types[0] == ast.String {
+				return printf(out, "%s", args)
+			}
+			return printf(out, "%lld", args)
+		},
+	}
+	// the bytecode makes the same calls as the assembly, to the printf of the virtual machine
+	standardFunctionBytecode = map[string]func(c *bytecodeCompiler, args []ir.Operand, types []ast.Type){
+		"printf": func(c *bytecodeCompiler, args []ir.Operand, types []ast.Type) {
+			c.callExtern("printf", []string{"stringformat"}, args)
+		},
+		"printnumber": func(c *bytecodeCompiler, args []ir.Operand, types []ast.Type) {
+			c.callExtern("printf", []string{"numberformat"}, args)
 		},
-		"assign": &FunctionDefinitionTree{
-			Parameters:        []string{"varName", "value", "valLength"},
-			AssembledBodyName: getAdr("setbytes"),
-			AssembledBodyFile: getAdr(asmFiles.Setbytes),
+		"printvalue": func(c *bytecodeCompiler, args []ir.Operand, types []ast.Type) {
+			format := "numberformat"
+			if types[0] == ast.String {
+				format = "stringformat"
+			}
+			c.callExtern("printf", []string{format}, args)
+		},
+	}
+	// in C each builtin calls a helper from cHelpers with its arguments
+	standardFunctionC = map[string]func(c *cCompiler, args []string, types []ast.Type) string{
+		"printf": func(c *cCompiler, args []string, types []ast.Type) string {
+			return c.helper("gary_printstring") + "(" + args[0] + ")"
+		},
+		"printnumber": func(c *cCompiler, args []string, types []ast.Type) string {
+			return c.helper("gary_printnumber") + "(" + args[0] + ")"
+		},
+		"printvalue": func(c *cCompiler, args []string, types []ast.Type) string {
+			if types[0] == ast.String {
+				return c.helper("gary_printstring") + "(" + args[0] + ")"
+			}
+			return c.helper("gary_printnumber") + "(" + args[0] + ")"
+		},
+	}
+	// in wasm each builtin calls a host function, given by its name in wasmImports, with its arguments
+	standardFunctionWasm = map[string]func(types []ast.Type) string{
+		"printf": func(types []ast.Type) string {
+			return "printstring"
+		},
+		"printnumber": func(types []ast.Type) string {
+			return "printnumber"
+		},
+		"printvalue": func(types []ast.Type) string {
+			if types[0] == ast.String {
+				return "printstring"
+			}
+			return "printnumber"
+		},
+	}
+	// in LLVM each builtin calls printf with one of its constants, putting what it gives back in dest
+	// unless dest is empty
+	standardFunctionLLVM = map[string]func(c *llvmCompiler, dest string, args []string, types []ast.Type){
+		"printf": func(c *llvmCompiler, dest string, args []string, types []ast.Type) {
+			c.printf(dest, c.pointer("stringformat"), "i8* "+c.toPointer(args[0]))
+		},
+		"printnumber": func(c *llvmCompiler, dest string, args []string, types []ast.Type) {
+			c.printf(dest, c.pointer("numberformat"), "i64 "+args[0])
+		},
+		"printvalue": func(c *llvmCompiler, dest string, args []string, types []ast.Type) {
+			if types[0] == ast.String {
+				c.printf(dest, c.pointer("stringformat"), "i8* "+c.toPointer(args[0]))
+				return
+			}
+			c.printf(dest, c.pointer("numberformat"), "i64 "+args[0])
+		},
+	}
+	// on arm64 each builtin calls printf with the format in x0 and what it prints in x1, leaving what
+	// printf gives back in x0
+	standardFunctionARM64 = map[string]func(c *arm64Compiler, args []ir.Operand, types []ast.Type){
+		"printf": func(c *arm64Compiler, args []ir.Operand, types []ast.Type) {
+			c.address("x0", "stringformat")
+			c.load("x1", args[0])
+			c.callPrintf()
+		},
+		"printnumber": func(c *arm64Compiler, args []ir.Operand, types []ast.Type) {
+			c.address("x0", "numberformat")
+			c.load("x1", args[0])
+			c.callPrintf()
+		},
+		"printvalue": func(c *arm64Compiler, args []ir.Operand, types []ast.Type) {
+			if types[0] == ast.String {
+				c.address("x0", "stringformat")
+			} else {
+				c.address("x0", "numberformat")
+			}
+			c.load("x1", args[0])
+			c.callPrintf()
+		},
+	}
+	standardFunctionConstants = map[string]map[string][]byte{
+		"printf": map[string][]byte{
+			"stringformat": append([]byte("%s"), 0),
+		},
+		"printnumber": map[string][]byte{
+			"numberformat": append([]byte("%lld"), 0),
+		},
+		"printvalue": map[string][]byte{
+			"numberformat": append([]byte("%lld"), 0),
+			"stringformat": append([]byte("%s"), 0),
 		},
 	}
 	externDependencies = map[string][]string{
 		"printf": []string{
 			"printf",
 		},
-		"setbytes": []string{
-			"malloc",
-			"free",
+		"printnumber": []string{
+			"printf",
+		},
+		"printvalue": []string{
+			"printf",
 		},
 	}
 }
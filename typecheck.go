@@ -0,0 +1,171 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+// typeChecker infers the type of every parameter, local and procedure result from how they are used
+type typeChecker struct {
+	diagnostics *Diagnostics
+	program     *ast.Program
+	procedure   *ast.ProcDecl
+	// report is set for the last pass, once every type that can be inferred has been
+	report bool
+	// changed is set whenever a pass infers a type it did not know before
+	changed bool
+}
+
+// checkTypes fills in the type of every symbol of a resolved program and reports values of the wrong type.
+// A parameter takes the type of the first argument passed to it, a local the type of the first value
+// assigned to it and a procedure the type of the first value it gives back. Passes are repeated until
+// nothing new is learnt, anything still unknown then, like a procedure without a giveback, is an int
+func checkTypes(program *ast.Program, diagnostics *Diagnostics) {
+	c := typeChecker{diagnostics: diagnostics, program: program}
+	c.infer()
+	for _, procedure := range program.Procedures {
+		for _, symbol := range append([]*ast.Symbol{procedure.Symbol}, procedure.Locals...) {
+			if symbol.Type == ast.Unknown {
+				symbol.Type = ast.Int
+			}
+		}
+	}
+	c.infer()
+	c.report = true
+	for _, procedure := range program.Procedures {
+		c.checkProcedure(procedure)
+	}
+}
+
+func (c *typeChecker) infer() {
+	for c.changed = true; c.changed; {
+		c.changed = false
+		for _, procedure := range c.program.Procedures {
+			c.checkProcedure(procedure)
+		}
+	}
+}
+
+func (c *typeChecker) checkProcedure(procedure *ast.ProcDecl) {
+	c.procedure = procedure
+	c.block(procedure.Body)
+}
+
+func (c *typeChecker) block(block *ast.Block) {
+	if block == nil {
+		return
+	}
+	for _, statement := range block.Statements {
+		c.statement(statement)
+	}
+}
+
+func (c *typeChecker) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.Assign:
+		value := c.expression(statement.Value)
+		if !c.unify(statement.Target.Symbol, value) {
+			c.error(statement.Value.Pos(), "cannot assign %s to %s, which holds %s", withArticle(value), statement.Target.Name, withArticle(statement.Target.Symbol.Type))
+		}
+	case *ast.Return:
+		value := c.expression(statement.Value)
+		if !c.unify(c.procedure.Symbol, value) {
+			c.error(statement.Value.Pos(), "cannot give back %s from %s, which gives back %s", withArticle(value), c.procedure.Name, withArticle(c.procedure.Symbol.Type))
+		} else if c.procedure.Name == "thisisthepie" && value != ast.Unknown && value != ast.Int && value != ast.Bool {
+			// what thisisthepie gives back is the exit status of the program
+			c.error(statement.Value.Pos(), "thisisthepie gives back the exit status, which must be an int or a bool but is given %s", withArticle(value))
+		}
+	case *ast.If:
+		c.condition("perchance", statement.Condition)
+		c.block(statement.Then)
+		c.block(statement.Else)
+	case *ast.While:
+		c.condition("whilst", statement.Condition)
+		c.block(statement.Body)
+	case *ast.Call:
+		c.call(statement)
+	}
+}
+
+func (c *typeChecker) condition(keyword string, condition ast.Expression) {
+	if value := c.expression(condition); value != ast.Unknown && value != ast.Bool {
+		c.error(condition.Pos(), "the condition of %s must be a bool but is %s", keyword, withArticle(value))
+	}
+}
+
+// expression checks the operands of value and gives its type, Unknown when it depends on something not yet inferred
+func (c *typeChecker) expression(value ast.Expression) ast.Type {
+	switch value := value.(type) {
+	case *ast.Call:
+		c.call(value)
+	case *ast.Unary:
+		c.operand(value.Operator, value.Operand, ast.Int)
+	case *ast.Binary:
+		if value.Operator != ast.Equal && value.Operator != ast.NotEqual {
+			c.operand(value.Operator, value.Left, ast.Int)
+			c.operand(value.Operator, value.Right, ast.Int)
+			break
+		}
+		left, right := c.expression(value.Left), c.expression(value.Right)
+		switch {
+		case left == ast.Unknown || right == ast.Unknown:
+		case left != right:
+			c.error(value.Position, "%s cannot compare %s with %s", value.Operator, withArticle(left), withArticle(right))
+		case left == ast.String:
+			c.error(value.Position, "%s cannot compare strings", value.Operator)
+		}
+	}
+	return ast.TypeOf(value)
+}
+
+// operand checks an operand of operator is of the type it needs
+func (c *typeChecker) operand(operator ast.Operator, operand ast.Expression, want ast.Type) {
+	if got := c.expression(operand); got != ast.Unknown && got != want {
+		c.error(operand.Pos(), "%s needs %s but is given %s", operator, withArticle(want), withArticle(got))
+	}
+}
+
+// call checks every argument against the parameter it is passed to, inferring the types of parameters as it goes
+func (c *typeChecker) call(call *ast.Call) {
+	for index, arg := range call.Args {
+		value := c.expression(arg)
+		if call.Symbol.Kind == ast.BuiltinSymbol {
+			builtin := GetStandardFunction(call.Name)
+			if want := builtin.ParameterTypes[index]; value != ast.Unknown && want != ast.Unknown && value != want {
+				c.error(arg.Pos(), "%s needs %s for %s but is given %s", call.Name, withArticle(want), builtin.Parameters[index], withArticle(value))
+			}
+			continue
+		}
+		procedure := c.program.Procedure(call.Name)
+		param := procedure.Locals[index]
+		if !c.unify(param, value) {
+			c.error(arg.Pos(), "%s needs %s for %s but is given %s", call.Name, withArticle(param.Type), param.Name, withArticle(value))
+		}
+	}
+}
+
+// unify gives symbol the type value if it has none yet, and is false when it already has a different one
+func (c *typeChecker) unify(symbol *ast.Symbol, value ast.Type) bool {
+	if value == ast.Unknown {
+		return true
+	}
+	if symbol.Type == ast.Unknown {
+		symbol.Type = value
+		c.changed = true
+		return true
+	}
+	return symbol.Type == value
+}
+
+// error reports a problem only in the last pass, so each is reported once
+func (c *typeChecker) error(position Position, format string, args ...interface{}) {
+	if c.report {
+		c.diagnostics.Error(position, format, args...)
+	}
+}
+
+func withArticle(value ast.Type) string {
+	if value == ast.Int {
+		return "an int"
+	}
+	return "a " + value.String()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ast"
+)
+
+func Test_checkTypes(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			"Int passed to printthething",
+			args{"halfleft thisisthepie £ $ /\n\tprintthething £ 1 $ #\n\\"},
+			[]string{"test.gry:2:18: error: printthething needs a string for printString but is given an int"},
+		},
+		{
+			"String given back from thisisthepie",
+			args{"halfleft thisisthepie £ $ /\n\tgiveback ¬s¬ #\n\\"},
+			[]string{"test.gry:2:11: error: thisisthepie gives back the exit status, which must be an int or a bool but is given a string"},
+		},
+		{
+			"String assigned to an int variable",
+			args{"halfleft thisisthepie £ $ /\n\tx = 1 #\n\tx = ¬one¬ #\n\\"},
+			[]string{"test.gry:3:6: error: cannot assign a string to x, which holds an int"},
+		},
+		{
+			"Arithmetic on a bool",
+			args{"halfleft thisisthepie £ $ /\n\tx = aye + 1 #\n\\"},
+			[]string{"test.gry:2:6: error: + needs an int but is given a bool"},
+		},
+		{
+			"Comparing different types",
+			args{"halfleft thisisthepie £ $ /\n\tx = 1 == aye #\n\\"},
+			[]string{"test.gry:2:8: error: == cannot compare an int with a bool"},
+		},
+		{
+			"Comparing strings",
+			args{"halfleft thisisthepie £ $ /\n\tx = ¬a¬ != ¬b¬ #\n\\"},
+			[]string{"test.gry:2:10: error: != cannot compare strings"},
+		},
+		{
+			"Int condition",
+			args{"halfleft thisisthepie £ $ /\n\twhilst £ 1 $ /\n\t\tscarper #\n\t\\\n\\"},
+			[]string{"test.gry:2:11: error: the condition of whilst must be a bool but is an int"},
+		},
+		{
+			"Parameter typed by its first call",
+			args{"halfleft thisisthepie £ $ /\n\tshout £ ¬hi¬ $ #\n\tshout £ 2 $ #\n\\\nhalfleft shout £ words $ /\n\tprintthething £ words $ #\n\\"},
+			[]string{"test.gry:3:10: error: shout needs a string for words but is given an int"},
+		},
+		{
+			"Givebacks of different types",
+			args{"halfleft thisisthepie £ $ /\n\tprintthevalue £ pick £ aye $ $ #\n\\\nhalfleft pick £ b $ /\n\tperchance £ b $ /\n\t\tgiveback 1 #\n\t\\\n\tgiveback ¬no¬ #\n\\"},
+			[]string{"test.gry:8:11: error: cannot give back a string from pick, which gives back an int"},
+		},
+		{
+			"Types flow through calls declared later",
+			args{"halfleft thisisthepie £ $ /\n\tprintthething £ name £ $ $ #\n\\\nhalfleft name £ $ /\n\tgiveback ¬gary¬ #\n\\"},
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := NewDiagnostics("test.gry", tt.args.source)
+			checkProgram(parseProgram(tokenize(tt.args.source), diagnostics), diagnostics)
+			got := []string{}
+			for _, line := range strings.Split(diagnostics.String(), "\n") {
+				if strings.HasPrefix(line, "test.gry") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("checkTypes() reported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkTypes_inference(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	describe £ ¬gary¬ 3 $ #
+	unused = nothing £ $ #
+\
+halfleft describe £ name age $ /
+	old = age > 40 #
+	giveback old #
+\
+halfleft nothing £ $ /
+\`))
+	want := map[string]ast.Type{
+		"describe": ast.Bool,
+		"name":     ast.String,
+		"age":      ast.Int,
+		"old":      ast.Bool,
+		"nothing":  ast.Int,
+		"unused":   ast.Int,
+	}
+	got := map[string]ast.Type{}
+	for _, procedure := range tree.Procedures {
+		got[procedure.Name] = procedure.Symbol.Type
+		for _, symbol := range procedure.Locals {
+			got[symbol.Name] = symbol.Type
+		}
+	}
+	for name, wantType := range want {
+		if got[name] != wantType {
+			t.Errorf("checkTypes() gave %v the type %v, want %v", name, got[name], wantType)
+		}
+	}
+}
+
+func Test_getAssemblyBodyFromIR_printthevalue(t *testing.T) {
+	tree := treeFromTokens(tokenize(`halfleft thisisthepie £ $ /
+	printthevalue £ ¬hi¬ $ #
+	printthevalue £ 3 $ #
+	printthevalue £ aye $ #
+\`))
+	got := getAssemblyBodyFromIR(windowsBackend(), lowerProgram(tree))
+	for _, want := range []string{
+		"Invoke printf,stringformat,p0\n",
+		"Invoke printf,numberformat,3\n",
+		"Invoke printf,numberformat,1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("getAssemblyBodyFromIR() = %v, missing %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// Op is the first byte of an instruction, its immediates follow it
+type Op byte
+
+const (
+	Unreachable  Op = 0x00
+	Nop          Op = 0x01
+	Block        Op = 0x02
+	Loop         Op = 0x03
+	If           Op = 0x04
+	Else         Op = 0x05
+	End          Op = 0x0B
+	Br           Op = 0x0C
+	BrIf         Op = 0x0D
+	Return       Op = 0x0F
+	Call         Op = 0x10
+	Drop         Op = 0x1A
+	LocalGet     Op = 0x20
+	LocalSet     Op = 0x21
+	LocalTee     Op = 0x22
+	I32Const     Op = 0x41
+	I64Const     Op = 0x42
+	I32Eqz       Op = 0x45
+	I64Eqz       Op = 0x50
+	I64Eq        Op = 0x51
+	I64Ne        Op = 0x52
+	I64LtS       Op = 0x53
+	I64GtS       Op = 0x55
+	I64LeS       Op = 0x57
+	I64GeS       Op = 0x59
+	I64Add       Op = 0x7C
+	I64Sub       Op = 0x7D
+	I64Mul       Op = 0x7E
+	I64DivS      Op = 0x7F
+	I64RemS      Op = 0x81
+	I32WrapI64   Op = 0xA7
+	I64ExtendI32 Op = 0xAD
+)
+
+// immediate is the kind of value following an op
+type immediate int
+
+const (
+	// blockType is 0x40 for a block giving back nothing, or the type of the one value it gives back
+	blockType immediate = iota
+	// index is an unsigned LEB128 number, of a label, local or function
+	index
+	// signed32 and signed64 are signed LEB128 numbers
+	signed32
+	signed64
+)
+
+// NoResult is the block type of a block giving back nothing
+const NoResult = 0x40
+
+type opInfo struct {
+	name       string
+	immediates []immediate
+}
+
+// ops are the instructions this package knows
+var ops = map[Op]opInfo{
+	Unreachable:  {"unreachable", nil},
+	Nop:          {"nop", nil},
+	Block:        {"block", []immediate{blockType}},
+	Loop:         {"loop", []immediate{blockType}},
+	If:           {"if", []immediate{blockType}},
+	Else:         {"else", nil},
+	End:          {"end", nil},
+	Br:           {"br", []immediate{index}},
+	BrIf:         {"br_if", []immediate{index}},
+	Return:       {"return", nil},
+	Call:         {"call", []immediate{index}},
+	Drop:         {"drop", nil},
+	LocalGet:     {"local.get", []immediate{index}},
+	LocalSet:     {"local.set", []immediate{index}},
+	LocalTee:     {"local.tee", []immediate{index}},
+	I32Const:     {"i32.const", []immediate{signed32}},
+	I64Const:     {"i64.const", []immediate{signed64}},
+	I32Eqz:       {"i32.eqz", nil},
+	I64Eqz:       {"i64.eqz", nil},
+	I64Eq:        {"i64.eq", nil},
+	I64Ne:        {"i64.ne", nil},
+	I64LtS:       {"i64.lt_s", nil},
+	I64GtS:       {"i64.gt_s", nil},
+	I64LeS:       {"i64.le_s", nil},
+	I64GeS:       {"i64.ge_s", nil},
+	I64Add:       {"i64.add", nil},
+	I64Sub:       {"i64.sub", nil},
+	I64Mul:       {"i64.mul", nil},
+	I64DivS:      {"i64.div_s", nil},
+	I64RemS:      {"i64.rem_s", nil},
+	I32WrapI64:   {"i32.wrap_i64", nil},
+	I64ExtendI32: {"i64.extend_i32_u", nil},
+}
+
+func (o Op) String() string {
+	if info, known := ops[o]; known {
+		return info.name
+	}
+	return fmt.Sprintf("op 0x%02X", byte(o))
+}
+
+// Emit adds an instruction to the end of the code of the function
+func (f *Function) Emit(op Op, immediates ...int64) {
+	f.Code = append(f.Code, byte(op))
+	for position, kind := range ops[op].immediates {
+		switch kind {
+		case blockType:
+			f.Code = append(f.Code, byte(immediates[position]))
+		case index:
+			f.Code = appendUleb(f.Code, uint64(immediates[position]))
+		default:
+			f.Code = appendSleb(f.Code, immediates[position])
+		}
+	}
+}
+
+// instruction reads the instruction at offset, giving its immediates and the offset of the next one
+func instruction(code []byte, offset int) (op Op, immediates []int64, next int, err error) {
+	op = Op(code[offset])
+	info, known := ops[op]
+	if !known {
+		return op, nil, offset, fmt.Errorf("at %d: %s is not supported", offset, op)
+	}
+	r := &reader{bytes: code, offset: offset + 1}
+	for _, kind := range info.immediates {
+		switch kind {
+		case blockType:
+			immediates = append(immediates, int64(r.byte()))
+		case index:
+			immediates = append(immediates, int64(r.u32()))
+		case signed32:
+			value := r.s64()
+			if value < -1<<31 || value >= 1<<31 {
+				r.fail("%d is too big for an i32", value)
+			}
+			immediates = append(immediates, value)
+		case signed64:
+			immediates = append(immediates, r.s64())
+		}
+	}
+	if r.err != nil {
+		return op, nil, offset, fmt.Errorf("at %d: %s: %v", offset, op, r.err)
+	}
+	return op, immediates, r.offset, nil
+}
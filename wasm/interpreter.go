@@ -0,0 +1,267 @@
+package wasm
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxDepth is how deep calls may go before the interpreter traps, as runtimes do when they run out of stack
+const maxDepth = 1 << 18
+
+// HostFunction is a function the host gives the module to import. It is given the memory of the
+// instance and its arguments, i32 values being held in the low 32 bits
+type HostFunction func(memory []byte, args []uint64) []uint64
+
+// Trap is an instruction that stopped the module, like dividing by zero
+type Trap struct {
+	Message string
+}
+
+func (t *Trap) Error() string {
+	return "trap: " + t.Message
+}
+
+// Instance is a validated module with its imports, ready to run
+type Instance struct {
+	module *Module
+	Memory []byte
+	hosts  []HostFunction
+	// ends are, for each function defined in the module, where each block, loop and if started at
+	// an offset ends and where its else is
+	ends  []map[int]blockEnd
+	depth int
+}
+
+type blockEnd struct {
+	end      int
+	elseFrom int
+}
+
+// Instantiate validates the module, finds what it imports in imports, by module and then name, and
+// lays its data out in its memory
+func Instantiate(module *Module, imports map[string]map[string]HostFunction) (*Instance, error) {
+	if err := module.Validate(); err != nil {
+		return nil, err
+	}
+	i := &Instance{module: module, Memory: make([]byte, module.Pages*PageSize)}
+	for _, imported := range module.Imports {
+		host := imports[imported.Module][imported.Name]
+		if host == nil {
+			return nil, fmt.Errorf("%s.%s is imported but not given", imported.Module, imported.Name)
+		}
+		i.hosts = append(i.hosts, host)
+	}
+	for _, data := range module.Data {
+		copy(i.Memory[data.Offset:], data.Bytes)
+	}
+	for _, function := range module.Functions {
+		i.ends = append(i.ends, blockEnds(function.Code))
+	}
+	return i, nil
+}
+
+// blockEnds matches every block, loop and if in validated code with its end and else
+func blockEnds(code []byte) map[int]blockEnd {
+	ends := map[int]blockEnd{}
+	starts := []int{}
+	for offset := 0; offset < len(code); {
+		op, _, next, _ := instruction(code, offset)
+		switch op {
+		case Block, Loop, If:
+			starts = append(starts, offset)
+			ends[offset] = blockEnd{elseFrom: -1}
+		case Else:
+			start := starts[len(starts)-1]
+			ends[start] = blockEnd{elseFrom: next}
+		case End:
+			if len(starts) > 0 {
+				start := starts[len(starts)-1]
+				starts = starts[:len(starts)-1]
+				ends[start] = blockEnd{end: next, elseFrom: ends[start].elseFrom}
+			}
+		}
+		offset = next
+	}
+	return ends
+}
+
+// Call runs the exported function called name with args and gives back its results
+func (i *Instance) Call(name string, args ...uint64) (results []uint64, err error) {
+	for _, export := range i.module.Exports {
+		if export.Name != name || export.Kind != FunctionExport {
+			continue
+		}
+		if len(args) != len(i.module.functionType(export.Index).Params) {
+			return nil, fmt.Errorf("%s takes %d arguments, not %d", name, len(i.module.functionType(export.Index).Params), len(args))
+		}
+		defer func() {
+			recovered := recover()
+			if trap, isTrap := recovered.(*Trap); isTrap {
+				err = trap
+			} else if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		return i.call(export.Index, args), nil
+	}
+	return nil, fmt.Errorf("there is no function exported as %s", name)
+}
+
+func (i *Instance) call(index int, args []uint64) []uint64 {
+	if index < len(i.hosts) {
+		return i.hosts[index](i.Memory, args)
+	}
+	i.depth++
+	defer func() { i.depth-- }()
+	if i.depth > maxDepth {
+		panic(&Trap{"call stack exhausted"})
+	}
+	function := i.module.Functions[index-len(i.hosts)]
+	return i.run(function, i.ends[index-len(i.hosts)], append(args, make([]uint64, len(function.Locals))...))
+}
+
+// label is a block being run: where a branch to it goes, how many values it carries there and how
+// many values were on the stack when it started
+type label struct {
+	target int
+	arity  int
+	height int
+}
+
+func (i *Instance) run(function Function, ends map[int]blockEnd, locals []uint64) []uint64 {
+	code := function.Code
+	values := []uint64{}
+	results := len(i.module.Types[function.Type].Results)
+	labels := []label{{target: len(code), arity: results}}
+	pop := func() uint64 {
+		value := values[len(values)-1]
+		values = values[:len(values)-1]
+		return value
+	}
+	push := func(value uint64) {
+		values = append(values, value)
+	}
+	// branch leaves depth blocks, keeping the values the label it goes to carries
+	branch := func(depth int64) int {
+		target := labels[len(labels)-1-int(depth)]
+		carried := append([]uint64{}, values[len(values)-target.arity:]...)
+		values = append(values[:target.height], carried...)
+		labels = labels[:len(labels)-1-int(depth)]
+		return target.target
+	}
+	for offset := 0; offset < len(code); {
+		op, immediates, next, _ := instruction(code, offset)
+		switch op {
+		case Unreachable:
+			panic(&Trap{"unreachable"})
+		case Nop:
+		case Block, Loop, If:
+			end := ends[offset]
+			arity := 0
+			if immediates[0] != NoResult {
+				arity = 1
+			}
+			started := label{target: end.end, arity: arity}
+			if op == Loop {
+				// a branch to a loop runs it again from the start
+				started = label{target: offset}
+			}
+			if op == If && pop() == 0 {
+				if end.elseFrom < 0 {
+					next = end.end
+					break
+				}
+				next = end.elseFrom
+			}
+			started.height = len(values)
+			labels = append(labels, started)
+		case Else:
+			// the then part has finished, so the else part is skipped
+			next = branch(0)
+		case End:
+			labels = labels[:len(labels)-1]
+		case Br:
+			next = branch(immediates[0])
+		case BrIf:
+			if pop() != 0 {
+				next = branch(immediates[0])
+			}
+		case Return:
+			return values[len(values)-results:]
+		case Call:
+			called := i.module.functionType(int(immediates[0]))
+			args := append([]uint64{}, values[len(values)-len(called.Params):]...)
+			values = values[:len(values)-len(called.Params)]
+			values = append(values, i.call(int(immediates[0]), args)...)
+		case Drop:
+			pop()
+		case LocalGet:
+			push(locals[immediates[0]])
+		case LocalSet:
+			locals[immediates[0]] = pop()
+		case LocalTee:
+			locals[immediates[0]] = values[len(values)-1]
+		case I32Const:
+			push(uint64(uint32(immediates[0])))
+		case I64Const:
+			push(uint64(immediates[0]))
+		case I32Eqz, I64Eqz:
+			push(boolValue(pop() == 0))
+		case I32WrapI64:
+			push(uint64(uint32(pop())))
+		case I64ExtendI32:
+		default:
+			right := int64(pop())
+			left := int64(pop())
+			push(uint64(arithmetic(op, left, right)))
+		}
+		offset = next
+	}
+	return values[len(values)-results:]
+}
+
+// arithmetic works out the i64 instructions taking two values
+func arithmetic(op Op, left int64, right int64) int64 {
+	switch op {
+	case I64Add:
+		return left + right
+	case I64Sub:
+		return left - right
+	case I64Mul:
+		return left * right
+	case I64DivS, I64RemS:
+		if right == 0 {
+			panic(&Trap{"integer divide by zero"})
+		}
+		if op == I64RemS {
+			if right == -1 {
+				return 0
+			}
+			return left % right
+		}
+		if left == math.MinInt64 && right == -1 {
+			panic(&Trap{"integer overflow"})
+		}
+		return left / right
+	case I64Eq:
+		return int64(boolValue(left == right))
+	case I64Ne:
+		return int64(boolValue(left != right))
+	case I64LtS:
+		return int64(boolValue(left < right))
+	case I64GtS:
+		return int64(boolValue(left > right))
+	case I64LeS:
+		return int64(boolValue(left <= right))
+	case I64GeS:
+		return int64(boolValue(left >= right))
+	}
+	panic(fmt.Sprintf("%s cannot be run", op))
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
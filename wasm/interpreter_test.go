@@ -0,0 +1,190 @@
+package wasm
+
+import (
+	"math"
+	"testing"
+)
+
+// module is a module exporting as f a function taking two i64 values, with the code emit writes
+func module(emit func(f *Function)) *Module {
+	f := Function{Type: 0, Locals: []ValueType{I64}}
+	emit(&f)
+	return &Module{
+		Types:     []FuncType{{Params: []ValueType{I64, I64}, Results: []ValueType{I64}}},
+		Functions: []Function{f},
+		Exports:   []Export{{Name: "f", Kind: FunctionExport, Index: 0}},
+	}
+}
+
+// sum adds the numbers from the first argument down to 1 in a loop, leaving it early when the total
+// passes the second and skipping the even numbers
+var sum = module(func(f *Function) {
+	f.Emit(Block, NoResult)
+	f.Emit(Loop, NoResult)
+	f.Emit(LocalGet, 0)
+	f.Emit(I64Eqz)
+	f.Emit(BrIf, 1)
+	f.Emit(LocalGet, 0)
+	f.Emit(LocalGet, 0)
+	f.Emit(I64Const, 1)
+	f.Emit(I64Sub)
+	f.Emit(LocalSet, 0)
+	f.Emit(I64Const, 2)
+	f.Emit(I64RemS)
+	f.Emit(I64Eqz)
+	f.Emit(If, NoResult)
+	f.Emit(Br, 1)
+	f.Emit(End)
+	f.Emit(LocalGet, 2)
+	f.Emit(LocalGet, 0)
+	f.Emit(I64Const, 1)
+	f.Emit(I64Add)
+	f.Emit(I64Add)
+	f.Emit(LocalSet, 2)
+	f.Emit(LocalGet, 2)
+	f.Emit(LocalGet, 1)
+	f.Emit(I64GtS)
+	f.Emit(BrIf, 1)
+	f.Emit(Br, 0)
+	f.Emit(End)
+	f.Emit(End)
+	f.Emit(LocalGet, 2)
+	f.Emit(End)
+})
+
+// operation gives back what op makes of the two arguments, widening comparisons to an i64
+func operation(op Op) *Module {
+	return module(func(f *Function) {
+		f.Emit(LocalGet, 0)
+		f.Emit(LocalGet, 1)
+		f.Emit(op)
+		if numericTypes[op][2] == I32 {
+			f.Emit(I64ExtendI32)
+		}
+		f.Emit(End)
+	})
+}
+
+// choose gives back the first argument if it is not 0, and the second if it is
+var choose = module(func(f *Function) {
+	f.Emit(LocalGet, 0)
+	f.Emit(I32WrapI64)
+	f.Emit(If, int64(I64))
+	f.Emit(LocalGet, 0)
+	f.Emit(Else)
+	f.Emit(LocalGet, 1)
+	f.Emit(End)
+	f.Emit(End)
+})
+
+func Test_Instance_Call(t *testing.T) {
+	type args struct {
+		module *Module
+		a      int64
+		b      int64
+	}
+	tests := []struct {
+		name string
+		args args
+		want int64
+	}{
+		{"Loop to the end", args{sum, 5, 100}, 9},
+		{"Leaving a loop early", args{sum, 9, 10}, 16},
+		{"Adding wraps around", args{operation(I64Add), math.MaxInt64, 1}, math.MinInt64},
+		{"Dividing truncates", args{operation(I64DivS), -7, 2}, -3},
+		{"Remainder takes the sign of the left", args{operation(I64RemS), -7, 2}, -1},
+		{"Remainder of the smallest number by -1", args{operation(I64RemS), math.MinInt64, -1}, 0},
+		{"Less than", args{operation(I64LtS), -1, 1}, 1},
+		{"Greater or equal", args{operation(I64GeS), -1, 1}, 0},
+		{"If with a value taking then", args{choose, 3, 4}, 3},
+		{"If with a value taking else", args{choose, 0, 4}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, err := Instantiate(tt.args.module, nil)
+			if err != nil {
+				t.Fatalf("Instantiate() error = %v", err)
+			}
+			got, err := instance.Call("f", uint64(tt.args.a), uint64(tt.args.b))
+			if err != nil {
+				t.Fatalf("Call() error = %v", err)
+			}
+			if int64(got[0]) != tt.want {
+				t.Errorf("Call() = %d, want %d", int64(got[0]), tt.want)
+			}
+		})
+	}
+}
+
+func Test_Instance_Call_hosts(t *testing.T) {
+	printed := ""
+	instance, err := Instantiate(double(), map[string]map[string]HostFunction{
+		"host": {
+			"print": func(memory []byte, args []uint64) []uint64 {
+				printed = string(memory[args[0] : args[0]+2])
+				return []uint64{7}
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Instantiate() error = %v", err)
+	}
+	got, err := instance.Call("greet")
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if printed != "hi" || got[0] != 7 {
+		t.Errorf("Call() printed %q and gave back %d, want %q and %d", printed, got[0], "hi", 7)
+	}
+	if got, _ := instance.Call("twice", 21); got[0] != 42 {
+		t.Errorf("Call() = %d, want %d", got[0], 42)
+	}
+}
+
+func Test_Instance_Call_errors(t *testing.T) {
+	recursing := module(func(f *Function) {
+		f.Emit(LocalGet, 0)
+		f.Emit(LocalGet, 1)
+		f.Emit(Call, 0)
+		f.Emit(End)
+	})
+	type args struct {
+		module *Module
+		name   string
+		args   []uint64
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Dividing by zero", args{operation(I64DivS), "f", []uint64{1, 0}}, "trap: integer divide by zero"},
+		{"Dividing the smallest number by -1", args{operation(I64DivS), "f", []uint64{1 << 63, math.MaxUint64}}, "trap: integer overflow"},
+		{"Recursing forever", args{recursing, "f", []uint64{0, 0}}, "trap: call stack exhausted"},
+		{"Unreachable", args{module(func(f *Function) {
+			f.Emit(Unreachable)
+			f.Emit(End)
+		}), "f", []uint64{0, 0}}, "trap: unreachable"},
+		{"Not exported", args{sum, "g", nil}, "there is no function exported as g"},
+		{"Wrong number of arguments", args{sum, "f", []uint64{0}}, "f takes 2 arguments, not 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, err := Instantiate(tt.args.module, nil)
+			if err != nil {
+				t.Fatalf("Instantiate() error = %v", err)
+			}
+			_, err = instance.Call(tt.args.name, tt.args.args...)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Call() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Instantiate_errors(t *testing.T) {
+	_, err := Instantiate(double(), map[string]map[string]HostFunction{"host": {}})
+	if want := "host.print is imported but not given"; err == nil || err.Error() != want {
+		t.Errorf("Instantiate() error = %v, want %v", err, want)
+	}
+}
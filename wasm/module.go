@@ -0,0 +1,463 @@
+// Package wasm writes and reads WebAssembly modules in the binary format, checks them the way a
+// runtime would before running them and runs them with a small interpreter. It knows the parts of
+// the format the code generator uses: functions working on i32 and i64 values, imported functions,
+// one memory and the data put in it, and little else.
+package wasm
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ValueType is the type of a value on the stack, in a local or passed to and from a function
+type ValueType byte
+
+const (
+	I32 ValueType = 0x7F
+	I64 ValueType = 0x7E
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	}
+	return fmt.Sprintf("type 0x%02X", byte(t))
+}
+
+// PageSize is how much memory a page is, memory is sized in pages
+const PageSize = 65536
+
+// FuncType is what a function takes and gives back
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// Import is a function the host provides, Type is the index of its type
+type Import struct {
+	Module string
+	Name   string
+	Type   int
+}
+
+// Function is a function defined in the module. Locals are the ones after its parameters and Code is
+// its instructions, ending with End
+type Function struct {
+	Type   int
+	Locals []ValueType
+	Code   []byte
+}
+
+// ExportKind is what an export is
+type ExportKind byte
+
+const (
+	FunctionExport ExportKind = 0
+	MemoryExport   ExportKind = 2
+)
+
+// Export makes a function or the memory available to the host under Name
+type Export struct {
+	Name  string
+	Kind  ExportKind
+	Index int
+}
+
+// Data is copied into memory at Offset when the module starts
+type Data struct {
+	Offset int
+	Bytes  []byte
+}
+
+// Module is a whole program. Functions are numbered with the imports first, then the functions
+// defined in the module
+type Module struct {
+	Types     []FuncType
+	Imports   []Import
+	Functions []Function
+	// Pages is how much memory the module starts with, it has none when it is 0
+	Pages   int
+	Exports []Export
+	Data    []Data
+}
+
+// magic and version start every module
+const (
+	magic   = "\x00asm"
+	version = 1
+)
+
+// the ids of the sections, which must come in this order
+const (
+	customSection   = 0
+	typeSection     = 1
+	importSection   = 2
+	functionSection = 3
+	memorySection   = 5
+	exportSection   = 7
+	codeSection     = 10
+	dataSection     = 11
+)
+
+// functionTypeForm starts every function type
+const functionTypeForm = 0x60
+
+// Encode lays the module out in the binary format, leaving out the sections it has nothing for
+func (m *Module) Encode() []byte {
+	content := append([]byte(magic), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(content[4:], version)
+	section := func(id byte, count int, entry func(index int, e *encoder)) {
+		if count == 0 {
+			return
+		}
+		e := &encoder{}
+		e.u32(count)
+		for index := 0; index < count; index++ {
+			entry(index, e)
+		}
+		content = append(content, id)
+		content = appendUleb(content, uint64(len(e.bytes)))
+		content = append(content, e.bytes...)
+	}
+	section(typeSection, len(m.Types), func(index int, e *encoder) {
+		e.bytes = append(e.bytes, functionTypeForm)
+		e.types(m.Types[index].Params)
+		e.types(m.Types[index].Results)
+	})
+	section(importSection, len(m.Imports), func(index int, e *encoder) {
+		e.name(m.Imports[index].Module)
+		e.name(m.Imports[index].Name)
+		e.bytes = append(e.bytes, byte(FunctionExport))
+		e.u32(m.Imports[index].Type)
+	})
+	section(functionSection, len(m.Functions), func(index int, e *encoder) {
+		e.u32(m.Functions[index].Type)
+	})
+	if m.Pages > 0 {
+		section(memorySection, 1, func(index int, e *encoder) {
+			// a minimum and no maximum
+			e.bytes = append(e.bytes, 0)
+			e.u32(m.Pages)
+		})
+	}
+	section(exportSection, len(m.Exports), func(index int, e *encoder) {
+		e.name(m.Exports[index].Name)
+		e.bytes = append(e.bytes, byte(m.Exports[index].Kind))
+		e.u32(m.Exports[index].Index)
+	})
+	section(codeSection, len(m.Functions), func(index int, e *encoder) {
+		function := m.Functions[index]
+		body := &encoder{}
+		// locals are written as runs of the same type
+		runs := []int{}
+		for local := range function.Locals {
+			if local == 0 || function.Locals[local] != function.Locals[local-1] {
+				runs = append(runs, local)
+			}
+		}
+		body.u32(len(runs))
+		for run, start := range runs {
+			end := len(function.Locals)
+			if run+1 < len(runs) {
+				end = runs[run+1]
+			}
+			body.u32(end - start)
+			body.bytes = append(body.bytes, byte(function.Locals[start]))
+		}
+		body.bytes = append(body.bytes, function.Code...)
+		e.u32(len(body.bytes))
+		e.bytes = append(e.bytes, body.bytes...)
+	})
+	section(dataSection, len(m.Data), func(index int, e *encoder) {
+		// active data for memory 0, at an offset given by an i32.const
+		e.u32(0)
+		e.bytes = append(e.bytes, byte(I32Const))
+		e.bytes = appendSleb(e.bytes, int64(m.Data[index].Offset))
+		e.bytes = append(e.bytes, byte(End))
+		e.u32(len(m.Data[index].Bytes))
+		e.bytes = append(e.bytes, m.Data[index].Bytes...)
+	})
+	return content
+}
+
+// Decode reads a module in the binary format. It only checks the module is laid out properly, Validate
+// checks what it says makes sense
+func Decode(bytes []byte) (*Module, error) {
+	if len(bytes) < 8 || string(bytes[:4]) != magic {
+		return nil, fmt.Errorf("not a wasm module")
+	}
+	if fileVersion := binary.LittleEndian.Uint32(bytes[4:]); fileVersion != version {
+		return nil, fmt.Errorf("wasm version %d cannot be read, only version %d", fileVersion, version)
+	}
+	m := &Module{}
+	r := &reader{bytes: bytes, offset: 8}
+	functionTypes := []int{}
+	last := 0
+	for r.err == nil && r.offset < len(r.bytes) {
+		id := int(r.byte())
+		size := r.u32()
+		if r.err != nil {
+			break
+		}
+		if size > len(r.bytes)-r.offset {
+			return nil, fmt.Errorf("section %d is cut short", id)
+		}
+		s := &reader{bytes: r.bytes[:r.offset+size], offset: r.offset}
+		r.offset += size
+		if id == customSection {
+			continue
+		}
+		if id <= last {
+			return nil, fmt.Errorf("section %d is out of order", id)
+		}
+		last = id
+		switch id {
+		case typeSection:
+			for count := s.count(); count > 0; count-- {
+				if form := s.byte(); form != functionTypeForm && s.err == nil {
+					s.fail("0x%02X is not a function type", form)
+				}
+				m.Types = append(m.Types, FuncType{Params: s.types(), Results: s.types()})
+			}
+		case importSection:
+			for count := s.count(); count > 0; count-- {
+				imported := Import{Module: s.name(), Name: s.name()}
+				if kind := s.byte(); kind != byte(FunctionExport) && s.err == nil {
+					s.fail("only functions can be imported")
+				}
+				imported.Type = s.u32()
+				m.Imports = append(m.Imports, imported)
+			}
+		case functionSection:
+			for count := s.count(); count > 0; count-- {
+				functionTypes = append(functionTypes, s.u32())
+			}
+		case memorySection:
+			if count := s.count(); count != 1 && s.err == nil {
+				s.fail("a module has one memory, not %d", count)
+			}
+			if flags := s.byte(); flags != 0 && s.err == nil {
+				s.fail("memory with a maximum is not supported")
+			}
+			m.Pages = s.u32()
+			if m.Pages == 0 && s.err == nil {
+				s.fail("memory with no pages is not supported")
+			}
+		case exportSection:
+			for count := s.count(); count > 0; count-- {
+				m.Exports = append(m.Exports, Export{Name: s.name(), Kind: ExportKind(s.byte()), Index: s.u32()})
+			}
+		case codeSection:
+			if count := s.count(); count != len(functionTypes) && s.err == nil {
+				s.fail("there are %d bodies for %d functions", count, len(functionTypes))
+			}
+			for _, functionType := range functionTypes {
+				m.Functions = append(m.Functions, s.function(functionType))
+			}
+		case dataSection:
+			for count := s.count(); count > 0; count-- {
+				if memory := s.u32(); memory != 0 && s.err == nil {
+					s.fail("only active data for memory 0 is supported")
+				}
+				if op := s.byte(); op != byte(I32Const) && s.err == nil {
+					s.fail("the offset of data must be an i32.const")
+				}
+				offset := s.s64()
+				if op := s.byte(); op != byte(End) && s.err == nil {
+					s.fail("the offset of data must be an i32.const")
+				}
+				m.Data = append(m.Data, Data{Offset: int(offset), Bytes: s.take(s.count())})
+			}
+		default:
+			return nil, fmt.Errorf("section %d is not supported", id)
+		}
+		if s.err == nil && s.offset != len(s.bytes) {
+			s.fail("there are bytes left over")
+		}
+		if s.err != nil {
+			return nil, fmt.Errorf("section %d: %v", id, s.err)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(functionTypes) > 0 && len(m.Functions) == 0 {
+		return nil, fmt.Errorf("there are %d functions but no code for them", len(functionTypes))
+	}
+	return m, nil
+}
+
+// maxLocals is the most locals a function read from a module may have, so a broken count cannot
+// make room for billions
+const maxLocals = 50000
+
+func (r *reader) function(functionType int) Function {
+	size := r.u32()
+	if r.err != nil || size > len(r.bytes)-r.offset {
+		r.fail("a function body is cut short")
+		return Function{}
+	}
+	body := &reader{bytes: r.bytes[:r.offset+size], offset: r.offset}
+	r.offset += size
+	function := Function{Type: functionType}
+	for runs := body.count(); runs > 0; runs-- {
+		count := body.u32()
+		valueType := ValueType(body.byte())
+		if len(function.Locals)+count > maxLocals {
+			body.fail("a function has more than %d locals", maxLocals)
+			break
+		}
+		for ; count > 0; count-- {
+			function.Locals = append(function.Locals, valueType)
+		}
+	}
+	function.Code = body.take(len(body.bytes) - body.offset)
+	if body.err != nil {
+		r.err = body.err
+	}
+	return function
+}
+
+type encoder struct {
+	bytes []byte
+}
+
+func (e *encoder) u32(value int) {
+	e.bytes = appendUleb(e.bytes, uint64(value))
+}
+
+func (e *encoder) name(value string) {
+	e.u32(len(value))
+	e.bytes = append(e.bytes, value...)
+}
+
+func (e *encoder) types(types []ValueType) {
+	e.u32(len(types))
+	for _, valueType := range types {
+		e.bytes = append(e.bytes, byte(valueType))
+	}
+}
+
+// reader reads what encoder wrote, after the first thing it cannot read it gives back zeros and
+// keeps the error
+type reader struct {
+	bytes  []byte
+	offset int
+	err    error
+}
+
+func (r *reader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || r.offset >= len(r.bytes) {
+		r.fail("the module is cut short")
+		return 0
+	}
+	r.offset++
+	return r.bytes[r.offset-1]
+}
+
+func (r *reader) u32() int {
+	if r.err != nil {
+		return 0
+	}
+	value, size := binary.Uvarint(r.bytes[r.offset:])
+	if size <= 0 || size > 5 || value > 0xFFFFFFFF {
+		r.fail("the module is cut short or has a number too big for 32 bits")
+		return 0
+	}
+	r.offset += size
+	return int(value)
+}
+
+func (r *reader) s64() int64 {
+	if r.err != nil {
+		return 0
+	}
+	value, size := readSleb(r.bytes[r.offset:])
+	if size <= 0 {
+		r.fail("the module is cut short or has a number too big for 64 bits")
+		return 0
+	}
+	r.offset += size
+	return value
+}
+
+// count is how many of something follow, each one takes at least a byte so a count bigger than what
+// is left is cut short rather than a reason to make room for it
+func (r *reader) count() int {
+	count := r.u32()
+	if count > len(r.bytes)-r.offset {
+		r.fail("the module is cut short")
+		return 0
+	}
+	return count
+}
+
+func (r *reader) take(length int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	taken := append([]byte{}, r.bytes[r.offset:r.offset+length]...)
+	r.offset += length
+	return taken
+}
+
+func (r *reader) name() string {
+	return string(r.take(r.count()))
+}
+
+func (r *reader) types() []ValueType {
+	types := []ValueType{}
+	for _, valueType := range r.take(r.count()) {
+		types = append(types, ValueType(valueType))
+	}
+	return types
+}
+
+func appendUleb(bytes []byte, value uint64) []byte {
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if value == 0 {
+			return append(bytes, b)
+		}
+		bytes = append(bytes, b|0x80)
+	}
+}
+
+func appendSleb(bytes []byte, value int64) []byte {
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if value == 0 && b&0x40 == 0 || value == -1 && b&0x40 != 0 {
+			return append(bytes, b)
+		}
+		bytes = append(bytes, b|0x80)
+	}
+}
+
+// readSleb reads a signed LEB128 number of at most 64 bits, size is 0 if it is cut short or too long
+func readSleb(bytes []byte) (value int64, size int) {
+	shift := uint(0)
+	for size < len(bytes) && size < 10 {
+		b := bytes[size]
+		size++
+		value |= int64(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				value |= -1 << shift
+			}
+			return value, size
+		}
+	}
+	return 0, 0
+}
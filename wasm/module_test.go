@@ -0,0 +1,173 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// double is a module exporting a function giving back twice what it is given, and one that passes
+// the address of its greeting to the function it imports
+func double() *Module {
+	twice := Function{Type: 0, Locals: []ValueType{I64}}
+	twice.Emit(LocalGet, 0)
+	twice.Emit(I64Const, 2)
+	twice.Emit(I64Mul)
+	twice.Emit(LocalSet, 1)
+	twice.Emit(LocalGet, 1)
+	twice.Emit(End)
+	greet := Function{Type: 1}
+	greet.Emit(I64Const, 0)
+	greet.Emit(Call, 0)
+	greet.Emit(End)
+	return &Module{
+		Types: []FuncType{
+			{Params: []ValueType{I64}, Results: []ValueType{I64}},
+			{Params: []ValueType{}, Results: []ValueType{I64}},
+		},
+		Imports:   []Import{{Module: "host", Name: "print", Type: 0}},
+		Functions: []Function{twice, greet},
+		Pages:     1,
+		Exports: []Export{
+			{Name: "twice", Kind: FunctionExport, Index: 1},
+			{Name: "greet", Kind: FunctionExport, Index: 2},
+			{Name: "memory", Kind: MemoryExport, Index: 0},
+		},
+		Data: []Data{{Offset: 0, Bytes: []byte("hi\x00")}},
+	}
+}
+
+func Test_Decode(t *testing.T) {
+	module := double()
+	got, err := Decode(module.Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, module) {
+		t.Errorf("Decode() = %v, want %v", got, module)
+	}
+}
+
+func Test_Module_Encode(t *testing.T) {
+	module := &Module{
+		Types:     []FuncType{{Params: []ValueType{}, Results: []ValueType{I64}}},
+		Functions: []Function{{Type: 0, Locals: []ValueType{I64, I64, I32}, Code: []byte{byte(I64Const), 0x7F, byte(End)}}},
+		Exports:   []Export{{Name: "f", Kind: FunctionExport, Index: 0}},
+	}
+	want := []byte{
+		0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00,
+		// one type, taking nothing and giving back an i64
+		0x01, 0x05, 0x01, 0x60, 0x00, 0x01, 0x7E,
+		0x03, 0x02, 0x01, 0x00,
+		0x07, 0x05, 0x01, 0x01, 'f', 0x00, 0x00,
+		// two i64 locals then an i32 one, and i64.const -1
+		0x0A, 0x0A, 0x01, 0x08, 0x02, 0x02, 0x7E, 0x01, 0x7F, 0x42, 0x7F, 0x0B,
+	}
+	if got := module.Encode(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Encode() = % X, want % X", got, want)
+	}
+}
+
+func Test_Decode_errors(t *testing.T) {
+	encoded := double().Encode()
+	type args struct {
+		bytes []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Not a module", args{[]byte("\x7fELF\x02\x01\x01\x00")}, "not a wasm module"},
+		{"Later version", args{[]byte("\x00asm\x02\x00\x00\x00")}, "wasm version 2 cannot be read, only version 1"},
+		{"Cut short", args{encoded[:len(encoded)-2]}, "section 11 is cut short"},
+		{"Sections out of order", args{[]byte("\x00asm\x01\x00\x00\x00\x03\x01\x00\x01\x01\x00")}, "section 1 is out of order"},
+		{"Unknown section", args{[]byte("\x00asm\x01\x00\x00\x00\x0C\x01\x00")}, "section 12 is not supported"},
+		{"Bytes left in a section", args{[]byte("\x00asm\x01\x00\x00\x00\x01\x02\x00\x00")}, "section 1: there are bytes left over"},
+		{"Not a function type", args{[]byte("\x00asm\x01\x00\x00\x00\x01\x04\x01\x50\x00\x00")}, "section 1: 0x50 is not a function type"},
+		{"Count bigger than the section", args{[]byte("\x00asm\x01\x00\x00\x00\x01\x01\x7F")}, "section 1: the module is cut short"},
+		{"Functions without code", args{[]byte("\x00asm\x01\x00\x00\x00\x03\x02\x01\x00")}, "there are 1 functions but no code for them"},
+		{"Memory with a maximum", args{[]byte("\x00asm\x01\x00\x00\x00\x05\x04\x01\x01\x01\x01")}, "section 5: memory with a maximum is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.args.bytes)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Module_Validate(t *testing.T) {
+	code := func(emit func(f *Function)) func(m *Module) {
+		return func(m *Module) {
+			f := Function{Type: 1}
+			emit(&f)
+			m.Functions[1] = f
+		}
+	}
+	type args struct {
+		change func(m *Module)
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Unknown import type", args{func(m *Module) { m.Imports[0].Type = 2 }}, "import host.print has type 2, which does not exist"},
+		{"Exported twice", args{func(m *Module) { m.Exports[1].Name = "twice" }}, "twice is exported more than once"},
+		{"Unknown export", args{func(m *Module) { m.Exports[0].Index = 3 }}, "export twice is function 3, which does not exist"},
+		{"Exporting memory it does not have", args{func(m *Module) {
+			m.Pages = 0
+			m.Data = nil
+		}}, "export memory is memory 0, which does not exist"},
+		{"Data outside memory", args{func(m *Module) { m.Data[0].Offset = PageSize - 1 }}, "data 0 does not fit in memory"},
+		{"Wrong type", args{code(func(f *Function) {
+			f.Emit(I32Const, 1)
+			f.Emit(End)
+		})}, "function 2: at 2: end: wanted i64 but there is i32"},
+		{"Nothing on the stack", args{code(func(f *Function) {
+			f.Emit(I64Const, 1)
+			f.Emit(I64Add)
+			f.Emit(End)
+		})}, "function 2: at 2: i64.add: there is no value on the stack, wanted i64"},
+		{"Values left over", args{code(func(f *Function) {
+			f.Emit(I64Const, 1)
+			f.Emit(I64Const, 1)
+			f.Emit(End)
+		})}, "function 2: at 4: end: 1 values are left on the stack"},
+		{"Unknown label", args{code(func(f *Function) {
+			f.Emit(Br, 1)
+			f.Emit(End)
+		})}, "function 2: at 0: br: there is no label 1"},
+		{"Unknown local", args{code(func(f *Function) {
+			f.Emit(LocalGet, 0)
+			f.Emit(End)
+		})}, "function 2: at 0: local.get: there is no local 0"},
+		{"Unknown function", args{code(func(f *Function) {
+			f.Emit(Call, 3)
+			f.Emit(End)
+		})}, "function 2: at 0: call: there is no function 3"},
+		{"Missing end", args{code(func(f *Function) { f.Emit(I64Const, 1) })}, "function 2: the code ends before the function does"},
+		{"Code after the end", args{code(func(f *Function) {
+			f.Emit(I64Const, 1)
+			f.Emit(End)
+			f.Emit(Nop)
+		})}, "function 2: at 3: there is code after the end of the function"},
+		{"Else without if", args{code(func(f *Function) {
+			f.Emit(Block, NoResult)
+			f.Emit(Else)
+		})}, "function 2: at 2: else: there is no if for it to be part of"},
+		{"Unknown instruction", args{code(func(f *Function) { f.Code = []byte{0xFC} })}, "function 2: at 0: op 0xFC is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			module := double()
+			tt.args.change(module)
+			err := module.Validate()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// unknownType stands for any type on the stack after an instruction that never carries on, like br
+const unknownType ValueType = 0
+
+// control is a block, loop or if being checked, or the function itself
+type control struct {
+	op Op
+	// results are what the block leaves, and labelTypes what a branch to it must carry
+	results    []ValueType
+	labelTypes []ValueType
+	// height is how many values were on the stack when it started
+	height int
+	// unreachable is set after an instruction that never carries on, until the block ends
+	unreachable bool
+}
+
+// Validate checks everything the module refers to exists and the code of every function uses values
+// of the right types, the checks a runtime makes before it runs a module
+func (m *Module) Validate() error {
+	for index, functionType := range m.Types {
+		for _, valueType := range append(append([]ValueType{}, functionType.Params...), functionType.Results...) {
+			if valueType != I32 && valueType != I64 {
+				return fmt.Errorf("type %d: %s is not supported", index, valueType)
+			}
+		}
+		if len(functionType.Results) > 1 {
+			return fmt.Errorf("type %d: functions giving back more than one value are not supported", index)
+		}
+	}
+	for _, imported := range m.Imports {
+		if imported.Type >= len(m.Types) {
+			return fmt.Errorf("import %s.%s has type %d, which does not exist", imported.Module, imported.Name, imported.Type)
+		}
+	}
+	exported := map[string]bool{}
+	for _, export := range m.Exports {
+		if exported[export.Name] {
+			return fmt.Errorf("%s is exported more than once", export.Name)
+		}
+		exported[export.Name] = true
+		switch {
+		case export.Kind == FunctionExport && export.Index >= len(m.Imports)+len(m.Functions):
+			return fmt.Errorf("export %s is function %d, which does not exist", export.Name, export.Index)
+		case export.Kind == MemoryExport && (export.Index != 0 || m.Pages == 0):
+			return fmt.Errorf("export %s is memory %d, which does not exist", export.Name, export.Index)
+		case export.Kind != FunctionExport && export.Kind != MemoryExport:
+			return fmt.Errorf("export %s is of kind %d, which is not supported", export.Name, export.Kind)
+		}
+	}
+	for index, data := range m.Data {
+		if data.Offset < 0 || data.Offset+len(data.Bytes) > m.Pages*PageSize {
+			return fmt.Errorf("data %d does not fit in memory", index)
+		}
+	}
+	for index, function := range m.Functions {
+		if function.Type >= len(m.Types) {
+			return fmt.Errorf("function %d has type %d, which does not exist", len(m.Imports)+index, function.Type)
+		}
+		if err := m.validateFunction(function); err != nil {
+			return fmt.Errorf("function %d: %v", len(m.Imports)+index, err)
+		}
+	}
+	return nil
+}
+
+// functionType is the type of the function numbered index, counting the imports first
+func (m *Module) functionType(index int) FuncType {
+	if index < len(m.Imports) {
+		return m.Types[m.Imports[index].Type]
+	}
+	return m.Types[m.Functions[index-len(m.Imports)].Type]
+}
+
+// validator checks the code of one function, keeping the types of the values on the stack and the
+// blocks it is in
+type validator struct {
+	module   *Module
+	locals   []ValueType
+	values   []ValueType
+	controls []control
+}
+
+func (m *Module) validateFunction(function Function) error {
+	functionType := m.Types[function.Type]
+	v := &validator{
+		module:   m,
+		locals:   append(append([]ValueType{}, functionType.Params...), function.Locals...),
+		controls: []control{{op: Block, results: functionType.Results, labelTypes: functionType.Results}},
+	}
+	for _, local := range function.Locals {
+		if local != I32 && local != I64 {
+			return fmt.Errorf("a local of %s is not supported", local)
+		}
+	}
+	for offset := 0; offset < len(function.Code); {
+		if len(v.controls) == 0 {
+			return fmt.Errorf("at %d: there is code after the end of the function", offset)
+		}
+		op, immediates, next, err := instruction(function.Code, offset)
+		if err != nil {
+			return err
+		}
+		if err := v.instruction(op, immediates); err != nil {
+			return fmt.Errorf("at %d: %s: %v", offset, op, err)
+		}
+		offset = next
+	}
+	if len(v.controls) > 0 {
+		return fmt.Errorf("the code ends before the function does")
+	}
+	return nil
+}
+
+func (v *validator) push(valueType ValueType) {
+	v.values = append(v.values, valueType)
+}
+
+// pop takes a value of the type wanted off the stack, unknownType wants any type
+func (v *validator) pop(want ValueType) (ValueType, error) {
+	current := v.controls[len(v.controls)-1]
+	if len(v.values) == current.height {
+		if current.unreachable {
+			return want, nil
+		}
+		return 0, fmt.Errorf("there is no value on the stack, wanted %s", typeName(want))
+	}
+	got := v.values[len(v.values)-1]
+	v.values = v.values[:len(v.values)-1]
+	if got != want && got != unknownType && want != unknownType {
+		return 0, fmt.Errorf("wanted %s but there is %s", typeName(want), got)
+	}
+	return got, nil
+}
+
+func (v *validator) popAll(types []ValueType) error {
+	for index := len(types) - 1; index >= 0; index-- {
+		if _, err := v.pop(types[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) pushAll(types []ValueType) {
+	for _, valueType := range types {
+		v.push(valueType)
+	}
+}
+
+// unreachable drops what is on the stack in the current block, the code after never runs
+func (v *validator) unreachable() {
+	current := &v.controls[len(v.controls)-1]
+	v.values = v.values[:current.height]
+	current.unreachable = true
+}
+
+// label is the block a branch leaving depth blocks goes to
+func (v *validator) label(depth int64) (control, error) {
+	if depth >= int64(len(v.controls)) {
+		return control{}, fmt.Errorf("there is no label %d", depth)
+	}
+	return v.controls[len(v.controls)-1-int(depth)], nil
+}
+
+// blockResults are the types a block type says the block gives back
+func blockResults(blockType int64) ([]ValueType, error) {
+	switch ValueType(blockType) {
+	case NoResult:
+		return nil, nil
+	case I32, I64:
+		return []ValueType{ValueType(blockType)}, nil
+	}
+	return nil, fmt.Errorf("block type 0x%02X is not supported", blockType)
+}
+
+func (v *validator) instruction(op Op, immediates []int64) error {
+	switch op {
+	case Unreachable:
+		v.unreachable()
+	case Nop:
+	case Block, Loop, If:
+		if op == If {
+			if _, err := v.pop(I32); err != nil {
+				return err
+			}
+		}
+		results, err := blockResults(immediates[0])
+		if err != nil {
+			return err
+		}
+		labelTypes := results
+		if op == Loop {
+			// a branch to a loop starts it again, with nothing
+			labelTypes = nil
+		}
+		v.controls = append(v.controls, control{op: op, results: results, labelTypes: labelTypes, height: len(v.values)})
+	case Else, End:
+		current := v.controls[len(v.controls)-1]
+		if op == Else && current.op != If {
+			return fmt.Errorf("there is no if for it to be part of")
+		}
+		if err := v.popAll(current.results); err != nil {
+			return err
+		}
+		if len(v.values) != current.height {
+			return fmt.Errorf("%d values are left on the stack", len(v.values)-current.height)
+		}
+		if op == End && current.op == If && len(current.results) > 0 {
+			return fmt.Errorf("an if giving back a value needs an else")
+		}
+		v.controls = v.controls[:len(v.controls)-1]
+		if op == Else {
+			v.controls = append(v.controls, control{op: Else, results: current.results, labelTypes: current.labelTypes, height: current.height})
+		} else {
+			v.pushAll(current.results)
+		}
+	case Br, BrIf:
+		target, err := v.label(immediates[0])
+		if err != nil {
+			return err
+		}
+		if op == BrIf {
+			if _, err := v.pop(I32); err != nil {
+				return err
+			}
+		}
+		if err := v.popAll(target.labelTypes); err != nil {
+			return err
+		}
+		if op == Br {
+			v.unreachable()
+		} else {
+			v.pushAll(target.labelTypes)
+		}
+	case Return:
+		if err := v.popAll(v.controls[0].results); err != nil {
+			return err
+		}
+		v.unreachable()
+	case Call:
+		if immediates[0] >= int64(len(v.module.Imports)+len(v.module.Functions)) {
+			return fmt.Errorf("there is no function %d", immediates[0])
+		}
+		called := v.module.functionType(int(immediates[0]))
+		if err := v.popAll(called.Params); err != nil {
+			return err
+		}
+		v.pushAll(called.Results)
+	case Drop:
+		if _, err := v.pop(unknownType); err != nil {
+			return err
+		}
+	case LocalGet, LocalSet, LocalTee:
+		if immediates[0] >= int64(len(v.locals)) {
+			return fmt.Errorf("there is no local %d", immediates[0])
+		}
+		local := v.locals[immediates[0]]
+		if op != LocalGet {
+			if _, err := v.pop(local); err != nil {
+				return err
+			}
+		}
+		if op != LocalSet {
+			v.push(local)
+		}
+	case I32Const:
+		v.push(I32)
+	case I64Const:
+		v.push(I64)
+	default:
+		return v.numeric(op)
+	}
+	return nil
+}
+
+// numericTypes are what the numeric instructions take, and then what they give back
+var numericTypes = map[Op][]ValueType{
+	I32Eqz:       {I32, I32},
+	I64Eqz:       {I64, I32},
+	I64Eq:        {I64, I64, I32},
+	I64Ne:        {I64, I64, I32},
+	I64LtS:       {I64, I64, I32},
+	I64GtS:       {I64, I64, I32},
+	I64LeS:       {I64, I64, I32},
+	I64GeS:       {I64, I64, I32},
+	I64Add:       {I64, I64, I64},
+	I64Sub:       {I64, I64, I64},
+	I64Mul:       {I64, I64, I64},
+	I64DivS:      {I64, I64, I64},
+	I64RemS:      {I64, I64, I64},
+	I32WrapI64:   {I64, I32},
+	I64ExtendI32: {I32, I64},
+}
+
+func (v *validator) numeric(op Op) error {
+	types := numericTypes[op]
+	if err := v.popAll(types[:len(types)-1]); err != nil {
+		return err
+	}
+	v.push(types[len(types)-1])
+	return nil
+}
+
+func typeName(valueType ValueType) string {
+	if valueType == unknownType {
+		return "a value"
+	}
+	return valueType.String()
+}
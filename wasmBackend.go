@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Jordank321/GaryLang/ast"
+	"github.com/Jordank321/GaryLang/ir"
+	"github.com/Jordank321/GaryLang/wasm"
+)
+
+var wasmOps = map[ir.Op]wasm.Op{
+	ir.Add:      wasm.I64Add,
+	ir.Subtract: wasm.I64Sub,
+	ir.Multiply: wasm.I64Mul,
+	ir.Divide:   wasm.I64DivS,
+	ir.Modulo:   wasm.I64RemS,
+}
+
+var wasmComparisons = map[ir.Op]wasm.Op{
+	ir.Equal:        wasm.I64Eq,
+	ir.NotEqual:     wasm.I64Ne,
+	ir.Less:         wasm.I64LtS,
+	ir.LessEqual:    wasm.I64LeS,
+	ir.Greater:      wasm.I64GtS,
+	ir.GreaterEqual: wasm.I64GeS,
+}
+
+// wasmHostModule is the module the builtins are imported from
+const wasmHostModule = "garylang"
+
+func init() {
+	RegisterProgramBackend("wasm", wasmBackend{})
+}
+
+// wasmBackend writes the optimised IR of the program as a WebAssembly module exporting thisisthepie and its memory. Every
+// value is an i64, strings being the address of their bytes in memory, and the builtins are functions
+// the host gives it, which wasmImports provides for garylang run
+type wasmBackend struct{}
+
+func (wasmBackend) Build(program *ast.Program, lowered *ir.Program, sourcePath string, options BuildOptions) (string, error) {
+	if options.Assembler != "" || options.Linker != "" {
+		return "", fmt.Errorf("wasm only writes a .wasm file, which is not assembled or linked")
+	}
+	outputPath := strings.TrimSuffix(sourcePath, ".gry") + ".wasm"
+	return outputPath, ioutil.WriteFile(outputPath, compileWasm(lowered).Encode(), 0644)
+}
+
+// runWasmFile runs the module in the file at filePath with the interpreter of the wasm package
+func runWasmFile(filePath string, out io.Writer) (int64, error) {
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	module, err := wasm.Decode(fileBytes)
+	if err != nil {
+		return 0, err
+	}
+	instance, err := wasm.Instantiate(module, wasmImports(out))
+	if err != nil {
+		return 0, err
+	}
+	results, err := instance.Call("thisisthepie")
+	if err != nil {
+		return 0, err
+	}
+	return int64(results[0]), nil
+}
+
+// wasmImports are the host functions the builtins call, printing to out like the interpreter does
+func wasmImports(out io.Writer) map[string]map[string]wasm.HostFunction {
+	text := func(memory []byte, address uint64) string {
+		if address >= uint64(len(memory)) {
+			panic(&wasm.Trap{Message: "out of bounds memory access"})
+		}
+		end := address
+		for end < uint64(len(memory)) && memory[end] != 0 {
+			end++
+		}
+		return string(memory[address:end])
+	}
+	return map[string]map[string]wasm.HostFunction{
+		wasmHostModule: {
+			"printnumber": func(memory []byte, args []uint64) []uint64 {
+				return []uint64{uint64(printf(out, "%lld", []value{{number: int64(args[0])}}).number)}
+			},
+			"printstring": func(memory []byte, args []uint64) []uint64 {
+				return []uint64{uint64(printf(out, "%s", []value{{text: text(memory, args[0])}}).number)}
+			},
+		},
+	}
+}
+
+// wasmCompiler turns the procedures of the IR into the functions of a module
+type wasmCompiler struct {
+	module *wasm.Module
+	ir     *ir.Program
+	// functions are the indexes of the imports and procedures, by name
+	functions map[string]int
+	// addresses are where each string of the program is in memory, by its index
+	addresses []int
+	// types are the indexes of the function types, by how many i64 values they take
+	types    map[int]int
+	function *wasm.Function
+	// blocks are the indexes of the blocks of the procedure being compiled, dispatch is the local
+	// holding the one to run next, or -1 when the procedure is a single block
+	blocks   map[*ir.Block]int
+	dispatch int64
+	stackEmitter
+}
+
+// compileWasm compiles every procedure of program into a function. The host functions the builtins
+// use are imported first, so every function index is known before any code is written
+func compileWasm(program *ir.Program) *wasm.Module {
+	c := &wasmCompiler{
+		module:    &wasm.Module{},
+		ir:        program,
+		functions: map[string]int{},
+		types:     map[int]int{},
+	}
+	data := []byte{}
+	for _, value := range program.Strings {
+		c.addresses = append(c.addresses, len(data))
+		data = append(append(data, value...), 0)
+	}
+	for _, procedure := range program.Procedures {
+		for _, block := range procedure.Blocks {
+			for _, instruction := range block.Instructions {
+				if instruction.Op != ir.Call || !instruction.Builtin {
+					continue
+				}
+				name := c.builtinImport(instruction)
+				if _, imported := c.functions[name]; !imported {
+					c.functions[name] = len(c.module.Imports)
+					c.module.Imports = append(c.module.Imports, wasm.Import{Module: wasmHostModule, Name: name, Type: c.functionType(len(instruction.Args))})
+				}
+			}
+		}
+	}
+	for index, procedure := range program.Procedures {
+		c.functions[procedure.Name] = len(c.module.Imports) + index
+	}
+	for _, procedure := range program.Procedures {
+		c.procedure(procedure)
+	}
+
+	c.module.Pages = (len(data) + wasm.PageSize - 1) / wasm.PageSize
+	if c.module.Pages == 0 {
+		c.module.Pages = 1
+	}
+	if len(data) > 0 {
+		c.module.Data = []wasm.Data{{Offset: 0, Bytes: data}}
+	}
+	c.module.Exports = []wasm.Export{
+		{Name: "thisisthepie", Kind: wasm.FunctionExport, Index: c.functions["thisisthepie"]},
+		{Name: "memory", Kind: wasm.MemoryExport, Index: 0},
+	}
+	return c.module
+}
+
+// functionType is the index of the type of a function taking params i64 values and giving back one
+func (c *wasmCompiler) functionType(params int) int {
+	if index, exists := c.types[params]; exists {
+		return index
+	}
+	functionType := wasm.FuncType{Params: []wasm.ValueType{}, Results: []wasm.ValueType{wasm.I64}}
+	for param := 0; param < params; param++ {
+		functionType.Params = append(functionType.Params, wasm.I64)
+	}
+	c.types[params] = len(c.module.Types)
+	c.module.Types = append(c.module.Types, functionType)
+	return c.types[params]
+}
+
+// builtinImport is the name of the host function call is made to
+func (c *wasmCompiler) builtinImport(call *ir.Instruction) string {
+	return GetStandardFunctionWasm(GetStandardFunction(call.Callee).AssembledBodyName)(call.Types)
+}
+
+// procedure compiles procedure into a function whose first locals are its slots. wasm only has
+// structured control flow, so a procedure of more than one block runs in a loop holding an if for
+// each block, which runs when the dispatch local holds its index. A block sets the local to the block
+// it goes to and falls through to the ifs after it, or goes round the loop again to reach one before
+func (c *wasmCompiler) procedure(procedure *ir.Procedure) {
+	c.function = &wasm.Function{Type: c.functionType(procedure.Params)}
+	c.start(procedure, func(local int64) { c.function.Emit(wasm.LocalSet, local) })
+	c.blocks = map[*ir.Block]int{}
+	c.dispatch = -1
+	for index, block := range procedure.Blocks {
+		c.blocks[block] = index
+	}
+	if len(procedure.Blocks) == 1 {
+		c.block(procedure.Blocks[0])
+	} else {
+		c.dispatch = int64(c.firstLocal)
+		c.firstLocal++
+		c.emit(wasm.Loop, wasm.NoResult)
+		for index, block := range procedure.Blocks {
+			c.emit(wasm.LocalGet, c.dispatch)
+			c.emit(wasm.I64Const, int64(index))
+			c.emit(wasm.I64Eq)
+			c.emit(wasm.If, wasm.NoResult)
+			c.block(block)
+			c.emit(wasm.End)
+		}
+		c.emit(wasm.End)
+	}
+	// every block ends in a return or a jump, so the end of the function is never reached
+	c.emit(wasm.Unreachable)
+	c.emit(wasm.End)
+	for local := procedure.Params; local < c.localCount(); local++ {
+		c.function.Locals = append(c.function.Locals, wasm.I64)
+	}
+	c.module.Functions = append(c.module.Functions, *c.function)
+}
+
+// block compiles the instructions of block, nothing is left on the stack after them
+func (c *wasmCompiler) block(block *ir.Block) {
+	for _, instruction := range block.Instructions {
+		c.instruction(block, instruction)
+	}
+	c.flush()
+}
+
+func (c *wasmCompiler) instruction(block *ir.Block, instruction *ir.Instruction) {
+	switch instruction.Op {
+	case ir.Load:
+		c.emit(wasm.LocalGet, int64(instruction.Slot))
+	case ir.Store:
+		c.operand(instruction.Args[0])
+		c.emit(wasm.LocalSet, int64(instruction.Slot))
+		return
+	case ir.Copy:
+		c.operand(instruction.Args[0])
+	case ir.Negate:
+		c.emit(wasm.I64Const, 0)
+		c.operand(instruction.Args[0])
+		c.emit(wasm.I64Sub)
+	case ir.Call:
+		for _, arg := range instruction.Args {
+			c.operand(arg)
+		}
+		name := instruction.Callee
+		if instruction.Builtin {
+			name = c.builtinImport(instruction)
+		}
+		c.emit(wasm.Call, int64(c.functions[name]))
+	case ir.Jump:
+		c.goTo(block, instruction.Targets[0], 1)
+		return
+	case ir.Branch:
+		c.operand(instruction.Args[0])
+		c.emit(wasm.I32WrapI64)
+		c.emit(wasm.If, wasm.NoResult)
+		c.goTo(block, instruction.Targets[0], 2)
+		c.emit(wasm.Else)
+		c.goTo(block, instruction.Targets[1], 2)
+		c.emit(wasm.End)
+		return
+	case ir.Return:
+		c.operand(instruction.Args[0])
+		c.emit(wasm.Return)
+		return
+	default:
+		c.operand(instruction.Args[0])
+		c.operand(instruction.Args[1])
+		if op, isArithmetic := wasmOps[instruction.Op]; isArithmetic {
+			c.emit(op)
+		} else {
+			// comparisons give an i32
+			c.emit(wasmComparisons[instruction.Op])
+			c.emit(wasm.I64ExtendI32)
+		}
+	}
+	if !c.keep(instruction.Dest) {
+		c.emit(wasm.Drop)
+	}
+}
+
+// goTo runs target after from, going round the dispatch loop when target does not come after from.
+// loop is how many labels out the loop is from where the code is
+func (c *wasmCompiler) goTo(from *ir.Block, target *ir.Block, loop int64) {
+	c.emit(wasm.I64Const, int64(c.blocks[target]))
+	c.emit(wasm.LocalSet, c.dispatch)
+	if c.blocks[target] <= c.blocks[from] {
+		c.emit(wasm.Br, loop)
+	}
+}
+
+// emit flushes and adds an instruction to the function
+func (c *wasmCompiler) emit(op wasm.Op, immediates ...int64) {
+	c.flush()
+	c.function.Emit(op, immediates...)
+}
+
+// operand pushes the value of operand as an i64
+func (c *wasmCompiler) operand(operand ir.Operand) {
+	switch operand.Kind {
+	case ir.RegisterOperand:
+		if !c.take(operand.Register) {
+			c.emit(wasm.LocalGet, c.local(operand.Register))
+		}
+	case ir.StringOperand:
+		c.emit(wasm.I64Const, int64(c.addresses[operand.Value]))
+	default:
+		c.emit(wasm.I64Const, operand.Value)
+	}
+}
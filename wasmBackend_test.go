@@ -0,0 +1,143 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Jordank321/GaryLang/ir"
+	"github.com/Jordank321/GaryLang/wasm"
+)
+
+func Test_compileWasm(t *testing.T) {
+	source := `halfleft thisisthepie £ $ /
+	printthevalue £ ¬hi¬ $ #
+	printthevalue £ 2 $ #
+	printthething £ ¬hi¬ $ #
+	giveback add £ 1 2 $ #
+\
+halfleft add £ a b $ /
+	total = a + b #
+	printthething £ ¬sum¬ $ #
+	giveback total #
+\`
+	program := lowerProgram(treeFromTokens(tokenize(source)))
+	ir.Optimise(program, 0)
+	module, err := wasm.Decode(compileWasm(program).Encode())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if err := module.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	takingOne := wasm.FuncType{Params: []wasm.ValueType{wasm.I64}, Results: []wasm.ValueType{wasm.I64}}
+	wantTypes := []wasm.FuncType{
+		takingOne,
+		{Params: []wasm.ValueType{}, Results: []wasm.ValueType{wasm.I64}},
+		{Params: []wasm.ValueType{wasm.I64, wasm.I64}, Results: []wasm.ValueType{wasm.I64}},
+	}
+	if !reflect.DeepEqual(module.Types, wantTypes) {
+		t.Errorf("compileWasm() types = %v, want %v", module.Types, wantTypes)
+	}
+	wantImports := []wasm.Import{
+		{Module: "garylang", Name: "printstring", Type: 0},
+		{Module: "garylang", Name: "printnumber", Type: 0},
+	}
+	if !reflect.DeepEqual(module.Imports, wantImports) {
+		t.Errorf("compileWasm() imports = %v, want %v", module.Imports, wantImports)
+	}
+	// add has a local for total after its two parameters, and one for each of the registers holding
+	// them while both are read to be added
+	if len(module.Functions) != 2 || module.Functions[0].Type != 1 || module.Functions[1].Type != 2 || len(module.Functions[1].Locals) != 3 {
+		t.Errorf("compileWasm() functions = %v, want thisisthepie and then add", module.Functions)
+	}
+	wantExports := []wasm.Export{
+		{Name: "thisisthepie", Kind: wasm.FunctionExport, Index: 2},
+		{Name: "memory", Kind: wasm.MemoryExport, Index: 0},
+	}
+	if !reflect.DeepEqual(module.Exports, wantExports) {
+		t.Errorf("compileWasm() exports = %v, want %v", module.Exports, wantExports)
+	}
+	// each string is in memory once, ending with a zero byte
+	wantData := []wasm.Data{{Offset: 0, Bytes: []byte("hi\x00sum\x00")}}
+	if module.Pages != 1 || !reflect.DeepEqual(module.Data, wantData) {
+		t.Errorf("compileWasm() memory = %d pages holding %v, want 1 holding %v", module.Pages, module.Data, wantData)
+	}
+}
+
+// Test_wasmBackend_Build checks the modules do what the interpreter does at every level, running them
+// from a .wasm file as garylang run does
+func Test_wasmBackend_Build(t *testing.T) {
+	backend := GetProgramBackend("wasm")
+	for _, tt := range interpreterPrograms {
+		for level := 0; level <= 1; level++ {
+			t.Run(tt.name, func(t *testing.T) {
+				program := treeFromTokens(tokenize(tt.source))
+				lowered := lowerProgram(program)
+				ir.Optimise(lowered, level)
+				wasmPath, err := backend.Build(program, lowered, filepath.Join(t.TempDir(), "program.gry"), BuildOptions{})
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				out := &strings.Builder{}
+				result, err := runWasmFile(wasmPath, out)
+				if err != nil {
+					t.Fatalf("runWasmFile() error = %v", err)
+				}
+				if out.String() != tt.want || result != tt.wantResult {
+					t.Errorf("at level %d runWasmFile() printed %q and gave back %d, want %q and %d", level, out.String(), result, tt.want, tt.wantResult)
+				}
+			})
+		}
+	}
+}
+
+func Test_compileWasm_errors(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      string
+		wantError string
+	}{
+		{
+			"Division by zero",
+			args{`halfleft thisisthepie £ $ /
+	printthething £ ¬before¬ $ #
+	zero = 0 #
+	printthenumber £ ( 1 / zero ) $ #
+\`},
+			"before",
+			"trap: integer divide by zero",
+		},
+		{
+			"Recursion that never stops",
+			args{`halfleft thisisthepie £ $ /
+	forever £ 1 $ #
+\
+halfleft forever £ n $ /
+	giveback 1 + forever £ ( n + 1 ) $ #
+\`},
+			"",
+			"trap: call stack exhausted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			program := lowerProgram(treeFromTokens(tokenize(tt.args.source)))
+			ir.Optimise(program, 0)
+			instance, err := wasm.Instantiate(compileWasm(program), wasmImports(out))
+			if err != nil {
+				t.Fatalf("Instantiate() error = %v", err)
+			}
+			_, err = instance.Call("thisisthepie")
+			if err == nil || err.Error() != tt.wantError || out.String() != tt.want {
+				t.Errorf("Call() printed %q with error %v, want %q and %v", out.String(), err, tt.want, tt.wantError)
+			}
+		})
+	}
+}
@@ -1,4 +1,11 @@
 ; -----------------------------------------------------------------------------
 ; Allocate stack memory
+; The frame below the saved rbp holds the variables first, then the saved
+; registers, then 8*4 bytes of shadow space and room for the parameters that
+; are passed on the stack.
+; !!! The frame size is always a multiple of 16, so together with the pushed
+; rbp the stack stays aligned on the 16 byte boundary.
 ; -----------------------------------------------------------------------------
-sub rsp,8*7
+push rbp
+mov rbp,rsp
+sub rsp,$frameSize
@@ -1,9 +1,4 @@
 ; -----------------------------------------------------------------------------
 ; Quit
 ; -----------------------------------------------------------------------------
-mov rax,qword 0
 ret
-
-; ----
-; END ----
-; ----
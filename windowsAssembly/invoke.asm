@@ -4,22 +4,22 @@
 %macro Invoke 1-*
         %if %0 > 1
                 %rotate 1
-                mov rcx,qword %1
+                mov rcx,%1
                 %rotate 1
                 %if %0 > 2
-                        mov rdx,qword %1
+                        mov rdx,%1
                         %rotate 1
                         %if  %0 > 3
-                                mov r8,qword %1
+                                mov r8,%1
                                 %rotate 1
                                 %if  %0 > 4
-                                        mov r9,qword %1
+                                        mov r9,%1
                                         %rotate 1
                                         %if  %0 > 5
                                                 %assign max %0-5
                                                 %assign i 32
                                                 %rep max
-                                                        mov rax,qword %1
+                                                        mov rax,%1
                                                         mov qword [rsp+i],rax
                                                         %assign i i+8
                                                         %rotate 1
@@ -1,8 +0,0 @@
-; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; 4x of them are assigned to registers.
-; 3x of them are assigned to stack spaces.
-; -----------------------------------------------------------------------------
-; Call printf with seven parameters
-; -----------------------------------------------------------------------------
-Invoke printf,$printString
@@ -1,4 +1,5 @@
 ; -----------------------------------------------------------------------------
 ; Release stack memory
 ; -----------------------------------------------------------------------------
-add rsp,8*7
+mov rsp,rbp
+pop rbp
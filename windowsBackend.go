@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/Jordank321/GaryLang/asmFiles"
+)
+
+func init() {
+	RegisterBackend("windows-amd64", &nasmBackend{
+		Start64bit:      asmFiles.Start64bit,
+		Datasection:     asmFiles.Datasection,
+		Alignconstbytes: asmFiles.Alignconstbytes,
+		Codesection:     asmFiles.Codesection,
+		Invoke:          asmFiles.Invoke,
+		C:               asmFiles.C,
+		Allocatestack:   asmFiles.Allocatestack,
+		Releasestack:    asmFiles.Releasestack,
+		Exit:            asmFiles.Exit,
+		End:             asmFiles.End,
+
+		ArgumentRegisters: []string{"rcx", "rdx", "r8", "r9"},
+		ShadowSpace:       8 * 4,
+		// Invoke fills the argument registers one after another and every instruction
+		// works in rax, rcx and rdx, so none of those are handed out
+		PreservedRegisters: []string{"rbx", "rsi", "rdi", "r12", "r13", "r14", "r15"},
+		ScratchRegisters:   []string{"r10", "r11"},
+
+		ObjectFormat:        "win64",
+		ObjectExtension:     ".obj",
+		ExecutableExtension: ".exe",
+		LinkFlags:           []string{"-m64"},
+	})
+}
@@ -0,0 +1,320 @@
+// Package x86 assembles the NASM syntax x86-64 the code generator writes into machine code, so a
+// program can be built without nasm installed, and links it into static executables so it can be
+// built without gcc either. It understands the instructions the generator and the snippets use and
+// little else.
+package x86
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Section is a named run of bytes in the object
+type Section struct {
+	Name  string
+	Bytes []byte
+}
+
+// Symbol is where a label was defined, as an offset into a section
+type Symbol struct {
+	Section *Section
+	Offset  int
+}
+
+// reference is a use of a label that could not be filled in while assembling, because the label is
+// in another section or outside the object altogether
+type reference struct {
+	section *Section
+	// at is where the value goes in the section
+	at    int
+	kind  fixupKind
+	label string
+	call  bool
+	// next is where the instruction holding the reference ends, relative values count from there
+	next int
+}
+
+// Object is the result of assembling a source: its sections, the labels defined in them and the
+// references still waiting for an address
+type Object struct {
+	Sections []*Section
+	Symbols  map[string]Symbol
+	// Labels are the names of the symbols in the order they were defined
+	Labels  []string
+	Globals []string
+	Externs []string
+
+	references []reference
+}
+
+// Error is a line of the source that could not be assembled
+type Error struct {
+	Line    int
+	Text    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Message, strings.TrimSpace(e.Text))
+}
+
+// assembler holds the state of assembling a source line by line
+type assembler struct {
+	object  *Object
+	current *Section
+	// scope is the last label not starting with a dot, which the local labels after it belong to
+	scope string
+	// pending are the jumps and calls to labels that may come later in their own section
+	pending []reference
+}
+
+// Assemble turns source into an object. Jumps and calls always take a 32 bit distance, so every
+// instruction has its final size as soon as it is read and one pass over the source is enough
+func Assemble(source string) (*Object, error) {
+	a := &assembler{object: &Object{Symbols: map[string]Symbol{}}}
+	for index, line := range strings.Split(source, "\n") {
+		if err := a.line(line); err != nil {
+			return nil, &Error{Line: index + 1, Text: line, Message: err.Error()}
+		}
+	}
+	if err := a.resolve(); err != nil {
+		return nil, err
+	}
+	return a.object, nil
+}
+
+func (a *assembler) line(line string) error {
+	line = strings.TrimSpace(stripComment(line))
+	if line == "" {
+		return nil
+	}
+	if colon := strings.Index(line, ":"); colon > 0 && isLabel(line[:colon]) {
+		if err := a.label(line[:colon]); err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line[colon+1:])
+		if line == "" {
+			return nil
+		}
+	}
+
+	mnemonic := line
+	rest := ""
+	if space := strings.IndexAny(line, " \t"); space > 0 {
+		mnemonic = line[:space]
+		rest = strings.TrimSpace(line[space:])
+	}
+	mnemonic = strings.ToLower(mnemonic)
+
+	switch mnemonic {
+	case "bits":
+		if rest != "64" {
+			return fmt.Errorf("only 64 bit code can be assembled")
+		}
+		return nil
+	case "section", "segment":
+		if rest == "" {
+			return fmt.Errorf("section needs a name")
+		}
+		return a.section(strings.Fields(rest)[0])
+	case "global":
+		a.object.Globals = append(a.object.Globals, splitOperands(rest)...)
+		return nil
+	case "extern":
+		a.object.Externs = append(a.object.Externs, splitOperands(rest)...)
+		return nil
+	case "align":
+		return a.align(rest)
+	case "db":
+		return a.data(rest)
+	case "dq":
+		return a.quadwords(rest)
+	}
+
+	if a.current == nil {
+		return fmt.Errorf("instructions must be in a section")
+	}
+	operands := []operand{}
+	for _, text := range splitOperands(rest) {
+		operand, err := parseOperand(text, a.scope)
+		if err != nil {
+			return err
+		}
+		operands = append(operands, operand)
+	}
+	instruction, err := encode(mnemonic, operands)
+	if err != nil {
+		return err
+	}
+	at := len(a.current.Bytes)
+	a.current.Bytes = append(a.current.Bytes, instruction.bytes...)
+	for _, fixup := range instruction.fixups {
+		a.pending = append(a.pending, reference{
+			section: a.current,
+			at:      at + fixup.offset,
+			kind:    fixup.kind,
+			label:   fixup.label,
+			call:    fixup.call,
+			next:    len(a.current.Bytes),
+		})
+	}
+	return nil
+}
+
+func (a *assembler) label(name string) error {
+	if !strings.HasPrefix(name, ".") {
+		a.scope = name
+	}
+	name = qualify(name, a.scope)
+	if a.current == nil {
+		return fmt.Errorf("label %s must be in a section", name)
+	}
+	if _, exists := a.object.Symbols[name]; exists {
+		return fmt.Errorf("label %s is defined twice", name)
+	}
+	a.object.Symbols[name] = Symbol{Section: a.current, Offset: len(a.current.Bytes)}
+	a.object.Labels = append(a.object.Labels, name)
+	return nil
+}
+
+// section switches to the section called name, making it the first time it is named
+func (a *assembler) section(name string) error {
+	if _, known := sectionKinds[name]; !known {
+		return fmt.Errorf("unknown section %s", name)
+	}
+	for _, section := range a.object.Sections {
+		if section.Name == name {
+			a.current = section
+			return nil
+		}
+	}
+	a.current = &Section{Name: name}
+	a.object.Sections = append(a.object.Sections, a.current)
+	return nil
+}
+
+// align pads the current section up to a multiple of the given size, code is padded with nops
+func (a *assembler) align(text string) error {
+	size, err := parseNumber(strings.TrimSpace(text))
+	if err != nil || size <= 0 || size&(size-1) != 0 {
+		return fmt.Errorf("cannot align to %q", text)
+	}
+	if a.current == nil {
+		return fmt.Errorf("align must be in a section")
+	}
+	padding := byte(0)
+	if a.current.Name == ".text" {
+		padding = 0x90
+	}
+	for int64(len(a.current.Bytes))%size != 0 {
+		a.current.Bytes = append(a.current.Bytes, padding)
+	}
+	return nil
+}
+
+// data writes the bytes of a db, each part being a quoted string or a number
+func (a *assembler) data(text string) error {
+	if a.current == nil {
+		return fmt.Errorf("db must be in a section")
+	}
+	for _, part := range splitOperands(text) {
+		if len(part) >= 2 && (part[0] == '"' || part[0] == '\'') && part[len(part)-1] == part[0] {
+			a.current.Bytes = append(a.current.Bytes, part[1:len(part)-1]...)
+			continue
+		}
+		value, err := parseNumber(part)
+		if err != nil || value < -128 || value > 255 {
+			return fmt.Errorf("cannot put %q in a byte", part)
+		}
+		a.current.Bytes = append(a.current.Bytes, byte(value))
+	}
+	return nil
+}
+
+// quadwords writes the numbers of a dq as 8 bytes each
+func (a *assembler) quadwords(text string) error {
+	if a.current == nil {
+		return fmt.Errorf("dq must be in a section")
+	}
+	for _, part := range splitOperands(text) {
+		value, err := parseNumber(part)
+		if err != nil {
+			return fmt.Errorf("cannot put %q in a quadword", part)
+		}
+		a.current.Bytes = append(a.current.Bytes, le64(value)...)
+	}
+	return nil
+}
+
+// resolve fills in the distances to labels in the same section and keeps every other reference for the linker
+func (a *assembler) resolve() error {
+	externs := map[string]bool{}
+	for _, extern := range a.object.Externs {
+		externs[extern] = true
+	}
+	for _, pending := range a.pending {
+		symbol, defined := a.object.Symbols[pending.label]
+		if !defined && !externs[pending.label] {
+			return fmt.Errorf("label %s is never defined", pending.label)
+		}
+		if defined && symbol.Section == pending.section && pending.kind == relative32 {
+			distance := symbol.Offset - pending.next
+			copy(pending.section.Bytes[pending.at:], le32(int32(distance)))
+			continue
+		}
+		a.object.references = append(a.object.references, pending)
+	}
+	for _, global := range a.object.Globals {
+		if _, defined := a.object.Symbols[global]; !defined {
+			return fmt.Errorf("global %s is never defined", global)
+		}
+	}
+	return nil
+}
+
+// stripComment removes everything from a semicolon that is not inside quotes
+func stripComment(line string) string {
+	var quote rune
+	for index, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ';':
+			return line[:index]
+		}
+	}
+	return line
+}
+
+// splitOperands splits text at the commas that are not inside quotes or brackets
+func splitOperands(text string) []string {
+	parts := []string{}
+	if strings.TrimSpace(text) == "" {
+		return parts
+	}
+	var quote rune
+	depth := 0
+	start := 0
+	for index, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:index]))
+			start = index + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(text[start:]))
+}
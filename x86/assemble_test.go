@@ -0,0 +1,119 @@
+package x86
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Assemble(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantText    string
+		wantSymbols map[string]int
+	}{
+		{
+			"Jumps backwards and forwards are filled in",
+			args{`section .text
+main:
+.top:
+jz .end
+jmp .top
+.end:
+ret`},
+			"0f8405000000" + "e9f5ffffff" + "c3",
+			map[string]int{"main": 0, "main.top": 0, "main.end": 11},
+		},
+		{
+			"Local labels belong to the label before them",
+			args{`section .text
+one:
+.giveback:
+ret
+two:
+.giveback:
+jmp .giveback`},
+			"c3" + "e9fbffffff",
+			map[string]int{"one": 0, "one.giveback": 0, "two": 1, "two.giveback": 1},
+		},
+		{
+			"Comments, calls to labels of the object and alignment",
+			args{`bits 64 ; comment
+section .text
+main: call helper ; comment
+align 8
+helper:
+ret`},
+			"e803000000" + "909090" + "c3",
+			map[string]int{"main": 0, "helper": 8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, err := Assemble(tt.args.source)
+			if err != nil {
+				t.Fatalf("Assemble() error = %v", err)
+			}
+			if got := hex.EncodeToString(object.Sections[0].Bytes); got != tt.wantText {
+				t.Errorf("Assemble() = %v, want %v", got, tt.wantText)
+			}
+			symbols := map[string]int{}
+			for name, symbol := range object.Symbols {
+				symbols[name] = symbol.Offset
+			}
+			if !reflect.DeepEqual(symbols, tt.wantSymbols) {
+				t.Errorf("Assemble() symbols = %v, want %v", symbols, tt.wantSymbols)
+			}
+		})
+	}
+}
+
+func Test_Assemble_data(t *testing.T) {
+	object, err := Assemble(`section .data
+p0: db "a;b",10,0 ; the semicolon in quotes stays
+p1: db 'it',255
+align 16`)
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+	want := "613b620a00" + "6974ff"
+	want += strings.Repeat("00", 16-len(want)/2)
+	if got := hex.EncodeToString(object.Sections[0].Bytes); got != want {
+		t.Errorf("Assemble() = %v, want %v", got, want)
+	}
+	if object.Symbols["p1"].Offset != 5 {
+		t.Errorf("Assemble() p1 at %v, want 5", object.Symbols["p1"].Offset)
+	}
+}
+
+func Test_Assemble_errors(t *testing.T) {
+	type args struct {
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Unknown instruction", args{"section .text\nfrobnicate rax"}, "line 2: unknown instruction frobnicate: frobnicate rax"},
+		{"Operands that do not go together", args{"section .text\nmov eax,rcx"}, "line 2: mov cannot take these operands: mov eax,rcx"},
+		{"Label never defined", args{"section .text\njmp nowhere"}, "label nowhere is never defined"},
+		{"Label defined twice", args{"section .text\na:\na:"}, "line 3: label a is defined twice: a:"},
+		{"Instruction outside a section", args{"ret"}, "line 1: instructions must be in a section: ret"},
+		{"Global never defined", args{"global main\nsection .text\nret"}, "global main is never defined"},
+		{"Unknown section", args{"section .bss"}, "line 1: unknown section .bss: section .bss"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble(tt.args.source)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Assemble() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
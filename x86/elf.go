@@ -0,0 +1,260 @@
+package x86
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// sectionKind is how a section is described in the section header table
+type sectionKind struct {
+	flags     uint64
+	alignment uint64
+}
+
+const (
+	shfWrite uint64 = 0x1
+	shfAlloc uint64 = 0x2
+	shfExec  uint64 = 0x4
+)
+
+// sectionKinds are the sections an object may have
+var sectionKinds = map[string]sectionKind{
+	".text":           {flags: shfAlloc | shfExec, alignment: 16},
+	".data":           {flags: shfAlloc | shfWrite, alignment: 16},
+	".note.GNU-stack": {alignment: 1},
+}
+
+const (
+	shtProgbits uint32 = 1
+	shtSymtab   uint32 = 2
+	shtStrtab   uint32 = 3
+	shtRela     uint32 = 4
+
+	stbLocal     byte = 0
+	stbGlobal    byte = 1
+	sttNotype    byte = 0
+	sttSection   byte = 3
+	shnUndefined      = 0
+
+	rX86_64_64    uint32 = 1
+	rX86_64_PC32  uint32 = 2
+	rX86_64_PLT32 uint32 = 4
+)
+
+// sectionHeader is an entry of the section header table
+type sectionHeader struct {
+	Name      uint32
+	Type      uint32
+	Flags     uint64
+	Address   uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Alignment uint64
+	EntrySize uint64
+}
+
+// symbolEntry is an entry of the symbol table
+type symbolEntry struct {
+	Name    uint32
+	Info    byte
+	Other   byte
+	Section uint16
+	Value   uint64
+	Size    uint64
+}
+
+// relocationEntry is an entry of a relocation table with an addend
+type relocationEntry struct {
+	Offset uint64
+	Info   uint64
+	Addend int64
+}
+
+// stringTable collects the names of a string table, the first being the empty name
+type stringTable struct {
+	bytes []byte
+}
+
+func (t *stringTable) add(name string) uint32 {
+	if len(t.bytes) == 0 {
+		t.bytes = []byte{0}
+	}
+	if name == "" {
+		return 0
+	}
+	offset := len(t.bytes)
+	t.bytes = append(append(t.bytes, name...), 0)
+	return uint32(offset)
+}
+
+// Relocatable writes the object as an ELF64 relocatable object for x86-64, which any linker can
+// put together with the C runtime. References to labels of the object are made relative to the
+// symbol of the section they are in, references to externs go through the symbol of the extern
+func (o *Object) Relocatable() []byte {
+	names := &stringTable{}
+	symbolNames := &stringTable{}
+	names.add("")
+	symbolNames.add("")
+	sectionNumbers := map[*Section]uint16{}
+	for index, section := range o.Sections {
+		sectionNumbers[section] = uint16(index + 1)
+	}
+
+	symbols := []symbolEntry{{}}
+	symbolNumbers := map[string]uint32{}
+	sectionSymbols := map[*Section]uint32{}
+	for _, section := range o.Sections {
+		sectionSymbols[section] = uint32(len(symbols))
+		symbols = append(symbols, symbolEntry{Info: stbLocal<<4 | sttSection, Section: sectionNumbers[section]})
+	}
+	globals := map[string]bool{}
+	for _, global := range o.Globals {
+		globals[global] = true
+	}
+	// local labels of procedures are left out, the names of procedures and constants stay for debuggers
+	for _, label := range o.Labels {
+		if globals[label] || strings.Contains(label, ".") {
+			continue
+		}
+		symbol := o.Symbols[label]
+		symbols = append(symbols, symbolEntry{Name: symbolNames.add(label), Info: stbLocal<<4 | sttNotype, Section: sectionNumbers[symbol.Section], Value: uint64(symbol.Offset)})
+	}
+	firstGlobal := len(symbols)
+	for _, global := range o.Globals {
+		symbol := o.Symbols[global]
+		symbolNumbers[global] = uint32(len(symbols))
+		symbols = append(symbols, symbolEntry{Name: symbolNames.add(global), Info: stbGlobal<<4 | sttNotype, Section: sectionNumbers[symbol.Section], Value: uint64(symbol.Offset)})
+	}
+	for _, extern := range o.Externs {
+		symbolNumbers[extern] = uint32(len(symbols))
+		symbols = append(symbols, symbolEntry{Name: symbolNames.add(extern), Info: stbGlobal<<4 | sttNotype, Section: shnUndefined})
+	}
+
+	relocations := map[*Section][]relocationEntry{}
+	for _, reference := range o.references {
+		entry := relocationEntry{Offset: uint64(reference.at)}
+		symbolNumber, kind := uint32(0), rX86_64_64
+		if symbol, defined := o.Symbols[reference.label]; defined && !globals[reference.label] {
+			symbolNumber = sectionSymbols[symbol.Section]
+			entry.Addend = int64(symbol.Offset)
+		} else {
+			symbolNumber = symbolNumbers[reference.label]
+		}
+		if reference.kind == relative32 {
+			kind = rX86_64_PC32
+			if reference.call && !o.defined(reference.label) {
+				kind = rX86_64_PLT32
+			}
+			// the processor adds the distance to where the instruction ends, the linker works from where the value is
+			entry.Addend -= int64(reference.next - reference.at)
+		}
+		entry.Info = uint64(symbolNumber)<<32 | uint64(kind)
+		relocations[reference.section] = append(relocations[reference.section], entry)
+	}
+
+	headers := []sectionHeader{{}}
+	contents := [][]byte{nil}
+	for _, section := range o.Sections {
+		kind := sectionKinds[section.Name]
+		headers = append(headers, sectionHeader{Name: names.add(section.Name), Type: shtProgbits, Flags: kind.flags, Alignment: kind.alignment})
+		contents = append(contents, section.Bytes)
+	}
+	symbolTable := len(o.Sections) + 1
+	for _, section := range o.Sections {
+		entries := relocations[section]
+		if len(entries) == 0 {
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Offset < entries[j].Offset })
+		symbolTable++
+		headers = append(headers, sectionHeader{Name: names.add(".rela" + section.Name), Type: shtRela, Info: uint32(sectionNumbers[section]), Alignment: 8, EntrySize: 24})
+		contents = append(contents, encodeTable(entries))
+	}
+	for index := range headers {
+		if headers[index].Type == shtRela {
+			headers[index].Link = uint32(symbolTable)
+		}
+	}
+	headers = append(headers, sectionHeader{Name: names.add(".symtab"), Type: shtSymtab, Link: uint32(symbolTable + 1), Info: uint32(firstGlobal), Alignment: 8, EntrySize: 24})
+	contents = append(contents, encodeTable(symbols))
+	headers = append(headers, sectionHeader{Name: names.add(".strtab"), Type: shtStrtab, Alignment: 1})
+	contents = append(contents, symbolNames.bytes)
+	headers = append(headers, sectionHeader{Name: names.add(".shstrtab"), Type: shtStrtab, Alignment: 1})
+	contents = append(contents, names.bytes)
+
+	out := make([]byte, 64)
+	for index := 1; index < len(headers); index++ {
+		alignment := int(headers[index].Alignment)
+		for alignment > 1 && len(out)%alignment != 0 {
+			out = append(out, 0)
+		}
+		headers[index].Offset = uint64(len(out))
+		headers[index].Size = uint64(len(contents[index]))
+		out = append(out, contents[index]...)
+	}
+	for len(out)%8 != 0 {
+		out = append(out, 0)
+	}
+	headerOffset := len(out)
+	out = append(out, encodeTable(headers)...)
+
+	header := elfHeader(1, 0, 0)
+	header.SectionHeaderOffset = uint64(headerOffset)
+	header.SectionHeaderCount = uint16(len(headers))
+	header.SectionNameTable = uint16(len(headers) - 1)
+	copy(out, encodeTable(header))
+	return out
+}
+
+func (o *Object) defined(label string) bool {
+	_, defined := o.Symbols[label]
+	return defined
+}
+
+// fileHeader is the header at the start of every ELF64 file
+type fileHeader struct {
+	Ident               [16]byte
+	Type                uint16
+	Machine             uint16
+	Version             uint32
+	Entry               uint64
+	ProgramHeaderOffset uint64
+	SectionHeaderOffset uint64
+	Flags               uint32
+	HeaderSize          uint16
+	ProgramHeaderSize   uint16
+	ProgramHeaderCount  uint16
+	SectionHeaderSize   uint16
+	SectionHeaderCount  uint16
+	SectionNameTable    uint16
+}
+
+// elfHeader is the header of a little endian x86-64 file of the given type
+func elfHeader(fileType uint16, entry uint64, programHeaders uint16) fileHeader {
+	header := fileHeader{
+		Type:               fileType,
+		Machine:            62,
+		Version:            1,
+		Entry:              entry,
+		HeaderSize:         64,
+		ProgramHeaderCount: programHeaders,
+		SectionHeaderSize:  64,
+	}
+	copy(header.Ident[:], []byte{0x7F, 'E', 'L', 'F', 2, 1, 1})
+	if programHeaders > 0 {
+		header.ProgramHeaderOffset = 64
+		header.ProgramHeaderSize = 56
+	}
+	return header
+}
+
+// encodeTable lays out a fixed size structure or a slice of them as little endian bytes
+func encodeTable(table interface{}) []byte {
+	buffer := &bytes.Buffer{}
+	binary.Write(buffer, binary.LittleEndian, table)
+	return buffer.Bytes()
+}
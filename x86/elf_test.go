@@ -0,0 +1,103 @@
+package x86
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func Test_Object_Relocatable(t *testing.T) {
+	object, err := Assemble(`bits 64
+section .data
+format: db "%lld",0
+message: db "hi",0
+section .text
+global main
+extern printf
+main:
+lea rdi,[rel message]
+mov rax,qword format
+call printf
+call helper
+ret
+helper:
+ret
+section .note.GNU-stack noalloc noexec nowrite progbits`)
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+	file, err := elf.NewFile(bytes.NewReader(object.Relocatable()))
+	if err != nil {
+		t.Fatalf("elf.NewFile() error = %v", err)
+	}
+	if file.Type != elf.ET_REL || file.Machine != elf.EM_X86_64 || file.Class != elf.ELFCLASS64 {
+		t.Errorf("header = %v %v %v, want a 64 bit x86-64 relocatable object", file.Type, file.Machine, file.Class)
+	}
+
+	names := []string{}
+	for _, section := range file.Sections {
+		names = append(names, section.Name)
+	}
+	wantNames := []string{"", ".data", ".text", ".note.GNU-stack", ".rela.text", ".symtab", ".strtab", ".shstrtab"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("sections = %v, want %v", names, wantNames)
+	}
+	text, _ := file.Section(".text").Data()
+	if !bytes.Equal(text, object.Sections[1].Bytes) {
+		t.Errorf(".text = %x, want %x", text, object.Sections[1].Bytes)
+	}
+	if file.Section(".text").Flags != elf.SHF_ALLOC|elf.SHF_EXECINSTR {
+		t.Errorf(".text flags = %v", file.Section(".text").Flags)
+	}
+
+	symbols, err := file.Symbols()
+	if err != nil {
+		t.Fatalf("Symbols() error = %v", err)
+	}
+	wantSymbols := map[string]string{
+		"format":  "STB_LOCAL .data 0",
+		"message": "STB_LOCAL .data 5",
+		"helper":  "STB_LOCAL .text 28",
+		"main":    "STB_GLOBAL .text 0",
+		"printf":  "STB_GLOBAL undefined 0",
+	}
+	for _, symbol := range symbols {
+		if symbol.Name == "" {
+			continue
+		}
+		section := "undefined"
+		if symbol.Section != elf.SHN_UNDEF {
+			section = file.Sections[symbol.Section].Name
+		}
+		got := fmt.Sprintf("%v %s %d", elf.ST_BIND(symbol.Info), section, symbol.Value)
+		if got != wantSymbols[symbol.Name] {
+			t.Errorf("symbol %s = %v, want %v", symbol.Name, got, wantSymbols[symbol.Name])
+		}
+	}
+
+	relocations, _ := file.Section(".rela.text").Data()
+	got := []string{}
+	for index := 0; index+24 <= len(relocations); index += 24 {
+		entry := relocations[index : index+24]
+		offset := file.ByteOrder.Uint64(entry)
+		info := file.ByteOrder.Uint64(entry[8:])
+		addend := int64(file.ByteOrder.Uint64(entry[16:]))
+		// the null symbol is left out of Symbols
+		symbol := symbols[info>>32-1]
+		name := symbol.Name
+		if name == "" {
+			name = file.Sections[symbol.Section].Name
+		}
+		got = append(got, fmt.Sprintf("%v %d %s %d", elf.R_X86_64(info&0xffffffff), offset, name, addend))
+	}
+	want := []string{
+		"R_X86_64_PC32 3 .data 1",
+		"R_X86_64_64 9 .data 0",
+		"R_X86_64_PLT32 18 printf -4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relocations = %v, want %v", got, want)
+	}
+}
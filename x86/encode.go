@@ -0,0 +1,339 @@
+package x86
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+type fixupKind int
+
+const (
+	// relative32 is a 4 byte distance from the end of the instruction, used by jumps, calls and [rel label]
+	relative32 fixupKind = iota
+	// absolute64 is the 8 byte address of a label
+	absolute64
+)
+
+// fixup is a place in an encoded instruction that needs the address of a label once it is known
+type fixup struct {
+	kind fixupKind
+	// offset is where the value goes, from the start of the instruction
+	offset int
+	label  string
+	// call is true for the target of a call, which goes through the procedure linkage table when it is extern
+	call bool
+}
+
+// encoded is the bytes of one instruction and the fixups still to be made to them
+type encoded struct {
+	bytes  []byte
+	fixups []fixup
+}
+
+var arithmetic = map[string]struct {
+	// opcode is the form r/m,reg, the form reg,r/m is two more
+	opcode byte
+	// digit picks the operation in the 81 and 83 immediate forms
+	digit byte
+}{
+	"add": {0x01, 0},
+	"or":  {0x09, 1},
+	"and": {0x21, 4},
+	"sub": {0x29, 5},
+	"xor": {0x31, 6},
+	"cmp": {0x39, 7},
+}
+
+// unary are the F7 group instructions taking a single r/m operand, by the digit that picks each one
+var unary = map[string]byte{"not": 2, "neg": 3, "mul": 4, "imul": 5, "div": 6, "idiv": 7}
+
+var conditions = map[string]byte{
+	"o": 0x0, "no": 0x1, "b": 0x2, "c": 0x2, "nae": 0x2, "ae": 0x3, "nb": 0x3, "nc": 0x3,
+	"e": 0x4, "z": 0x4, "ne": 0x5, "nz": 0x5, "be": 0x6, "na": 0x6, "a": 0x7, "nbe": 0x7,
+	"s": 0x8, "ns": 0x9, "p": 0xA, "pe": 0xA, "np": 0xB, "po": 0xB,
+	"l": 0xC, "nge": 0xC, "ge": 0xD, "nl": 0xD, "le": 0xE, "ng": 0xE, "g": 0xF, "nle": 0xF,
+}
+
+// others are the instructions that are neither arithmetic, unary nor conditional
+var others = map[string]bool{
+	"ret": true, "cqo": true, "syscall": true, "nop": true, "push": true, "pop": true,
+	"jmp": true, "call": true, "mov": true, "lea": true, "movzx": true, "test": true,
+}
+
+// conditionOf reads the condition at the end of a conditional jump or set
+func conditionOf(mnemonic string, prefix string) (byte, bool) {
+	if !strings.HasPrefix(mnemonic, prefix) {
+		return 0, false
+	}
+	condition, exists := conditions[mnemonic[len(prefix):]]
+	return condition, exists
+}
+
+// encode turns one instruction into machine code
+func encode(mnemonic string, operands []operand) (encoded, error) {
+	jumpCondition, isJump := conditionOf(mnemonic, "j")
+	setCondition, isSet := conditionOf(mnemonic, "set")
+	digit, isUnary := unary[mnemonic]
+	if _, isArithmetic := arithmetic[mnemonic]; !isArithmetic && !isJump && !isSet && !isUnary && !others[mnemonic] {
+		return encoded{}, fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+	unsupported := fmt.Errorf("%s cannot take these operands", mnemonic)
+	count := len(operands)
+	var first, second operand
+	if count > 0 {
+		first = operands[0]
+	}
+	if count > 1 {
+		second = operands[1]
+	}
+
+	switch {
+	case count == 0:
+		switch mnemonic {
+		case "ret":
+			return encoded{bytes: []byte{0xC3}}, nil
+		case "cqo":
+			return encoded{bytes: []byte{0x48, 0x99}}, nil
+		case "syscall":
+			return encoded{bytes: []byte{0x0F, 0x05}}, nil
+		case "nop":
+			return encoded{bytes: []byte{0x90}}, nil
+		}
+	case mnemonic == "push" || mnemonic == "pop":
+		if count != 1 || first.kind != registerOperand || first.register.size != 8 {
+			return encoded{}, unsupported
+		}
+		opcode := byte(0x50)
+		if mnemonic == "pop" {
+			opcode = 0x58
+		}
+		bytes := []byte{}
+		if first.register.number >= 8 {
+			bytes = append(bytes, 0x41)
+		}
+		return encoded{bytes: append(bytes, opcode+first.register.number&7)}, nil
+	case mnemonic == "jmp" || mnemonic == "call":
+		if count != 1 || first.kind != labelOperand {
+			return encoded{}, unsupported
+		}
+		opcode := byte(0xE9)
+		if mnemonic == "call" {
+			opcode = 0xE8
+		}
+		return encoded{
+			bytes:  []byte{opcode, 0, 0, 0, 0},
+			fixups: []fixup{{kind: relative32, offset: 1, label: first.label, call: mnemonic == "call"}},
+		}, nil
+	case isJump:
+		if count != 1 || first.kind != labelOperand {
+			return encoded{}, unsupported
+		}
+		return encoded{
+			bytes:  []byte{0x0F, 0x80 | jumpCondition, 0, 0, 0, 0},
+			fixups: []fixup{{kind: relative32, offset: 2, label: first.label}},
+		}, nil
+	case isSet:
+		if count != 1 || first.kind != registerOperand || first.register.size != 1 {
+			return encoded{}, unsupported
+		}
+		return withModRM(false, []byte{0x0F, 0x90 | setCondition}, 0, first, nil), nil
+	case isUnary && count == 1:
+		if !isRM(first) || operandSize(first) != 8 {
+			return encoded{}, unsupported
+		}
+		return withModRM(true, []byte{0xF7}, digit, first, nil), nil
+	case count != 2:
+		return encoded{}, unsupported
+	}
+
+	switch mnemonic {
+	case "mov":
+		return encodeMov(first, second, unsupported)
+	case "lea":
+		if first.kind != registerOperand || first.register.size != 8 || second.kind != memoryOperand {
+			return encoded{}, unsupported
+		}
+		return withModRM(true, []byte{0x8D}, first.register.number, second, nil), nil
+	case "movzx":
+		if first.kind != registerOperand || first.register.size != 8 || !isRM(second) || operandSize(second) != 1 {
+			return encoded{}, unsupported
+		}
+		return withModRM(true, []byte{0x0F, 0xB6}, first.register.number, second, nil), nil
+	case "imul":
+		if first.kind != registerOperand || first.register.size != 8 || !isRM(second) || operandSize(second) != 8 {
+			return encoded{}, unsupported
+		}
+		return withModRM(true, []byte{0x0F, 0xAF}, first.register.number, second, nil), nil
+	case "test":
+		if isRM(first) && second.kind == registerOperand && sameSize(first, second) {
+			return withModRM(second.register.size == 8, []byte{0x85}, second.register.number, first, nil), nil
+		}
+		return encoded{}, unsupported
+	}
+
+	operation, isArithmetic := arithmetic[mnemonic]
+	if !isArithmetic {
+		return encoded{}, unsupported
+	}
+	switch {
+	case isRM(first) && second.kind == registerOperand && sameSize(first, second) && second.register.size >= 4:
+		return withModRM(second.register.size == 8, []byte{operation.opcode}, second.register.number, first, nil), nil
+	case first.kind == registerOperand && second.kind == memoryOperand && sameSize(first, second) && first.register.size >= 4:
+		return withModRM(first.register.size == 8, []byte{operation.opcode + 2}, first.register.number, second, nil), nil
+	case isRM(first) && second.kind == immediateOperand && operandSize(first) >= 4:
+		wide := operandSize(first) == 8
+		if second.value >= -128 && second.value <= 127 {
+			return withModRM(wide, []byte{0x83}, operation.digit, first, []byte{byte(second.value)}), nil
+		}
+		if second.value >= -1<<31 && second.value < 1<<31 {
+			return withModRM(wide, []byte{0x81}, operation.digit, first, le32(int32(second.value))), nil
+		}
+	}
+	return encoded{}, unsupported
+}
+
+func encodeMov(destination operand, source operand, unsupported error) (encoded, error) {
+	switch {
+	case isRM(destination) && source.kind == registerOperand && source.register.size == 1 && operandSize(destination) == 1:
+		return withModRM(false, []byte{0x88}, source.register.number, destination, nil), nil
+	case destination.kind == registerOperand && destination.register.size == 1 && source.kind == memoryOperand && operandSize(source) == 1:
+		return withModRM(false, []byte{0x8A}, destination.register.number, source, nil), nil
+	case destination.kind == registerOperand && source.kind == registerOperand && sameSize(destination, source) && source.register.size >= 4:
+		return withModRM(source.register.size == 8, []byte{0x89}, source.register.number, destination, nil), nil
+	case destination.kind == memoryOperand && source.kind == registerOperand && sameSize(destination, source) && source.register.size >= 4:
+		return withModRM(source.register.size == 8, []byte{0x89}, source.register.number, destination, nil), nil
+	case destination.kind == registerOperand && source.kind == memoryOperand && sameSize(destination, source) && destination.register.size >= 4:
+		return withModRM(destination.register.size == 8, []byte{0x8B}, destination.register.number, source, nil), nil
+	case destination.kind == registerOperand && destination.register.size >= 4 && source.kind == immediateOperand:
+		value := source.value
+		switch {
+		case value >= 0 && value <= 0xFFFFFFFF:
+			// writing the low half of a register clears the rest, so small numbers need no REX.W
+			return movImmediate(destination.register, false, le32(int32(uint32(value)))), nil
+		case destination.register.size == 8 && value >= -1<<31 && value < 0:
+			return withModRM(true, []byte{0xC7}, 0, destination, le32(int32(value))), nil
+		case destination.register.size == 8:
+			return movImmediate(destination.register, true, le64(value)), nil
+		}
+	case destination.kind == registerOperand && destination.register.size == 8 && source.kind == labelOperand:
+		instruction := movImmediate(destination.register, true, make([]byte, 8))
+		instruction.fixups = []fixup{{kind: absolute64, offset: len(instruction.bytes) - 8, label: source.label}}
+		return instruction, nil
+	case destination.kind == memoryOperand && operandSize(destination) == 8 && source.kind == immediateOperand:
+		if source.value >= -1<<31 && source.value < 1<<31 {
+			return withModRM(true, []byte{0xC7}, 0, destination, le32(int32(source.value))), nil
+		}
+	}
+	return encoded{}, unsupported
+}
+
+// movImmediate is the B8+register form, which takes an immediate as wide as the register it writes
+func movImmediate(destination register, wide bool, immediate []byte) encoded {
+	bytes := []byte{}
+	if rex := rexPrefix(wide, 0, destination.number); rex != 0 {
+		bytes = append(bytes, rex)
+	}
+	bytes = append(bytes, 0xB8+destination.number&7)
+	return encoded{bytes: append(bytes, immediate...)}
+}
+
+// withModRM encodes opcode with reg in the reg field of the ModRM byte and rm as its register or
+// memory operand, followed by immediate
+func withModRM(wide bool, opcode []byte, reg byte, rm operand, immediate []byte) encoded {
+	modrm := []byte{}
+	fixups := []fixup{}
+	rmNumber := byte(0)
+	switch {
+	case rm.kind == registerOperand:
+		rmNumber = rm.register.number
+		modrm = append(modrm, 0xC0|(reg&7)<<3|rmNumber&7)
+	case rm.ripRelative:
+		modrm = append(modrm, 0x05|(reg&7)<<3, 0, 0, 0, 0)
+		fixups = append(fixups, fixup{kind: relative32, offset: 1, label: rm.label})
+	default:
+		rmNumber = rm.base.number
+		mode := byte(2)
+		switch {
+		// rbp and r13 as a base with no displacement would mean rip-relative, so they always get one
+		case rm.displacement == 0 && rmNumber&7 != 5:
+			mode = 0
+		case rm.displacement >= -128 && rm.displacement <= 127:
+			mode = 1
+		}
+		modrm = append(modrm, mode<<6|(reg&7)<<3|rmNumber&7)
+		// rsp and r12 as a base need a SIB byte saying there is no index
+		if rmNumber&7 == 4 {
+			modrm = append(modrm, 0x24)
+		}
+		if mode == 1 {
+			modrm = append(modrm, byte(rm.displacement))
+		} else if mode == 2 {
+			modrm = append(modrm, le32(rm.displacement)...)
+		}
+	}
+
+	bytes := []byte{}
+	if rex := rexPrefix(wide, reg, rmNumber); rex != 0 {
+		bytes = append(bytes, rex)
+	}
+	bytes = append(bytes, opcode...)
+	for index := range fixups {
+		fixups[index].offset += len(bytes)
+	}
+	bytes = append(bytes, modrm...)
+	return encoded{bytes: append(bytes, immediate...), fixups: fixups}
+}
+
+// rexPrefix is the REX byte needed for a 64 bit operation or registers r8 to r15, 0 when none is
+func rexPrefix(wide bool, reg byte, rm byte) byte {
+	rex := byte(0x40)
+	if wide {
+		rex |= 0x08
+	}
+	if reg >= 8 {
+		rex |= 0x04
+	}
+	if rm >= 8 {
+		rex |= 0x01
+	}
+	if rex == 0x40 {
+		return 0
+	}
+	return rex
+}
+
+func isRM(operand operand) bool {
+	return operand.kind == registerOperand || operand.kind == memoryOperand
+}
+
+// operandSize is the width of a register or memory operand, memory without a size given is taken as a qword
+func operandSize(operand operand) int {
+	if operand.kind == registerOperand {
+		return operand.register.size
+	}
+	if operand.size == 0 {
+		return 8
+	}
+	return operand.size
+}
+
+// sameSize is true when the operands agree on their width, a memory operand with no size given agrees with anything
+func sameSize(first operand, second operand) bool {
+	if first.kind == memoryOperand && first.size == 0 || second.kind == memoryOperand && second.size == 0 {
+		return true
+	}
+	return operandSize(first) == operandSize(second)
+}
+
+func le32(value int32) []byte {
+	bytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(bytes, uint32(value))
+	return bytes
+}
+
+func le64(value int64) []byte {
+	bytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bytes, uint64(value))
+	return bytes
+}
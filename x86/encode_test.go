@@ -0,0 +1,70 @@
+package x86
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func Test_encode(t *testing.T) {
+	type args struct {
+		line string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Push", args{"push rbp"}, "55"},
+		{"Push an extended register", args{"push r12"}, "4154"},
+		{"Pop an extended register", args{"pop r15"}, "415f"},
+		{"Ret", args{"ret"}, "c3"},
+		{"Cqo", args{"cqo"}, "4899"},
+		{"Syscall", args{"syscall"}, "0f05"},
+		{"Move between registers", args{"mov rbp,rsp"}, "4889e5"},
+		{"Move into an extended register", args{"mov r10,rax"}, "4989c2"},
+		{"Move out of an extended register", args{"mov rax,r10"}, "4c89d0"},
+		{"Load a local", args{"mov rax,qword [rbp-8]"}, "488b45f8"},
+		{"Load a parameter without a size", args{"mov rax,[rbp+48]"}, "488b4530"},
+		{"Store a local", args{"mov qword [rbp-8],rax"}, "488945f8"},
+		{"Store far from rbp", args{"mov qword [rbp-200],r13"}, "4c89ad38ffffff"},
+		{"Store above rsp", args{"mov qword [rsp+8],rax"}, "4889442408"},
+		{"Store through r12", args{"mov qword [r12],rax"}, "49890424"},
+		{"Load through r13", args{"mov rax,qword [r13]"}, "498b4500"},
+		{"Small number", args{"mov rcx,qword 10"}, "b90a000000"},
+		{"Negative number", args{"mov r11,-5"}, "49c7c3fbffffff"},
+		{"Big number", args{"mov rax,0x123456789"}, "48b88967452301000000"},
+		{"Number into memory", args{"mov qword [rbp-16],-1"}, "48c745f0ffffffff"},
+		{"Small immediate", args{"sub rsp,16"}, "4883ec10"},
+		{"Large immediate", args{"sub rsp,4096"}, "4881ec00100000"},
+		{"Add", args{"add rax,rcx"}, "4801c8"},
+		{"Add from memory", args{"add rax,qword [rbp-8]"}, "480345f8"},
+		{"Subtract", args{"sub rax,rcx"}, "4829c8"},
+		{"Multiply", args{"imul rax,rcx"}, "480fafc1"},
+		{"Multiply from memory", args{"imul r10,qword [rbp-24]"}, "4c0faf55e8"},
+		{"Compare", args{"cmp rax,rcx"}, "4839c8"},
+		{"Compare with a number", args{"cmp rax,100"}, "4883f864"},
+		{"Divide", args{"idiv rcx"}, "48f7f9"},
+		{"Negate", args{"neg rax"}, "48f7d8"},
+		{"Test", args{"test rax,rax"}, "4885c0"},
+		{"Clear eax", args{"xor eax,eax"}, "31c0"},
+		{"Set if less", args{"setl al"}, "0f9cc0"},
+		{"Set if greater or equal", args{"setge al"}, "0f9dc0"},
+		{"Widen a byte", args{"movzx rax,al"}, "480fb6c0"},
+		{"Jump", args{"jmp there"}, "e900000000"},
+		{"Jump if zero", args{"jz there"}, "0f8400000000"},
+		{"Call", args{"call there"}, "e800000000"},
+		{"Address relative to rip", args{"lea rdi,[rel there]"}, "488d3d00000000"},
+		{"Address as a number", args{"mov rax,qword there"}, "48b80000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, err := Assemble("section .text\n" + tt.args.line + "\nextern there\n")
+			if err != nil {
+				t.Fatalf("Assemble() error = %v", err)
+			}
+			if got := hex.EncodeToString(object.Sections[0].Bytes); got != tt.want {
+				t.Errorf("Assemble() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
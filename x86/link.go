@@ -0,0 +1,120 @@
+package x86
+
+import (
+	"fmt"
+)
+
+const (
+	// imageBase is where the executable is loaded, the usual address for code that is not position independent
+	imageBase = 0x400000
+	pageSize  = 0x1000
+
+	ptLoad      uint32 = 1
+	ptGnuStack  uint32 = 0x6474E551
+	pfExecute   uint32 = 1
+	pfWrite     uint32 = 2
+	pfRead      uint32 = 4
+	headersSize        = 64 + 3*56
+)
+
+// programHeader is an entry of the program header table, telling the kernel what to load where
+type programHeader struct {
+	Type            uint32
+	Flags           uint32
+	Offset          uint64
+	Address         uint64
+	PhysicalAddress uint64
+	FileSize        uint64
+	MemorySize      uint64
+	Alignment       uint64
+}
+
+// Link puts objects together into a static ELF64 executable for linux, starting at the global
+// called entry. The code of every object goes in one segment and the data in another a page
+// further on, so nothing has to be loaded at an address that is not known now. Every reference
+// is filled in, a label is looked for in the object using it first and then among the globals
+func Link(entry string, objects ...*Object) ([]byte, error) {
+	text := make([]byte, headersSize)
+	data := []byte{}
+	offsets := map[*Section]int{}
+	for _, object := range objects {
+		for _, section := range object.Sections {
+			switch section.Name {
+			case ".text":
+				text = pad(text, 16, 0x90)
+				offsets[section] = len(text)
+				text = append(text, section.Bytes...)
+			case ".data":
+				data = pad(data, 16, 0)
+				offsets[section] = len(data)
+				data = append(data, section.Bytes...)
+			}
+		}
+	}
+	dataOffset := len(pad(text, pageSize, 0))
+	address := func(symbol Symbol) uint64 {
+		if symbol.Section.Name == ".data" {
+			return uint64(imageBase + dataOffset + offsets[symbol.Section] + symbol.Offset)
+		}
+		return uint64(imageBase + offsets[symbol.Section] + symbol.Offset)
+	}
+
+	globals := map[string]uint64{}
+	for _, object := range objects {
+		for _, global := range object.Globals {
+			if _, exists := globals[global]; exists {
+				return nil, fmt.Errorf("%s is defined more than once", global)
+			}
+			globals[global] = address(object.Symbols[global])
+		}
+	}
+
+	for _, object := range objects {
+		for _, reference := range object.references {
+			target, found := globals[reference.label]
+			if symbol, defined := object.Symbols[reference.label]; defined {
+				target, found = address(symbol), true
+			}
+			if !found {
+				return nil, fmt.Errorf("%s is used but never defined", reference.label)
+			}
+			bytes := text
+			if reference.section.Name == ".data" {
+				bytes = data
+			}
+			at := offsets[reference.section] + reference.at
+			if reference.kind == absolute64 {
+				copy(bytes[at:], le64(int64(target)))
+				continue
+			}
+			distance := int64(target) - int64(address(Symbol{Section: reference.section, Offset: reference.next}))
+			if distance < -1<<31 || distance >= 1<<31 {
+				return nil, fmt.Errorf("%s is too far away to reach", reference.label)
+			}
+			copy(bytes[at:], le32(int32(distance)))
+		}
+	}
+
+	start, found := globals[entry]
+	if !found {
+		return nil, fmt.Errorf("there is no %s to start at", entry)
+	}
+	header := elfHeader(2, start, 3)
+	header.SectionHeaderSize = 0
+	headers := []programHeader{
+		{Type: ptLoad, Flags: pfRead | pfExecute, Address: imageBase, PhysicalAddress: imageBase, FileSize: uint64(len(text)), MemorySize: uint64(len(text)), Alignment: pageSize},
+		{Type: ptLoad, Flags: pfRead | pfWrite, Offset: uint64(dataOffset), Address: uint64(imageBase + dataOffset), PhysicalAddress: uint64(imageBase + dataOffset), FileSize: uint64(len(data)), MemorySize: uint64(len(data)), Alignment: pageSize},
+		// the stack does not need to be executable
+		{Type: ptGnuStack, Flags: pfRead | pfWrite, Alignment: 16},
+	}
+	copy(text, append(encodeTable(header), encodeTable(headers)...))
+	return append(pad(text, pageSize, 0), data...), nil
+}
+
+// pad adds filler to bytes until its length is a multiple of size
+func pad(bytes []byte, size int, filler byte) []byte {
+	for len(bytes)%size != 0 {
+		bytes = append(bytes, filler)
+	}
+	return bytes
+}
@@ -0,0 +1,124 @@
+package x86
+
+import (
+	"bytes"
+	"debug/elf"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func Test_Link(t *testing.T) {
+	program, err := Assemble(`section .data
+message: db "linked",10
+section .text
+global _start
+extern leave
+_start:
+mov eax,1
+mov edi,1
+lea rsi,[rel message]
+mov edx,7
+syscall
+call leave`)
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+	library, err := Assemble(`section .text
+global leave
+leave:
+mov rax,qword code
+mov rdi,qword [rax]
+mov eax,60
+syscall
+section .data
+code: dq 42`)
+	if err != nil {
+		t.Fatalf("Assemble() error = %v", err)
+	}
+	executable, err := Link("_start", program, library)
+	if err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+
+	file, err := elf.NewFile(bytes.NewReader(executable))
+	if err != nil {
+		t.Fatalf("elf.NewFile() error = %v", err)
+	}
+	if file.Type != elf.ET_EXEC || file.Machine != elf.EM_X86_64 {
+		t.Errorf("header = %v %v, want an x86-64 executable", file.Type, file.Machine)
+	}
+	// the code starts on the first 16 byte boundary after the headers
+	if file.Entry != 0x4000f0 {
+		t.Errorf("entry = %#x, want 0x4000f0", file.Entry)
+	}
+	wantFlags := []elf.ProgFlag{elf.PF_R | elf.PF_X, elf.PF_R | elf.PF_W, elf.PF_R | elf.PF_W}
+	for index, program := range file.Progs {
+		if program.Flags != wantFlags[index] {
+			t.Errorf("program header %d flags = %v, want %v", index, program.Flags, wantFlags[index])
+		}
+	}
+	data := make([]byte, 7)
+	file.Progs[1].ReadAt(data, 0)
+	if string(data) != "linked\n" {
+		t.Errorf("data = %q, want the message first", data)
+	}
+
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("running the executable needs linux-amd64")
+	}
+	path := filepath.Join(t.TempDir(), "linked")
+	if err := ioutil.WriteFile(path, executable, 0755); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(path).Output()
+	exitError, exited := err.(*exec.ExitError)
+	if !exited || exitError.ExitCode() != 42 || string(output) != "linked\n" {
+		t.Errorf("running gave %q and %v, want \"linked\\n\" and exit status 42", output, err)
+	}
+}
+
+func Test_Link_errors(t *testing.T) {
+	assemble := func(source string) *Object {
+		object, err := Assemble(source)
+		if err != nil {
+			t.Fatalf("Assemble() error = %v", err)
+		}
+		return object
+	}
+	type args struct {
+		entry   string
+		objects []*Object
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Extern nobody defines",
+			args{"_start", []*Object{assemble("section .text\nglobal _start\nextern missing\n_start:\ncall missing")}},
+			"missing is used but never defined",
+		},
+		{
+			"Global defined twice",
+			args{"_start", []*Object{assemble("section .text\nglobal _start\n_start:\nret"), assemble("section .text\nglobal _start\n_start:\nret")}},
+			"_start is defined more than once",
+		},
+		{
+			"No entry",
+			args{"_start", []*Object{assemble("section .text\nglobal main\nmain:\nret")}},
+			"there is no _start to start at",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Link(tt.args.entry, tt.args.objects...)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Link() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package x86
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// register is a general purpose register, number is how instructions encode it and size is its width in bytes
+type register struct {
+	number byte
+	size   int
+}
+
+var registers = map[string]register{}
+
+func init() {
+	names64 := []string{"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi"}
+	names32 := []string{"eax", "ecx", "edx", "ebx", "esp", "ebp", "esi", "edi"}
+	for number := byte(0); number < 16; number++ {
+		if number < 8 {
+			registers[names64[number]] = register{number, 8}
+			registers[names32[number]] = register{number, 4}
+		} else {
+			name := "r" + strconv.Itoa(int(number))
+			registers[name] = register{number, 8}
+			registers[name+"d"] = register{number, 4}
+		}
+	}
+	// only the low bytes that need no REX prefix, setcc and movzx only ever use al
+	for number, name := range []string{"al", "cl", "dl", "bl"} {
+		registers[name] = register{byte(number), 1}
+	}
+}
+
+// IsRegister is true for the names of the general purpose registers the assembler knows
+func IsRegister(name string) bool {
+	_, exists := registers[name]
+	return exists
+}
+
+type operandKind int
+
+const (
+	registerOperand operandKind = iota
+	memoryOperand
+	immediateOperand
+	labelOperand
+)
+
+// operand is one argument of an instruction. Memory is either [base+displacement] or [rel label],
+// an immediate is a number and a label stands for the address of a symbol
+type operand struct {
+	kind     operandKind
+	register register
+	// size is the width in bytes given by a qword or similar in front, 0 when none was given
+	size         int
+	base         register
+	displacement int32
+	ripRelative  bool
+	label        string
+	value        int64
+}
+
+var sizeKeywords = map[string]int{"byte": 1, "word": 2, "dword": 4, "qword": 8}
+
+// parseOperand reads one operand, resolving local labels against scope
+func parseOperand(text string, scope string) (operand, error) {
+	text = strings.TrimSpace(text)
+	size := 0
+	if space := strings.IndexAny(text, " \t"); space > 0 {
+		if keywordSize, isKeyword := sizeKeywords[strings.ToLower(text[:space])]; isKeyword {
+			size = keywordSize
+			text = strings.TrimSpace(text[space:])
+		}
+	}
+	if reg, isRegister := registers[strings.ToLower(text)]; isRegister {
+		return operand{kind: registerOperand, register: reg, size: reg.size}, nil
+	}
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		memory, err := parseMemory(text[1:len(text)-1], scope)
+		memory.size = size
+		return memory, err
+	}
+	if value, err := parseNumber(text); err == nil {
+		return operand{kind: immediateOperand, value: value, size: size}, nil
+	}
+	if isLabel(text) {
+		return operand{kind: labelOperand, label: qualify(text, scope), size: size}, nil
+	}
+	return operand{}, fmt.Errorf("cannot understand the operand %q", text)
+}
+
+// parseMemory reads what is between the brackets of a memory operand
+func parseMemory(text string, scope string) (operand, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(strings.ToLower(text), "rel ") {
+		label := strings.TrimSpace(text[4:])
+		if !isLabel(label) {
+			return operand{}, fmt.Errorf("cannot address %q relative to rip", label)
+		}
+		return operand{kind: memoryOperand, ripRelative: true, label: qualify(label, scope)}, nil
+	}
+	split := strings.IndexAny(text, "+-")
+	baseName := text
+	displacement := int64(0)
+	if split >= 0 {
+		baseName = strings.TrimSpace(text[:split])
+		value, err := parseNumber(strings.ReplaceAll(text[split:], " ", ""))
+		if err != nil || value < -1<<31 || value >= 1<<31 {
+			return operand{}, fmt.Errorf("cannot understand the displacement in [%s]", text)
+		}
+		displacement = value
+	}
+	base, isRegister := registers[strings.ToLower(baseName)]
+	if !isRegister || base.size != 8 {
+		return operand{}, fmt.Errorf("cannot use %q as the base of [%s]", baseName, text)
+	}
+	return operand{kind: memoryOperand, base: base, displacement: int32(displacement)}, nil
+}
+
+func parseNumber(text string) (int64, error) {
+	text = strings.TrimPrefix(text, "+")
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "-0x") {
+		negative := strings.HasPrefix(text, "-")
+		value, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(text, "-"), "0x"), 16, 64)
+		if negative {
+			return -int64(value), err
+		}
+		return int64(value), err
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+func isLabel(text string) bool {
+	if text == "" || IsRegister(text) {
+		return false
+	}
+	for index, c := range text {
+		letter := c == '_' || c == '.' || c == '?' || c == '@' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (index == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// qualify gives a local label, one starting with a dot, the name of the label it belongs to
+func qualify(label string, scope string) string {
+	if strings.HasPrefix(label, ".") {
+		return scope + label
+	}
+	return label
+}